@@ -0,0 +1,55 @@
+package main
+
+import (
+	"admin-go/handlers"
+	"admin-go/models"
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+)
+
+// runCommand executes a maintenance command instead of starting the server
+func runCommand(name string, args []string) {
+	if models.DB == nil {
+		log.Fatal("A database connection is required to run commands")
+	}
+
+	switch name {
+	case "geo-backfill":
+		fs := flag.NewFlagSet(name, flag.ExitOnError)
+		from := fs.String("from", "", "first visit date to include (YYYY-MM-DD)")
+		to := fs.String("to", "", "last visit date to include (YYYY-MM-DD)")
+		limit := fs.Int("limit", 5000, "maximum number of distinct IPs to resolve")
+		fs.Parse(args)
+
+		printResult(handlers.RunGeoBackfill(*from, *to, *limit))
+	case "recompute-stats":
+		fs := flag.NewFlagSet(name, flag.ExitOnError)
+		from := fs.String("from", "", "first day to rebuild (YYYY-MM-DD, default today)")
+		to := fs.String("to", "", "last day to rebuild (YYYY-MM-DD, default today)")
+		fs.Parse(args)
+
+		result, err := handlers.RecomputeStats(*from, *to)
+		if err != nil {
+			log.Fatal(err)
+		}
+		printResult(result)
+	case "purge-logs":
+		result, err := handlers.RunRetention()
+		if result != nil {
+			printResult(result)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	default:
+		log.Fatalf("Unknown command %q (available: geo-backfill, recompute-stats, purge-logs)", name)
+	}
+}
+
+func printResult(v interface{}) {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -17,6 +20,65 @@ type Config struct {
 	Port       string
 	GinMode    string
 	JWTSecret  string
+
+	// Public site, for canonical URLs, the sitemap and structured data
+	SiteURL     string
+	SiteName    string
+	SiteLogoURL string
+
+	// Timezone used to bucket analytics into days and hours
+	ReportTimezone string
+	ReportLocation *time.Location
+
+	// Privacy
+	PrivacyIPMode   string // none, truncate or hash
+	PrivacyIPSalt   string
+	PrivacyHonorDNT bool
+
+	// Geolocation
+	GeoProviders     []string
+	GeoMMDBPath      string
+	GeoASNMMDBPath   string
+	GeoHTTPRateLimit int // requests per minute for remote providers
+	GeoCacheSize     int
+	GeoCacheTTLHours int
+	GeoQueueSize     int
+
+	// Seconds between stats aggregator runs (0 disables it)
+	StatsRollupInterval int
+	// Seconds between live match concurrency samples (0 disables them)
+	LiveSampleInterval int
+	// Seconds between dashboard snapshot rebuilds (0 builds it per request)
+	DashboardRefreshInterval int
+
+	// Retention of raw visitor data (0 days keeps it forever)
+	RetentionDays          int
+	RetentionIntervalHours int
+	RetentionBatchSize     int
+	RetentionArchive       bool
+	RetentionArchiveDir    string
+	RetentionArchiveFormat string // jsonl or csv
+
+	// SMTP server for alert and report emails
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// Scheduled analytics email reports
+	EmailReportsEnabled bool
+
+	// Traffic anomaly alerts
+	AlertCheckInterval    int // seconds between checks (0 disables them)
+	AlertWindowMinutes    int
+	AlertDropPercent      int
+	AlertSpikePercent     int
+	AlertErrorRatePercent int
+	AlertMinBaseline      int
+	AlertCooldownMinutes  int
+	AlertWebhookURL       string
+	AlertEmails           []string
 }
 
 var AppConfig *Config
@@ -40,6 +102,66 @@ func Load() {
 		Port:       getEnv("PORT", "3001"),
 		GinMode:    getEnv("GIN_MODE", "debug"),
 		JWTSecret:  getEnv("JWT_SECRET", "bongdaha-admin-secret-key-2024"),
+
+		SiteURL:     strings.TrimRight(getEnv("SITE_URL", "http://localhost:3000"), "/"),
+		SiteName:    getEnv("SITE_NAME", "Bongdaha"),
+		SiteLogoURL: getEnv("SITE_LOGO_URL", ""),
+
+		ReportTimezone: getEnv("REPORT_TIMEZONE", "Asia/Ho_Chi_Minh"),
+
+		PrivacyIPMode:   strings.ToLower(getEnv("PRIVACY_IP_MODE", "none")),
+		PrivacyIPSalt:   getEnv("PRIVACY_IP_SALT", ""),
+		PrivacyHonorDNT: getEnvBool("PRIVACY_HONOR_DNT", true),
+
+		GeoProviders:     getEnvList("GEO_PROVIDERS", "mmdb,ip-api"),
+		GeoMMDBPath:      getEnv("GEO_MMDB_PATH", "./data/GeoLite2-City.mmdb"),
+		GeoASNMMDBPath:   getEnv("GEO_ASN_MMDB_PATH", "./data/GeoLite2-ASN.mmdb"),
+		GeoHTTPRateLimit: getEnvInt("GEO_HTTP_RATE_LIMIT", 40),
+		GeoCacheSize:     getEnvInt("GEO_CACHE_SIZE", 10000),
+		GeoCacheTTLHours: getEnvInt("GEO_CACHE_TTL_HOURS", 720),
+		GeoQueueSize:     getEnvInt("GEO_QUEUE_SIZE", 1000),
+
+		StatsRollupInterval: getEnvInt("STATS_ROLLUP_INTERVAL", 300),
+		LiveSampleInterval:  getEnvInt("LIVE_SAMPLE_INTERVAL", 60),
+
+		DashboardRefreshInterval: getEnvInt("DASHBOARD_REFRESH_INTERVAL", 15),
+
+		RetentionDays:          getEnvInt("RETENTION_DAYS", 0),
+		RetentionIntervalHours: getEnvInt("RETENTION_INTERVAL_HOURS", 24),
+		RetentionBatchSize:     getEnvInt("RETENTION_BATCH_SIZE", 1000),
+		RetentionArchive:       getEnvBool("RETENTION_ARCHIVE", true),
+		RetentionArchiveDir:    getEnv("RETENTION_ARCHIVE_DIR", "./archive"),
+		RetentionArchiveFormat: strings.ToLower(getEnv("RETENTION_ARCHIVE_FORMAT", "jsonl")),
+
+		SMTPHost:     getEnv("SMTP_HOST", ""),
+		SMTPPort:     getEnvInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", ""),
+
+		EmailReportsEnabled: getEnvBool("EMAIL_REPORTS_ENABLED", true),
+
+		AlertCheckInterval:    getEnvInt("ALERT_CHECK_INTERVAL", 300),
+		AlertWindowMinutes:    getEnvInt("ALERT_WINDOW_MINUTES", 60),
+		AlertDropPercent:      getEnvInt("ALERT_DROP_PERCENT", 50),
+		AlertSpikePercent:     getEnvInt("ALERT_SPIKE_PERCENT", 200),
+		AlertErrorRatePercent: getEnvInt("ALERT_ERROR_RATE_PERCENT", 5),
+		AlertMinBaseline:      getEnvInt("ALERT_MIN_BASELINE", 20),
+		AlertCooldownMinutes:  getEnvInt("ALERT_COOLDOWN_MINUTES", 60),
+		AlertWebhookURL:       getEnv("ALERT_WEBHOOK_URL", ""),
+		AlertEmails:           getEnvList("ALERT_EMAILS", ""),
+	}
+
+	loc, err := time.LoadLocation(AppConfig.ReportTimezone)
+	if err != nil {
+		log.Printf("Warning: invalid REPORT_TIMEZONE %q, using UTC: %v", AppConfig.ReportTimezone, err)
+		AppConfig.ReportTimezone = "UTC"
+		loc = time.UTC
+	}
+	AppConfig.ReportLocation = loc
+
+	if AppConfig.PrivacyIPMode == "hash" && AppConfig.PrivacyIPSalt == "" {
+		log.Fatal("PRIVACY_IP_SALT is required when PRIVACY_IP_MODE=hash")
 	}
 
 	log.Printf("Config loaded - DB: %s@%s:%s/%s", AppConfig.DBUser, AppConfig.DBHost, AppConfig.DBPort, AppConfig.DBName)
@@ -59,6 +181,35 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvBool(key string, defaultValue bool) bool {
+	value := strings.ToLower(strings.TrimSpace(os.Getenv(key)))
+	switch value {
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	}
+	return defaultValue
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(strings.TrimSpace(os.Getenv(key))); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getEnvList parses a comma-separated list, lowercased and trimmed
+func getEnvList(key, defaultValue string) []string {
+	list := []string{}
+	for _, s := range strings.Split(getEnv(key, defaultValue), ",") {
+		if v := strings.ToLower(strings.TrimSpace(s)); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
 func GetPort() string {
 	if AppConfig == nil {
 		return "8080"
@@ -76,3 +227,62 @@ func GetJWTSecret() string {
 func GetDBConfig() *Config {
 	return AppConfig
 }
+
+func GetPrivacyConfig() *Config {
+	if AppConfig == nil {
+		return &Config{PrivacyIPMode: "none", PrivacyHonorDNT: true}
+	}
+	return AppConfig
+}
+
+func GetGeoConfig() *Config {
+	if AppConfig == nil {
+		return &Config{GeoProviders: []string{"ip-api"}, GeoHTTPRateLimit: 40, GeoCacheSize: 10000, GeoCacheTTLHours: 720, GeoQueueSize: 1000}
+	}
+	return AppConfig
+}
+
+func GetStatsConfig() *Config {
+	if AppConfig == nil {
+		return &Config{StatsRollupInterval: 300, LiveSampleInterval: 60, DashboardRefreshInterval: 15}
+	}
+	return AppConfig
+}
+
+func GetRetentionConfig() *Config {
+	if AppConfig == nil {
+		return &Config{RetentionIntervalHours: 24, RetentionBatchSize: 1000, RetentionArchive: true,
+			RetentionArchiveDir: "./archive", RetentionArchiveFormat: "jsonl"}
+	}
+	return AppConfig
+}
+
+func GetMailConfig() *Config {
+	if AppConfig == nil {
+		return &Config{SMTPPort: 587, EmailReportsEnabled: true}
+	}
+	return AppConfig
+}
+
+func GetAlertConfig() *Config {
+	if AppConfig == nil {
+		return &Config{AlertCheckInterval: 300, AlertWindowMinutes: 60, AlertDropPercent: 50, AlertSpikePercent: 200,
+			AlertErrorRatePercent: 5, AlertMinBaseline: 20, AlertCooldownMinutes: 60}
+	}
+	return AppConfig
+}
+
+func GetSiteConfig() *Config {
+	if AppConfig == nil {
+		return &Config{SiteURL: "http://localhost:3000", SiteName: "Bongdaha"}
+	}
+	return AppConfig
+}
+
+// GetReportLocation returns the timezone analytics are reported in
+func GetReportLocation() *time.Location {
+	if AppConfig == nil || AppConfig.ReportLocation == nil {
+		return time.UTC
+	}
+	return AppConfig.ReportLocation
+}
@@ -0,0 +1,228 @@
+package geoip
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/big"
+)
+
+// Data section field types as defined by the MaxMind DB format spec
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEndMarker = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+// maxDecodeDepth bounds the nesting of maps, arrays and pointers, so a
+// corrupt file with a pointer cycle fails instead of overflowing the stack
+const maxDecodeDepth = 64
+
+var errCorrupt = errors.New("geoip: corrupt data section")
+
+type decoder struct {
+	buf []byte
+}
+
+// decode reads the value at offset and returns it together with the
+// offset of the next field
+func (d *decoder) decode(offset uint) (interface{}, uint, error) {
+	return d.decodeAt(offset, 0)
+}
+
+// decodeAt decodes a value nested depth maps, arrays or pointers deep
+func (d *decoder) decodeAt(offset, depth uint) (interface{}, uint, error) {
+	if offset >= uint(len(d.buf)) || depth > maxDecodeDepth {
+		return nil, 0, errCorrupt
+	}
+
+	ctrl := d.buf[offset]
+	offset++
+	fieldType := uint(ctrl >> 5)
+
+	if fieldType == typePointer {
+		pointer, next, err := d.decodePointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decodeAt(pointer, depth+1)
+		return value, next, err
+	}
+
+	if fieldType == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return nil, 0, errCorrupt
+		}
+		fieldType = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	size, offset, err := d.sizeFromCtrl(ctrl, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return d.decodeFromType(fieldType, size, offset, depth)
+}
+
+func (d *decoder) sizeFromCtrl(ctrl byte, offset uint) (uint, uint, error) {
+	size := uint(ctrl & 0x1f)
+	if size < 29 {
+		return size, offset, nil
+	}
+
+	extra := size - 28
+	if offset+extra > uint(len(d.buf)) {
+		return 0, 0, errCorrupt
+	}
+	b := d.buf[offset : offset+extra]
+
+	switch size {
+	case 29:
+		size = 29 + uint(b[0])
+	case 30:
+		size = 285 + (uint(b[0])<<8 | uint(b[1]))
+	default:
+		size = 65821 + (uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]))
+	}
+	return size, offset + extra, nil
+}
+
+func (d *decoder) decodePointer(ctrl byte, offset uint) (uint, uint, error) {
+	pointerSize := uint((ctrl>>3)&0x3) + 1
+	if offset+pointerSize > uint(len(d.buf)) {
+		return 0, 0, errCorrupt
+	}
+	b := d.buf[offset : offset+pointerSize]
+
+	var prefix uint
+	if pointerSize != 4 {
+		prefix = uint(ctrl & 0x7)
+	}
+	value := prefix
+	for _, v := range b {
+		value = value<<8 | uint(v)
+	}
+
+	switch pointerSize {
+	case 2:
+		value += 2048
+	case 3:
+		value += 526336
+	}
+	return value, offset + pointerSize, nil
+}
+
+func (d *decoder) decodeFromType(fieldType, size, offset, depth uint) (interface{}, uint, error) {
+	switch fieldType {
+	case typeMap:
+		return d.decodeMap(size, offset, depth)
+	case typeArray:
+		return d.decodeArray(size, offset, depth)
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	end := offset + size
+	if end > uint(len(d.buf)) {
+		return nil, 0, errCorrupt
+	}
+	b := d.buf[offset:end]
+
+	switch fieldType {
+	case typeString:
+		return string(b), end, nil
+	case typeBytes:
+		out := make([]byte, len(b))
+		copy(out, b)
+		return out, end, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errCorrupt
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), end, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errCorrupt
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), end, nil
+	case typeUint16:
+		return uint16(unsignedFromBytes(b)), end, nil
+	case typeUint32:
+		return uint32(unsignedFromBytes(b)), end, nil
+	case typeInt32:
+		return int32(uint32(unsignedFromBytes(b))), end, nil
+	case typeUint64:
+		return unsignedFromBytes(b), end, nil
+	case typeUint128:
+		return new(big.Int).SetBytes(b), end, nil
+	case typeContainer, typeEndMarker:
+		return nil, end, nil
+	}
+	return nil, 0, errCorrupt
+}
+
+// decodeMap reads size key/value pairs. Every entry takes at least two
+// bytes, which bounds the size a corrupt file can make it allocate.
+func (d *decoder) decodeMap(size, offset, depth uint) (interface{}, uint, error) {
+	if size > (uint(len(d.buf))-offset)/2 {
+		return nil, 0, errCorrupt
+	}
+	m := make(map[string]interface{}, size)
+	for i := uint(0); i < size; i++ {
+		key, next, err := d.decodeAt(offset, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		keyStr, ok := key.(string)
+		if !ok {
+			return nil, 0, errCorrupt
+		}
+
+		value, next, err := d.decodeAt(next, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		m[keyStr] = value
+		offset = next
+	}
+	return m, offset, nil
+}
+
+// decodeArray reads size values of at least one byte each
+func (d *decoder) decodeArray(size, offset, depth uint) (interface{}, uint, error) {
+	if size > uint(len(d.buf))-offset {
+		return nil, 0, errCorrupt
+	}
+	a := make([]interface{}, 0, size)
+	for i := uint(0); i < size; i++ {
+		value, next, err := d.decodeAt(offset, depth+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		a = append(a, value)
+		offset = next
+	}
+	return a, offset, nil
+}
+
+func unsignedFromBytes(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
@@ -0,0 +1,164 @@
+package geoip
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		buf      []byte
+		offset   uint
+		want     interface{}
+		wantNext uint
+	}{
+		{"string", []byte{0x43, 'a', 'b', 'c'}, 0, "abc", 4},
+		{"empty string", []byte{0x40}, 0, "", 1},
+		{"uint16", []byte{0xA2, 0x12, 0x34}, 0, uint16(0x1234), 3},
+		{"uint32 zero", []byte{0xC0}, 0, uint32(0), 1},
+		{"uint32", []byte{0xC3, 0x01, 0x00, 0x00}, 0, uint32(65536), 4},
+		{"double", []byte{0x68, 0x3F, 0xF8, 0, 0, 0, 0, 0, 0}, 0, 1.5, 9},
+		{"float", []byte{0x04, 0x08, 0x3F, 0xC0, 0, 0}, 0, float32(1.5), 6},
+		{"int32 negative", []byte{0x04, 0x01, 0xFF, 0xFF, 0xFF, 0xFF}, 0, int32(-1), 6},
+		{"uint64", []byte{0x02, 0x02, 0x01, 0x00}, 0, uint64(256), 4},
+		{"uint128", []byte{0x02, 0x03, 0x01, 0x00}, 0, big.NewInt(256), 4},
+		{"bool true", []byte{0x01, 0x07}, 0, true, 2},
+		{"bool false", []byte{0x00, 0x07}, 0, false, 2},
+		{"bytes", []byte{0x82, 0xDE, 0xAD}, 0, []byte{0xDE, 0xAD}, 3},
+		{"array", []byte{0x02, 0x04, 0x41, 'a', 0x41, 'b'}, 0, []interface{}{"a", "b"}, 6},
+		{"map", []byte{0xE1, 0x41, 'k', 0x41, 'v'}, 0, map[string]interface{}{"k": "v"}, 5},
+		{
+			"nested map",
+			[]byte{0xE1, 0x47, 'c', 'o', 'u', 'n', 't', 'r', 'y', 0xE1, 0x48, 'i', 's', 'o', '_', 'c', 'o', 'd', 'e', 0x42, 'V', 'N'},
+			0,
+			map[string]interface{}{"country": map[string]interface{}{"iso_code": "VN"}},
+			22,
+		},
+		// A pointer resolves to the value it points at; decoding continues
+		// after the pointer itself
+		{"pointer", []byte{0x41, 'x', 0x20, 0x00}, 2, "x", 4},
+		{"map with pointer value", []byte{0x41, 'x', 0xE1, 0x41, 'k', 0x20, 0x00}, 2, map[string]interface{}{"k": "x"}, 7},
+	}
+	for _, tt := range tests {
+		d := decoder{buf: tt.buf}
+		got, next, err := d.decode(tt.offset)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: decode = %#v, want %#v", tt.name, got, tt.want)
+		}
+		if next != tt.wantNext {
+			t.Errorf("%s: next offset = %d, want %d", tt.name, next, tt.wantNext)
+		}
+	}
+}
+
+func TestDecodeLongString(t *testing.T) {
+	tests := []struct {
+		header []byte
+		size   int
+	}{
+		{[]byte{0x5D, 0x01}, 30},                // 29 + 1
+		{[]byte{0x5E, 0x00, 0x01}, 286},         // 285 + 1
+		{[]byte{0x5F, 0x00, 0x00, 0x01}, 65822}, // 65821 + 1
+	}
+	for _, tt := range tests {
+		buf := append([]byte{}, tt.header...)
+		for i := 0; i < tt.size; i++ {
+			buf = append(buf, 'x')
+		}
+		d := decoder{buf: buf}
+		got, next, err := d.decode(0)
+		if err != nil {
+			t.Errorf("size %d: unexpected error %v", tt.size, err)
+			continue
+		}
+		if s, ok := got.(string); !ok || len(s) != tt.size {
+			t.Errorf("size %d: decoded %d bytes", tt.size, len(got.(string)))
+		}
+		if next != uint(len(buf)) {
+			t.Errorf("size %d: next offset = %d, want %d", tt.size, next, len(buf))
+		}
+	}
+}
+
+func TestDecodePointerSizes(t *testing.T) {
+	tests := []struct {
+		ctrl  []byte
+		want  uint
+		bytes uint
+	}{
+		{[]byte{0x21, 0x02}, 0x102, 2},                     // 11 bits
+		{[]byte{0x28, 0x00, 0x00}, 2048, 3},                // 19 bits, +2048
+		{[]byte{0x30, 0x00, 0x00, 0x00}, 526336, 4},        // 27 bits, +526336
+		{[]byte{0x38, 0x00, 0x01, 0x00, 0x00}, 0x10000, 5}, // 32 bits
+	}
+	for _, tt := range tests {
+		d := decoder{buf: tt.ctrl}
+		pointer, next, err := d.decodePointer(tt.ctrl[0], 1)
+		if err != nil {
+			t.Errorf("% x: unexpected error %v", tt.ctrl, err)
+			continue
+		}
+		if pointer != tt.want || next != tt.bytes {
+			t.Errorf("% x: pointer = %d (next %d), want %d (next %d)", tt.ctrl, pointer, next, tt.want, tt.bytes)
+		}
+	}
+}
+
+func TestDecodeCorrupt(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		{"empty", []byte{}},
+		{"string past end", []byte{0x45, 'a', 'b'}},
+		{"truncated extended type", []byte{0x01}},
+		{"truncated size", []byte{0x5E, 0x01}},
+		{"truncated pointer", []byte{0x28, 0x00}},
+		{"pointer past end", []byte{0x20, 0x10}},
+		{"double of wrong size", []byte{0x64, 0, 0, 0, 0}},
+		{"map key not a string", []byte{0xE1, 0xA1, 0x01, 0x41, 'v'}},
+		{"map value missing", []byte{0xE1, 0x41, 'k'}},
+		{"array element missing", []byte{0x02, 0x04, 0x41, 'a'}},
+		{"pointer to itself", []byte{0x20, 0x00}},
+		{"map containing itself", []byte{0xE1, 0x41, 'k', 0x20, 0x00}},
+		{"map larger than the data", []byte{0xFF, 0xFF, 0xFF, 0xFF, 0x41, 'k', 0x41, 'v'}},
+		{"array larger than the data", []byte{0x1F, 0x04, 0xFF, 0xFF, 0xFF, 0x41, 'a'}},
+	}
+	for _, tt := range tests {
+		d := decoder{buf: tt.buf}
+		if got, _, err := d.decode(0); err == nil {
+			t.Errorf("%s: decode = %#v, want an error", tt.name, got)
+		}
+	}
+}
+
+func TestDecodeDepth(t *testing.T) {
+	// Arrays nested n deep around an empty string
+	nested := func(n int) []byte {
+		buf := []byte{}
+		for i := 0; i < n; i++ {
+			buf = append(buf, 0x01, 0x04)
+		}
+		return append(buf, 0x40)
+	}
+	tests := []struct {
+		depth   int
+		wantErr bool
+	}{
+		{1, false},
+		{maxDecodeDepth, false},
+		{maxDecodeDepth + 1, true},
+	}
+	for _, tt := range tests {
+		d := decoder{buf: nested(tt.depth)}
+		if _, _, err := d.decode(0); (err != nil) != tt.wantErr {
+			t.Errorf("depth %d: error = %v, wantErr %v", tt.depth, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Package geoip reads MaxMind DB (.mmdb) files such as GeoLite2-City,
+// GeoLite2-ASN or the DB-IP lite databases without any network access.
+package geoip
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+var metadataStartMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// Metadata describes the layout and content of a database file
+type Metadata struct {
+	NodeCount    uint
+	RecordSize   uint
+	IPVersion    uint
+	DatabaseType string
+	BuildEpoch   uint64
+	Languages    []string
+}
+
+// Reader performs lookups against an in-memory .mmdb file
+type Reader struct {
+	buf       []byte
+	data      []byte
+	Metadata  Metadata
+	nodeSize  uint
+	ipv4Start uint
+}
+
+// Open loads the whole database file into memory
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromBytes(buf)
+}
+
+// FromBytes creates a reader from the raw contents of a database file
+func FromBytes(buf []byte) (*Reader, error) {
+	markerPos := bytes.LastIndex(buf, metadataStartMarker)
+	if markerPos == -1 {
+		return nil, errors.New("geoip: invalid database, metadata section not found")
+	}
+	metaStart := markerPos + len(metadataStartMarker)
+
+	d := decoder{buf: buf[metaStart:]}
+	raw, _, err := d.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("geoip: failed to decode metadata: %w", err)
+	}
+	rawMeta, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("geoip: metadata is not a map")
+	}
+
+	meta := Metadata{
+		NodeCount:  uint(toUint64(rawMeta["node_count"])),
+		RecordSize: uint(toUint64(rawMeta["record_size"])),
+		IPVersion:  uint(toUint64(rawMeta["ip_version"])),
+		BuildEpoch: toUint64(rawMeta["build_epoch"]),
+	}
+	if dbType, ok := rawMeta["database_type"].(string); ok {
+		meta.DatabaseType = dbType
+	}
+	if langs, ok := rawMeta["languages"].([]interface{}); ok {
+		for _, l := range langs {
+			meta.Languages = append(meta.Languages, fmt.Sprint(l))
+		}
+	}
+
+	switch meta.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("geoip: unsupported record size %d", meta.RecordSize)
+	}
+
+	nodeSize := meta.RecordSize / 4
+	treeSize := meta.NodeCount * nodeSize
+	dataStart := treeSize + 16
+	if dataStart > uint(markerPos) {
+		return nil, errors.New("geoip: invalid database, search tree exceeds file size")
+	}
+
+	r := &Reader{
+		buf:      buf,
+		data:     buf[dataStart:markerPos],
+		Metadata: meta,
+		nodeSize: nodeSize,
+	}
+
+	// IPv4 addresses live under ::/96 in an IPv6 tree
+	if meta.IPVersion == 6 {
+		for i := 0; i < 96 && r.ipv4Start < meta.NodeCount; i++ {
+			r.ipv4Start = r.readNode(r.ipv4Start, 0)
+		}
+	}
+
+	return r, nil
+}
+
+// Lookup returns the decoded record for an IP address, or nil when the
+// address is not present in the database
+func (r *Reader) Lookup(ip net.IP) (map[string]interface{}, error) {
+	if ip == nil {
+		return nil, errors.New("geoip: invalid IP address")
+	}
+
+	pointer, err := r.findAddressInTree(ip)
+	if err != nil || pointer == 0 {
+		return nil, err
+	}
+
+	offset := pointer - r.Metadata.NodeCount - 16
+	if offset >= uint(len(r.data)) {
+		return nil, errors.New("geoip: invalid data pointer in search tree")
+	}
+
+	d := decoder{buf: r.data}
+	value, _, err := d.decode(offset)
+	if err != nil {
+		return nil, err
+	}
+	record, _ := value.(map[string]interface{})
+	return record, nil
+}
+
+func (r *Reader) findAddressInTree(ip net.IP) (uint, error) {
+	bitCount := uint(128)
+	node := uint(0)
+
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+		bitCount = 32
+		if r.Metadata.IPVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.Metadata.IPVersion == 4 {
+		return 0, errors.New("geoip: IPv6 address in an IPv4-only database")
+	}
+
+	nodeCount := r.Metadata.NodeCount
+	for i := uint(0); i < bitCount && node < nodeCount; i++ {
+		bit := uint(1) & (uint(ip[i>>3]) >> (7 - (i % 8)))
+		node = r.readNode(node, bit)
+	}
+
+	switch {
+	case node == nodeCount:
+		return 0, nil
+	case node > nodeCount:
+		return node, nil
+	}
+	return 0, errors.New("geoip: invalid node in search tree")
+}
+
+func (r *Reader) readNode(node, bit uint) uint {
+	offset := node * r.nodeSize
+	b := r.buf[offset : offset+r.nodeSize]
+
+	switch r.Metadata.RecordSize {
+	case 24:
+		if bit == 0 {
+			return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3])<<16 | uint(b[4])<<8 | uint(b[5])
+	case 28:
+		if bit == 0 {
+			return (uint(b[3])&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return (uint(b[3])&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		if bit == 0 {
+			return uint(b[0])<<24 | uint(b[1])<<16 | uint(b[2])<<8 | uint(b[3])
+		}
+		return uint(b[4])<<24 | uint(b[5])<<16 | uint(b[6])<<8 | uint(b[7])
+	}
+}
+
+func toUint64(v interface{}) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case uint32:
+		return uint64(n)
+	case uint16:
+		return uint64(n)
+	case int32:
+		return uint64(n)
+	}
+	return 0
+}
+
+// String walks nested maps (and the first element of arrays) in a decoded
+// record, e.g. String(record, "country", "names", "en")
+func String(record map[string]interface{}, path ...string) string {
+	var current interface{} = record
+	for _, key := range path {
+		if arr, ok := current.([]interface{}); ok {
+			if len(arr) == 0 {
+				return ""
+			}
+			current = arr[0]
+		}
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		current = m[key]
+	}
+	if s, ok := current.(string); ok {
+		return s
+	}
+	return ""
+}
+
+// Uint returns a numeric field of a decoded record
+func Uint(record map[string]interface{}, key string) uint64 {
+	return toUint64(record[key])
+}
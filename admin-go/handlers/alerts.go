@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"admin-go/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// alertBaselineDays is how many previous days the same time window is
+// averaged over to get the expected traffic
+const alertBaselineDays = 7
+
+// Tracking requests and failures since the last alert check
+var (
+	trackRequests atomic.Int64
+	trackErrors   atomic.Int64
+)
+
+// countTrackRequest and countTrackError feed the tracking error rate alert
+func countTrackRequest() {
+	trackRequests.Add(1)
+}
+
+func countTrackError() {
+	trackErrors.Add(1)
+}
+
+// StartAlertChecker compares recent traffic against its rolling baseline
+// every ALERT_CHECK_INTERVAL seconds and raises alerts for anomalies
+func StartAlertChecker() {
+	interval := time.Duration(config.GetAlertConfig().AlertCheckInterval) * time.Second
+	if interval <= 0 {
+		log.Println("Traffic alert checker disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if models.DB == nil {
+				continue
+			}
+			if _, err := checkTrafficAnomalies(now, true); err != nil {
+				log.Printf("Traffic alert check error: %v", err)
+			}
+		}
+	}()
+}
+
+// trafficCounts returns the pageviews and unique visitors of a time window
+func trafficCounts(from, to time.Time) (float64, float64, error) {
+	var pv, uv float64
+	err := models.DB.QueryRow(`
+		SELECT COUNT(*), COUNT(DISTINCT visitor_id)
+		FROM page_views
+		WHERE viewed_at >= ? AND viewed_at < ?`, from, to).Scan(&pv, &uv)
+	return pv, uv, err
+}
+
+// checkTrafficAnomalies compares PV/UV of the last ALERT_WINDOW_MINUTES with
+// the same window on the previous days, and the tracking error rate since the
+// last scheduled check with ALERT_ERROR_RATE_PERCENT. Returns the alerts it
+// raised.
+func checkTrafficAnomalies(now time.Time, scheduled bool) ([]models.Alert, error) {
+	cfg := config.GetAlertConfig()
+	window := time.Duration(cfg.AlertWindowMinutes) * time.Minute
+	start, end := now.Add(-window), now
+
+	currentPV, currentUV, err := trafficCounts(start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	// Same time of day on previous days, so matchday and night-time
+	// patterns are part of the baseline. Days without traffic are skipped.
+	var baselinePV, baselineUV float64
+	baselineDays := 0
+	for k := 1; k <= alertBaselineDays; k++ {
+		pv, uv, err := trafficCounts(start.AddDate(0, 0, -k), end.AddDate(0, 0, -k))
+		if err != nil {
+			return nil, err
+		}
+		if pv > 0 {
+			baselinePV += pv
+			baselineUV += uv
+			baselineDays++
+		}
+	}
+
+	raised := []models.Alert{}
+	if baselineDays > 0 {
+		metrics := []struct {
+			name     string
+			label    string
+			current  float64
+			baseline float64
+		}{
+			{"pageviews", "Pageviews", currentPV, baselinePV / float64(baselineDays)},
+			{"unique_visitors", "Unique visitors", currentUV, baselineUV / float64(baselineDays)},
+		}
+		for _, m := range metrics {
+			if m.baseline < float64(cfg.AlertMinBaseline) {
+				continue
+			}
+			change := (m.current - m.baseline) / m.baseline * 100
+
+			alert := models.Alert{
+				Metric:        m.name,
+				CurrentValue:  m.current,
+				BaselineValue: math.Round(m.baseline*10) / 10,
+				ChangePercent: math.Round(change*10) / 10,
+				WindowStart:   start,
+				WindowEnd:     end,
+				Severity:      "warning",
+			}
+			switch {
+			case change <= -float64(cfg.AlertDropPercent):
+				alert.AlertType = "traffic_drop"
+				// A near-total drop usually means tracking is broken
+				if change <= -90 {
+					alert.Severity = "critical"
+				}
+				alert.Message = fmt.Sprintf("%s dropped %.0f%% in the last %d minutes (%.0f vs. baseline %.0f)",
+					m.label, -change, cfg.AlertWindowMinutes, m.current, m.baseline)
+			case change >= float64(cfg.AlertSpikePercent):
+				alert.AlertType = "traffic_spike"
+				if change >= 2*float64(cfg.AlertSpikePercent) {
+					alert.Severity = "critical"
+				}
+				alert.Message = fmt.Sprintf("%s spiked %.0f%% in the last %d minutes (%.0f vs. baseline %.0f)",
+					m.label, change, cfg.AlertWindowMinutes, m.current, m.baseline)
+			default:
+				continue
+			}
+
+			if raiseAlert(&alert) {
+				raised = append(raised, alert)
+			}
+		}
+	}
+
+	// Tracking errors since the previous scheduled check. Only the
+	// scheduled check starts a new window; a manual run just reads it.
+	var requests, failures int64
+	if scheduled {
+		requests, failures = trackRequests.Swap(0), trackErrors.Swap(0)
+	} else {
+		requests, failures = trackRequests.Load(), trackErrors.Load()
+	}
+	// One request can fail several writes
+	failures = min(failures, requests)
+	if requests >= int64(cfg.AlertMinBaseline) {
+		rate := float64(failures) / float64(requests) * 100
+		if rate >= float64(cfg.AlertErrorRatePercent) {
+			alert := models.Alert{
+				AlertType:     "tracking_errors",
+				Metric:        "error_rate",
+				Severity:      "warning",
+				CurrentValue:  math.Round(rate*10) / 10,
+				BaselineValue: float64(cfg.AlertErrorRatePercent),
+				ChangePercent: math.Round(rate*10) / 10,
+				WindowStart:   start,
+				WindowEnd:     end,
+				Message: fmt.Sprintf("%.1f%% of tracking requests failed (%d of %d)",
+					rate, failures, requests),
+			}
+			if rate >= 50 {
+				alert.Severity = "critical"
+			}
+			if raiseAlert(&alert) {
+				raised = append(raised, alert)
+			}
+		}
+	}
+
+	return raised, nil
+}
+
+// raiseAlert stores and sends an alert unless the same alert was raised
+// within ALERT_COOLDOWN_MINUTES
+func raiseAlert(alert *models.Alert) bool {
+	cfg := config.GetAlertConfig()
+
+	var recent int
+	models.DB.QueryRow(`
+		SELECT COUNT(*) FROM alerts
+		WHERE alert_type = ? AND metric = ? AND created_at >= ?`,
+		alert.AlertType, alert.Metric, time.Now().Add(-time.Duration(cfg.AlertCooldownMinutes)*time.Minute)).Scan(&recent)
+	if recent > 0 {
+		return false
+	}
+
+	result, err := models.DB.Exec(`
+		INSERT INTO alerts (alert_type, metric, severity, message, current_value, baseline_value,
+		                    change_percent, window_start, window_end)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		alert.AlertType, alert.Metric, alert.Severity, alert.Message, alert.CurrentValue,
+		alert.BaselineValue, alert.ChangePercent, alert.WindowStart, alert.WindowEnd)
+	if err != nil {
+		log.Printf("Failed to store alert: %v", err)
+		return false
+	}
+	alert.ID, _ = result.LastInsertId()
+	alert.CreatedAt = time.Now()
+	log.Printf("Traffic alert [%s]: %s", alert.Severity, alert.Message)
+
+	notifications, _ := json.Marshal(notifyAlert(*alert))
+	alert.Notifications = string(notifications)
+	models.DB.Exec("UPDATE alerts SET notifications = ? WHERE id = ?", alert.Notifications, alert.ID)
+	return true
+}
+
+// notifyAlert sends an alert to every configured channel and returns the
+// outcome per channel ("ok" or the error)
+func notifyAlert(alert models.Alert) map[string]string {
+	cfg := config.GetAlertConfig()
+	results := map[string]string{}
+
+	if cfg.AlertWebhookURL != "" {
+		results["webhook"] = channelResult(sendAlertWebhook(cfg.AlertWebhookURL, alert))
+	}
+
+	if len(cfg.AlertEmails) > 0 {
+		body := fmt.Sprintf("%s\n\nSeverity: %s\nMetric: %s\nCurrent: %g\nBaseline: %g\nChange: %g%%\nWindow: %s - %s\n",
+			alert.Message, alert.Severity, alert.Metric, alert.CurrentValue, alert.BaselineValue, alert.ChangePercent,
+			alert.WindowStart.In(config.GetReportLocation()).Format("2006-01-02 15:04"),
+			alert.WindowEnd.In(config.GetReportLocation()).Format("2006-01-02 15:04"))
+		results["email"] = channelResult(sendMail(mailMessage{
+			To:      cfg.AlertEmails,
+			Subject: fmt.Sprintf("[%s] %s", alert.Severity, alert.Message),
+			Body:    body,
+		}))
+	}
+
+	return results
+}
+
+// sendAlertWebhook posts the alert as JSON. The text field makes the payload
+// usable as a Slack/Mattermost incoming webhook as is.
+func sendAlertWebhook(url string, alert models.Alert) error {
+	payload, _ := json.Marshal(gin.H{
+		"text":  fmt.Sprintf("[%s] %s", alert.Severity, alert.Message),
+		"alert": alert,
+	})
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+func channelResult(err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return "ok"
+}
+
+// GetAlerts lists alerts, newest first (status=open|acknowledged|all)
+func GetAlerts(c *gin.Context) {
+	whereClause := "1=1"
+	switch c.DefaultQuery("status", "all") {
+	case "open":
+		whereClause = "acknowledged = 0"
+	case "acknowledged":
+		whereClause = "acknowledged = 1"
+	case "all":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "status must be open, acknowledged or all"})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	alerts, err := loadAlerts(whereClause, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	var openCount int
+	models.DB.QueryRow("SELECT COUNT(*) FROM alerts WHERE acknowledged = 0").Scan(&openCount)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"data":       alerts,
+		"open_count": openCount,
+	})
+}
+
+func loadAlerts(whereClause string, limit int) ([]models.Alert, error) {
+	rows, err := models.DB.Query(`
+		SELECT id, alert_type, metric, severity, message, current_value, baseline_value, change_percent,
+		       window_start, window_end, COALESCE(notifications, ''), acknowledged, acknowledged_at, created_at
+		FROM alerts
+		WHERE `+whereClause+`
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []models.Alert{}
+	for rows.Next() {
+		var a models.Alert
+		if rows.Scan(&a.ID, &a.AlertType, &a.Metric, &a.Severity, &a.Message, &a.CurrentValue,
+			&a.BaselineValue, &a.ChangePercent, &a.WindowStart, &a.WindowEnd, &a.Notifications,
+			&a.Acknowledged, &a.AcknowledgedAt, &a.CreatedAt) == nil {
+			alerts = append(alerts, a)
+		}
+	}
+	return alerts, nil
+}
+
+func AcknowledgeAlert(c *gin.Context) {
+	id := c.Param("id")
+
+	result, err := models.DB.Exec(`
+		UPDATE alerts SET acknowledged = 1, acknowledged_at = ?
+		WHERE id = ? AND acknowledged = 0`, time.Now(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to acknowledge alert"})
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found or already acknowledged"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Alert acknowledged"})
+}
+
+// RunAlertCheck runs the anomaly check immediately
+func RunAlertCheck(c *gin.Context) {
+	raised, err := checkTrafficAnomalies(time.Now(), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": raised})
+}
+
+// TestAlertChannels sends a test alert to the configured channels without
+// storing it
+func TestAlertChannels(c *gin.Context) {
+	now := time.Now()
+	results := notifyAlert(models.Alert{
+		AlertType:   "test",
+		Metric:      "test",
+		Severity:    "info",
+		Message:     "Test alert from the admin panel",
+		WindowStart: now,
+		WindowEnd:   now,
+		CreatedAt:   now,
+	})
+	if len(results) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "No alert channels configured (ALERT_WEBHOOK_URL, ALERT_EMAILS)"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": results})
+}
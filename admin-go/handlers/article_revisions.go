@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"admin-go/models"
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// articleRevisionColumns are the article fields snapshotted by a revision
+const articleRevisionColumns = `title, slug, content, content_json, summary, cover_image, category_id, is_published, is_recommended`
+
+// saveArticleRevision snapshots the current state of an article as a new
+// revision by the logged-in admin, within the transaction that saved it.
+// Updates that changed nothing since the latest revision are not stored.
+func saveArticleRevision(tx *sql.Tx, c *gin.Context, articleId int64, action string, restoredFrom *int64) error {
+	if action == "update" {
+		var unchanged int
+		err := tx.QueryRow(`
+			SELECT COUNT(*) FROM article_revisions r
+			JOIN articles a ON a.id = r.article_id
+			WHERE r.id = (SELECT MAX(id) FROM article_revisions WHERE article_id = ?)
+			  AND r.title <=> a.title AND r.slug <=> a.slug AND r.content <=> a.content
+			  AND r.content_json <=> a.content_json AND r.summary <=> a.summary
+			  AND r.cover_image <=> a.cover_image AND r.category_id <=> a.category_id
+			  AND r.is_published <=> a.is_published AND r.is_recommended <=> a.is_recommended`,
+			articleId).Scan(&unchanged)
+		if err != nil {
+			return err
+		}
+		if unchanged > 0 {
+			return nil
+		}
+	}
+
+	var adminId *int64
+	if userId := c.GetInt64("user_id"); userId != 0 {
+		adminId = &userId
+	}
+
+	_, err := tx.Exec(`
+		INSERT INTO article_revisions (article_id, action, restored_from, `+articleRevisionColumns+`, admin_id, admin_name, created_at)
+		SELECT id, ?, ?, `+articleRevisionColumns+`, ?, ?, ?
+		FROM articles WHERE id = ?`,
+		action, restoredFrom, adminId, c.GetString("username"), time.Now(), articleId)
+	return err
+}
+
+// ensureBaselineRevision stores the current state of an article saved
+// before revisions existed, so its first edit can be undone
+func ensureBaselineRevision(tx *sql.Tx, articleId int64) error {
+	_, err := tx.Exec(`
+		INSERT INTO article_revisions (article_id, action, `+articleRevisionColumns+`, created_at)
+		SELECT id, 'original', `+articleRevisionColumns+`, COALESCE(updated_at, created_at)
+		FROM articles a
+		WHERE id = ? AND NOT EXISTS (SELECT 1 FROM article_revisions r WHERE r.article_id = a.id)`,
+		articleId)
+	return err
+}
+
+// loadArticleRevision reads one revision of an article; revision "current"
+// is the article as it is now
+func loadArticleRevision(articleId, revision string) (models.ArticleRevision, error) {
+	var r models.ArticleRevision
+	var restoredFrom, adminId sql.NullInt64
+
+	if revision == "current" {
+		err := models.DB.QueryRow(`
+			SELECT id, title, COALESCE(slug, ''), COALESCE(content, ''), COALESCE(content_json, ''),
+			       COALESCE(summary, ''), COALESCE(cover_image, ''), category_id, is_published, is_recommended, updated_at
+			FROM articles WHERE id = ?`, articleId).
+			Scan(&r.ArticleId, &r.Title, &r.Slug, &r.Content, &r.ContentJson, &r.Summary, &r.CoverImage,
+				&r.CategoryId, &r.IsPublished, &r.IsRecommended, &r.CreatedAt)
+		r.Action = "current"
+		return r, err
+	}
+
+	err := models.DB.QueryRow(`
+		SELECT id, article_id, action, restored_from, title, COALESCE(slug, ''), COALESCE(content, ''), COALESCE(content_json, ''),
+		       COALESCE(summary, ''), COALESCE(cover_image, ''), category_id, is_published, is_recommended,
+		       admin_id, COALESCE(admin_name, ''), created_at
+		FROM article_revisions
+		WHERE id = ? AND article_id = ?`, revision, articleId).
+		Scan(&r.ID, &r.ArticleId, &r.Action, &restoredFrom, &r.Title, &r.Slug, &r.Content, &r.ContentJson,
+			&r.Summary, &r.CoverImage, &r.CategoryId, &r.IsPublished, &r.IsRecommended,
+			&adminId, &r.AdminName, &r.CreatedAt)
+	if restoredFrom.Valid {
+		r.RestoredFrom = &restoredFrom.Int64
+	}
+	if adminId.Valid {
+		r.AdminId = &adminId.Int64
+	}
+	return r, err
+}
+
+// GetArticleRevisions lists the revisions of an article, newest first, with
+// the fields each one changed compared to the one before
+func GetArticleRevisions(c *gin.Context) {
+	articleId := c.Param("id")
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	// One extra row gives the changes of the oldest listed revision
+	rows, err := models.DB.Query(`
+		SELECT id, action, restored_from, title, COALESCE(slug, ''), MD5(COALESCE(content, '')), MD5(COALESCE(content_json, '')),
+		       COALESCE(summary, ''), COALESCE(cover_image, ''), category_id, is_published, is_recommended,
+		       admin_id, COALESCE(admin_name, ''), created_at, CHAR_LENGTH(COALESCE(content, ''))
+		FROM article_revisions
+		WHERE article_id = ?
+		ORDER BY id DESC
+		LIMIT ?`, articleId, limit+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	type revisionRow struct {
+		fields map[string]interface{}
+		item   map[string]interface{}
+	}
+	list := []revisionRow{}
+	for rows.Next() {
+		var id int64
+		var restoredFrom, categoryId, adminId *int64
+		var action, title, slug, contentHash, contentJsonHash, summary, coverImage, adminName string
+		var isPublished, isRecommended bool
+		var createdAt time.Time
+		var contentLength int
+		if rows.Scan(&id, &action, &restoredFrom, &title, &slug, &contentHash, &contentJsonHash,
+			&summary, &coverImage, &categoryId, &isPublished, &isRecommended,
+			&adminId, &adminName, &createdAt, &contentLength) != nil {
+			continue
+		}
+
+		var category interface{}
+		if categoryId != nil {
+			category = *categoryId
+		}
+		list = append(list, revisionRow{
+			fields: map[string]interface{}{
+				"title":          title,
+				"slug":           slug,
+				"content":        contentHash,
+				"content_json":   contentJsonHash,
+				"summary":        summary,
+				"cover_image":    coverImage,
+				"category_id":    category,
+				"is_published":   isPublished,
+				"is_recommended": isRecommended,
+			},
+			item: map[string]interface{}{
+				"id":             id,
+				"action":         action,
+				"restored_from":  restoredFrom,
+				"title":          title,
+				"slug":           slug,
+				"is_published":   isPublished,
+				"admin_id":       adminId,
+				"admin_name":     adminName,
+				"content_length": contentLength,
+				"created_at":     createdAt,
+			},
+		})
+	}
+
+	revisions := []map[string]interface{}{}
+	for i, r := range list {
+		if i == limit {
+			break
+		}
+		changed := []string{}
+		if i+1 < len(list) {
+			for _, field := range articleRevisionFields {
+				if r.fields[field] != list[i+1].fields[field] {
+					changed = append(changed, field)
+				}
+			}
+		}
+		r.item["changed_fields"] = changed
+		revisions = append(revisions, r.item)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": revisions})
+}
+
+// articleRevisionFields are the fields compared between revisions, in order
+var articleRevisionFields = []string{"title", "slug", "summary", "cover_image", "category_id", "is_published", "is_recommended", "content", "content_json"}
+
+// GetArticleRevision returns the full snapshot of one revision
+func GetArticleRevision(c *gin.Context) {
+	r, err := loadArticleRevision(c.Param("id"), c.Param("revision_id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": r})
+}
+
+// DiffArticleRevisions compares two revisions (to defaults to the current
+// article): the changed fields with their old and new values, and an HTML
+// diff of the content marking insertions with <ins> and deletions with <del>
+func DiffArticleRevisions(c *gin.Context) {
+	articleId := c.Param("id")
+	fromId := c.Query("from")
+	if fromId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from is required"})
+		return
+	}
+	toId := c.DefaultQuery("to", "current")
+
+	from, err := loadArticleRevision(articleId, fromId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+	to, err := loadArticleRevision(articleId, toId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+
+	values := func(r models.ArticleRevision) map[string]interface{} {
+		var category interface{}
+		if r.CategoryId != nil {
+			category = *r.CategoryId
+		}
+		return map[string]interface{}{
+			"title":          r.Title,
+			"slug":           r.Slug,
+			"summary":        r.Summary,
+			"cover_image":    r.CoverImage,
+			"category_id":    category,
+			"is_published":   r.IsPublished,
+			"is_recommended": r.IsRecommended,
+		}
+	}
+	fromValues, toValues := values(from), values(to)
+
+	changes := []gin.H{}
+	for _, field := range articleRevisionFields {
+		if field == "content" || field == "content_json" {
+			continue
+		}
+		if fromValues[field] != toValues[field] {
+			changes = append(changes, gin.H{"field": field, "from": fromValues[field], "to": toValues[field]})
+		}
+	}
+
+	content := gin.H{"changed": from.Content != to.Content}
+	if from.Content != to.Content {
+		ops := diffTokens(tokenizeHTML(from.Content), tokenizeHTML(to.Content))
+		inserted, deleted := diffWordCounts(ops)
+		content["html"] = renderHTMLDiff(ops)
+		content["words_inserted"] = inserted
+		content["words_deleted"] = deleted
+	}
+
+	summary := func(r models.ArticleRevision) gin.H {
+		return gin.H{"id": r.ID, "action": r.Action, "admin_name": r.AdminName, "created_at": r.CreatedAt}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"from":                 summary(from),
+			"to":                   summary(to),
+			"changes":              changes,
+			"content":              content,
+			"content_json_changed": from.ContentJson != to.ContentJson,
+		},
+	})
+}
+
+// RestoreArticleRevision puts the content of a revision (title, slug,
+// content, summary, cover image and category) back into the article and
+// records the restore as a new revision. Publishing flags are left as they are.
+func RestoreArticleRevision(c *gin.Context) {
+	articleId, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid article ID"})
+		return
+	}
+
+	r, err := loadArticleRevision(c.Param("id"), c.Param("revision_id"))
+	if err != nil || r.ID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+		return
+	}
+
+	// The slug may have been taken by another article since
+	var existingId int64
+	err = models.DB.QueryRow("SELECT id FROM articles WHERE slug = ? AND id != ?", r.Slug, articleId).Scan(&existingId)
+	if err == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Slug already exists"})
+		return
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore revision"})
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		UPDATE articles
+		SET title = ?, slug = NULLIF(?, ''), content = ?, content_json = ?, summary = ?, cover_image = ?, category_id = ?,
+		    last_editor_id = ?, updated_at = ?
+		WHERE id = ?`,
+		r.Title, r.Slug, r.Content, r.ContentJson, r.Summary, r.CoverImage, r.CategoryId,
+		currentAuthorId(c), time.Now(), articleId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore revision"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		return
+	}
+
+	if err := saveArticleRevision(tx, c, articleId, "restore", &r.ID); err != nil {
+		log.Printf("Article %d: saving revision failed: %v", articleId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore revision"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore revision"})
+		return
+	}
+	reindexArticles(articleId)
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Revision restored successfully"})
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"admin-go/models"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// articleScheduleInterval is how often scheduled articles are published and
+// unpublished; public endpoints apply the schedule to the second regardless
+const articleScheduleInterval = 30 * time.Second
+
+// publicArticleCondition matches the articles visible on the public site
+// (alias a): published or past their publish_at, and not past unpublish_at
+const publicArticleCondition = `(a.is_published = 1 OR a.publish_at <= NOW()) AND (a.unpublish_at IS NULL OR a.unpublish_at > NOW())`
+
+// scheduleTimeLayouts are the accepted publish_at/unpublish_at formats;
+// times without an offset are in the reporting timezone
+var scheduleTimeLayouts = []string{"2006-01-02T15:04", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02 15:04:05"}
+
+// parseScheduleTime reads an optional schedule time ("" means none)
+func parseScheduleTime(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+	for _, layout := range scheduleTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, config.GetReportLocation()); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid time %q, expected RFC 3339 or YYYY-MM-DD HH:MM", value)
+}
+
+// articleSchedule resolves the publishing state of a saved article. A future
+// publish_at keeps the article unpublished until then; a past one publishes
+// it now. unpublish_at must come after publish_at.
+func articleSchedule(req ArticleRequest, now time.Time) (isPublished bool, publishAt, unpublishAt *time.Time, err error) {
+	publishAt, err = parseScheduleTime(req.PublishAt)
+	if err != nil {
+		return false, nil, nil, err
+	}
+	unpublishAt, err = parseScheduleTime(req.UnpublishAt)
+	if err != nil {
+		return false, nil, nil, err
+	}
+
+	isPublished = req.IsPublished
+	if publishAt != nil {
+		if publishAt.After(now) {
+			isPublished = false
+		} else {
+			isPublished = true
+			publishAt = nil
+		}
+	}
+	if unpublishAt != nil {
+		if publishAt != nil && !unpublishAt.After(*publishAt) {
+			return false, nil, nil, errors.New("unpublish_at must be after publish_at")
+		}
+		if !unpublishAt.After(now) {
+			return false, nil, nil, errors.New("unpublish_at must be in the future")
+		}
+	}
+	return isPublished, publishAt, unpublishAt, nil
+}
+
+// firstPublishedAt is the published_at a save sets when the article has none
+// yet: now if it publishes the article, otherwise NULL
+func firstPublishedAt(isPublished bool, now time.Time) *time.Time {
+	if !isPublished {
+		return nil
+	}
+	return &now
+}
+
+// StartArticleScheduler publishes and unpublishes scheduled articles
+func StartArticleScheduler() {
+	go func() {
+		ticker := time.NewTicker(articleScheduleInterval)
+		defer ticker.Stop()
+		for {
+			if models.DB != nil {
+				if published, unpublished, err := runArticleSchedule(time.Now()); err != nil {
+					log.Printf("Article scheduler error: %v", err)
+				} else if published > 0 || unpublished > 0 {
+					log.Printf("Article scheduler: published %d, unpublished %d", published, unpublished)
+				}
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// runArticleSchedule flips the articles whose publish_at or unpublish_at has
+// passed, clearing the schedule it applied
+func runArticleSchedule(now time.Time) (published, unpublished int64, err error) {
+	result, err := models.DB.Exec(`
+		UPDATE articles SET published_at = COALESCE(published_at, publish_at), is_published = 1, publish_at = NULL, updated_at = ?
+		WHERE publish_at IS NOT NULL AND publish_at <= ?`, now, now)
+	if err != nil {
+		return 0, 0, err
+	}
+	published, _ = result.RowsAffected()
+
+	result, err = models.DB.Exec(`
+		UPDATE articles SET is_published = 0, unpublish_at = NULL, updated_at = ?
+		WHERE unpublish_at IS NOT NULL AND unpublish_at <= ?`, now, now)
+	if err != nil {
+		return published, 0, err
+	}
+	unpublished, _ = result.RowsAffected()
+	return published, unpublished, nil
+}
+
+// GetScheduledArticles lists the upcoming scheduled publications and
+// unpublications, soonest first
+func GetScheduledArticles(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 500 {
+		limit = 100
+	}
+
+	rows, err := models.DB.Query(`
+		SELECT a.id, a.title, a.slug, COALESCE(c.name, ''), a.is_published, a.publish_at, a.unpublish_at,
+		       LEAST(COALESCE(a.publish_at, a.unpublish_at), COALESCE(a.unpublish_at, a.publish_at)) as next_at
+		FROM articles a
+		LEFT JOIN categories c ON a.category_id = c.id
+		WHERE a.publish_at IS NOT NULL OR a.unpublish_at IS NOT NULL
+		ORDER BY next_at
+		LIMIT ?`, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	items := []map[string]interface{}{}
+	for rows.Next() {
+		var id int64
+		var title, slug, categoryName string
+		var isPublished bool
+		var publishAt, unpublishAt *time.Time
+		var nextAt time.Time
+		if rows.Scan(&id, &title, &slug, &categoryName, &isPublished, &publishAt, &unpublishAt, &nextAt) != nil {
+			continue
+		}
+
+		nextAction := "unpublish"
+		if publishAt != nil && publishAt.Equal(nextAt) {
+			nextAction = "publish"
+		}
+		items = append(items, map[string]interface{}{
+			"id":            id,
+			"title":         title,
+			"slug":          slug,
+			"category_name": categoryName,
+			"is_published":  isPublished,
+			"publish_at":    publishAt,
+			"unpublish_at":  unpublishAt,
+			"next_action":   nextAction,
+			"next_at":       nextAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": items})
+}
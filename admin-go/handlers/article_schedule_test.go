@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"testing"
+	"time"
+)
+
+func TestArticleSchedule(t *testing.T) {
+	withConfig(t, &config.Config{ReportLocation: time.FixedZone("ICT", 7*3600)})
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	at := func(s string) *time.Time {
+		t, _ := time.Parse(time.RFC3339, s)
+		return &t
+	}
+
+	tests := []struct {
+		name            string
+		req             ArticleRequest
+		wantPublished   bool
+		wantPublishAt   *time.Time
+		wantUnpublishAt *time.Time
+		wantErr         bool
+	}{
+		{name: "draft", req: ArticleRequest{}},
+		{name: "published", req: ArticleRequest{IsPublished: true}, wantPublished: true},
+		{
+			name:          "future publish_at holds the article back",
+			req:           ArticleRequest{IsPublished: true, PublishAt: "2026-03-15T08:00:00Z"},
+			wantPublishAt: at("2026-03-15T08:00:00Z"),
+		},
+		{
+			name:          "local time in the reporting timezone",
+			req:           ArticleRequest{PublishAt: "2026-03-15 08:00"},
+			wantPublishAt: at("2026-03-15T01:00:00Z"),
+		},
+		{
+			name:          "past publish_at publishes now",
+			req:           ArticleRequest{PublishAt: "2026-03-14T11:59:00Z"},
+			wantPublished: true,
+		},
+		{
+			name:            "published until unpublish_at",
+			req:             ArticleRequest{IsPublished: true, UnpublishAt: "2026-03-20T00:00:00Z"},
+			wantPublished:   true,
+			wantUnpublishAt: at("2026-03-20T00:00:00Z"),
+		},
+		{
+			name:            "scheduled window",
+			req:             ArticleRequest{PublishAt: "2026-03-15T00:00:00Z", UnpublishAt: "2026-03-16T00:00:00Z"},
+			wantPublishAt:   at("2026-03-15T00:00:00Z"),
+			wantUnpublishAt: at("2026-03-16T00:00:00Z"),
+		},
+		{
+			name:    "unpublish_at before publish_at",
+			req:     ArticleRequest{PublishAt: "2026-03-16T00:00:00Z", UnpublishAt: "2026-03-15T00:00:00Z"},
+			wantErr: true,
+		},
+		{
+			name:    "unpublish_at equal to publish_at",
+			req:     ArticleRequest{PublishAt: "2026-03-16T00:00:00Z", UnpublishAt: "2026-03-16T00:00:00Z"},
+			wantErr: true,
+		},
+		{
+			name:    "unpublish_at in the past",
+			req:     ArticleRequest{IsPublished: true, UnpublishAt: "2026-03-14T11:00:00Z"},
+			wantErr: true,
+		},
+		{name: "malformed publish_at", req: ArticleRequest{PublishAt: "tomorrow"}, wantErr: true},
+		{name: "malformed unpublish_at", req: ArticleRequest{UnpublishAt: "15/03/2026"}, wantErr: true},
+	}
+	sameTime := func(a, b *time.Time) bool {
+		return (a == nil && b == nil) || (a != nil && b != nil && a.Equal(*b))
+	}
+	for _, tt := range tests {
+		published, publishAt, unpublishAt, err := articleSchedule(tt.req, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if published != tt.wantPublished {
+			t.Errorf("%s: isPublished = %v, want %v", tt.name, published, tt.wantPublished)
+		}
+		if !sameTime(publishAt, tt.wantPublishAt) {
+			t.Errorf("%s: publishAt = %v, want %v", tt.name, publishAt, tt.wantPublishAt)
+		}
+		if !sameTime(unpublishAt, tt.wantUnpublishAt) {
+			t.Errorf("%s: unpublishAt = %v, want %v", tt.name, unpublishAt, tt.wantUnpublishAt)
+		}
+	}
+}
+
+func TestFirstPublishedAt(t *testing.T) {
+	now := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+	if got := firstPublishedAt(false, now); got != nil {
+		t.Errorf("firstPublishedAt(false) = %v, want nil", got)
+	}
+	if got := firstPublishedAt(true, now); got == nil || !got.Equal(now) {
+		t.Errorf("firstPublishedAt(true) = %v, want %v", got, now)
+	}
+}
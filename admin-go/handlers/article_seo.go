@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"admin-go/models"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Limits of the SEO fields. Generated descriptions are cut to about what
+// search engines show.
+const (
+	maxMetaTitleLength       = 255
+	maxMetaDescriptionLength = 500
+	autoDescriptionLength    = 160
+	maxHeadlineLength        = 110 // schema.org NewsArticle headline limit
+)
+
+// validateArticleSEO checks the SEO fields of an article request
+func validateArticleSEO(req *ArticleRequest) error {
+	req.MetaTitle = strings.TrimSpace(req.MetaTitle)
+	req.MetaDescription = strings.TrimSpace(req.MetaDescription)
+	req.CanonicalUrl = strings.TrimSpace(req.CanonicalUrl)
+	req.OgImage = strings.TrimSpace(req.OgImage)
+
+	if utf8.RuneCountInString(req.MetaTitle) > maxMetaTitleLength {
+		return errors.New("meta_title must be at most 255 characters")
+	}
+	if utf8.RuneCountInString(req.MetaDescription) > maxMetaDescriptionLength {
+		return errors.New("meta_description must be at most 500 characters")
+	}
+	if req.CanonicalUrl != "" {
+		u, err := url.Parse(req.CanonicalUrl)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return errors.New("canonical_url must be an absolute http(s) URL")
+		}
+	}
+	return nil
+}
+
+// absoluteURL resolves a site-relative URL (/uploads/...) against the site
+func absoluteURL(siteURL, u string) string {
+	if u == "" || strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+		return u
+	}
+	if strings.HasPrefix(u, "//") {
+		return "https:" + u
+	}
+	return siteURL + "/" + strings.TrimLeft(u, "/")
+}
+
+// truncateText shortens text to at most max characters on a word boundary,
+// ending with an ellipsis when cut
+func truncateText(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	cut := string(runes[:max-1])
+	if i := strings.LastIndex(cut, " "); i > len(cut)/2 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:-") + "…"
+}
+
+// articleSEO is the resolved SEO metadata of a public article
+type articleSEO struct {
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	CanonicalUrl string `json:"canonical_url"`
+	Image        string `json:"image"`
+	Robots       string `json:"robots"`
+	OgType       string `json:"og_type"`
+	TwitterCard  string `json:"twitter_card"`
+}
+
+// seoArticle holds what the metadata and structured data of an article are
+// built from
+type seoArticle struct {
+	Title           string
+	Slug            string
+	Summary         string
+	Content         string
+	CoverImage      string
+	CategoryName    string
+	MetaTitle       string
+	MetaDescription string
+	CanonicalUrl    string
+	OgImage         string
+	Noindex         bool
+	Author          map[string]interface{} // publicByline, or nil
+	Tags            []models.Tag
+	PublishedAt     time.Time
+	UpdatedAt       time.Time
+}
+
+// buildArticleSEO fills in the metadata of an article, falling back to the
+// title, summary, start of the content, article URL and cover image
+func buildArticleSEO(a seoArticle) articleSEO {
+	site := config.GetSiteConfig()
+
+	seo := articleSEO{
+		Title:        a.MetaTitle,
+		Description:  a.MetaDescription,
+		CanonicalUrl: a.CanonicalUrl,
+		Image:        absoluteURL(site.SiteURL, a.OgImage),
+		Robots:       "index, follow, max-image-preview:large",
+		OgType:       "article",
+		TwitterCard:  "summary",
+	}
+	if seo.Title == "" {
+		seo.Title = a.Title
+	}
+	if seo.Description == "" {
+		seo.Description = truncateText(strings.TrimSpace(a.Summary), autoDescriptionLength)
+	}
+	if seo.Description == "" {
+		seo.Description = truncateText(stripHTML(a.Content), autoDescriptionLength)
+	}
+	if seo.CanonicalUrl == "" {
+		seo.CanonicalUrl = site.SiteURL + "/article/" + a.Slug
+	}
+	if seo.Image == "" {
+		seo.Image = absoluteURL(site.SiteURL, a.CoverImage)
+	}
+	if seo.Image != "" {
+		seo.TwitterCard = "summary_large_image"
+	}
+	if a.Noindex {
+		seo.Robots = "noindex, follow"
+	}
+	return seo
+}
+
+// articleJSONLD renders the schema.org NewsArticle of an article, ready to
+// embed in a <script type="application/ld+json"> tag (<, > and & are
+// escaped by encoding/json)
+func articleJSONLD(a seoArticle, seo articleSEO) string {
+	site := config.GetSiteConfig()
+
+	publisher := map[string]interface{}{
+		"@type": "Organization",
+		"name":  site.SiteName,
+		"url":   site.SiteURL,
+	}
+	if site.SiteLogoURL != "" {
+		publisher["logo"] = map[string]interface{}{
+			"@type": "ImageObject",
+			"url":   absoluteURL(site.SiteURL, site.SiteLogoURL),
+		}
+	}
+
+	data := map[string]interface{}{
+		"@context":         "https://schema.org",
+		"@type":            "NewsArticle",
+		"headline":         truncateText(a.Title, maxHeadlineLength),
+		"description":      seo.Description,
+		"url":              seo.CanonicalUrl,
+		"mainEntityOfPage": map[string]interface{}{"@type": "WebPage", "@id": seo.CanonicalUrl},
+		"datePublished":    a.PublishedAt.Format(time.RFC3339),
+		"dateModified":     a.UpdatedAt.Format(time.RFC3339),
+		"publisher":        publisher,
+	}
+	if seo.Image != "" {
+		data["image"] = []string{seo.Image}
+	}
+	if a.Author != nil {
+		author := map[string]interface{}{
+			"@type": "Person",
+			"name":  a.Author["name"],
+			"url":   site.SiteURL + "/author/" + a.Author["slug"].(string),
+		}
+		if avatar, _ := a.Author["avatar"].(string); avatar != "" {
+			author["image"] = absoluteURL(site.SiteURL, avatar)
+		}
+		data["author"] = author
+	} else {
+		data["author"] = publisher
+	}
+	if a.CategoryName != "" {
+		data["articleSection"] = a.CategoryName
+	}
+	if len(a.Tags) > 0 {
+		keywords := make([]string, len(a.Tags))
+		for i, t := range a.Tags {
+			keywords[i] = t.Name
+		}
+		data["keywords"] = strings.Join(keywords, ", ")
+	}
+
+	out, err := json.Marshal(data)
+	if err != nil {
+		return ""
+	}
+	return string(out)
+}
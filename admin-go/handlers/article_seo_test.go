@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"admin-go/models"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTruncateText(t *testing.T) {
+	tests := []struct {
+		text string
+		max  int
+		want string
+	}{
+		{"short", 10, "short"},
+		{"abcde", 5, "abcde"},
+		{"abcdefghijkl", 5, "abcd…"},
+		{"hello world foo", 10, "hello…"},
+		{"hello, world again", 9, "hello…"},
+		// A space in the first half is not worth losing that much text for
+		{"ab cdefghijkl", 6, "ab cd…"},
+		{"Tiếng Việt có dấu", 10, "Tiếng…"},
+	}
+	for _, tt := range tests {
+		if got := truncateText(tt.text, tt.max); got != tt.want {
+			t.Errorf("truncateText(%q, %d) = %q, want %q", tt.text, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestBuildArticleSEO(t *testing.T) {
+	withConfig(t, nil)
+	defaults := articleSEO{
+		Robots:      "index, follow, max-image-preview:large",
+		OgType:      "article",
+		TwitterCard: "summary",
+	}
+	with := func(f func(*articleSEO)) articleSEO {
+		seo := defaults
+		f(&seo)
+		return seo
+	}
+
+	tests := []struct {
+		name    string
+		article seoArticle
+		want    articleSEO
+	}{
+		{
+			name:    "fallbacks",
+			article: seoArticle{Title: "Match report", Slug: "match-report", Summary: " A late winner. "},
+			want: with(func(s *articleSEO) {
+				s.Title = "Match report"
+				s.Description = "A late winner."
+				s.CanonicalUrl = "http://localhost:3000/article/match-report"
+			}),
+		},
+		{
+			name:    "description from the content",
+			article: seoArticle{Title: "T", Slug: "t", Content: "<p>Goals &amp; <b>highlights</b></p>"},
+			want: with(func(s *articleSEO) {
+				s.Title = "T"
+				s.Description = "Goals & highlights"
+				s.CanonicalUrl = "http://localhost:3000/article/t"
+			}),
+		},
+		{
+			name:    "long content is cut",
+			article: seoArticle{Title: "T", Slug: "t", Content: strings.Repeat("word ", 100)},
+			want: with(func(s *articleSEO) {
+				s.Title = "T"
+				s.Description = strings.TrimSpace(strings.Repeat("word ", 31)) + "…"
+				s.CanonicalUrl = "http://localhost:3000/article/t"
+			}),
+		},
+		{
+			name:    "cover image",
+			article: seoArticle{Title: "T", Slug: "t", Summary: "S", CoverImage: "/uploads/cover.jpg"},
+			want: with(func(s *articleSEO) {
+				s.Title = "T"
+				s.Description = "S"
+				s.CanonicalUrl = "http://localhost:3000/article/t"
+				s.Image = "http://localhost:3000/uploads/cover.jpg"
+				s.TwitterCard = "summary_large_image"
+			}),
+		},
+		{
+			name: "explicit fields",
+			article: seoArticle{
+				Title: "T", Slug: "t", Summary: "S", CoverImage: "/uploads/cover.jpg",
+				MetaTitle: "Meta title", MetaDescription: "Meta description",
+				CanonicalUrl: "https://example.com/original", OgImage: "//cdn.example.com/og.jpg", Noindex: true,
+			},
+			want: with(func(s *articleSEO) {
+				s.Title = "Meta title"
+				s.Description = "Meta description"
+				s.CanonicalUrl = "https://example.com/original"
+				s.Image = "https://cdn.example.com/og.jpg"
+				s.TwitterCard = "summary_large_image"
+				s.Robots = "noindex, follow"
+			}),
+		},
+	}
+	for _, tt := range tests {
+		if got := buildArticleSEO(tt.article); got != tt.want {
+			t.Errorf("%s: buildArticleSEO = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestArticleJSONLD(t *testing.T) {
+	withConfig(t, &config.Config{SiteURL: "https://example.com", SiteName: "Example", SiteLogoURL: "/logo.png"})
+	publisher := map[string]interface{}{
+		"@type": "Organization",
+		"name":  "Example",
+		"url":   "https://example.com",
+		"logo":  map[string]interface{}{"@type": "ImageObject", "url": "https://example.com/logo.png"},
+	}
+	published := time.Date(2026, 3, 14, 8, 0, 0, 0, time.UTC)
+	base := seoArticle{
+		Title:       "Cup final </script> " + strings.Repeat("a", 120),
+		Slug:        "cup-final",
+		Summary:     "S",
+		CoverImage:  "/uploads/final.jpg",
+		PublishedAt: published,
+		UpdatedAt:   published.Add(time.Hour),
+	}
+
+	tests := []struct {
+		name    string
+		article func(a *seoArticle)
+		check   map[string]interface{}
+		absent  []string
+	}{
+		{
+			name:    "no author, category or tags",
+			article: func(a *seoArticle) {},
+			check: map[string]interface{}{
+				"@type":         "NewsArticle",
+				"url":           "https://example.com/article/cup-final",
+				"image":         []interface{}{"https://example.com/uploads/final.jpg"},
+				"datePublished": "2026-03-14T08:00:00Z",
+				"dateModified":  "2026-03-14T09:00:00Z",
+				"author":        publisher,
+				"publisher":     publisher,
+			},
+			absent: []string{"articleSection", "keywords"},
+		},
+		{
+			name: "author, category and tags",
+			article: func(a *seoArticle) {
+				a.Author = map[string]interface{}{"name": "Lan", "slug": "lan", "avatar": "/uploads/lan.jpg"}
+				a.CategoryName = "Football"
+				a.Tags = []models.Tag{{Name: "Cup"}, {Name: "Final"}}
+			},
+			check: map[string]interface{}{
+				"author": map[string]interface{}{
+					"@type": "Person",
+					"name":  "Lan",
+					"url":   "https://example.com/author/lan",
+					"image": "https://example.com/uploads/lan.jpg",
+				},
+				"articleSection": "Football",
+				"keywords":       "Cup, Final",
+			},
+		},
+	}
+	for _, tt := range tests {
+		a := base
+		tt.article(&a)
+		out := articleJSONLD(a, buildArticleSEO(a))
+		if strings.Contains(out, "</script") {
+			t.Errorf("%s: JSON-LD is not safe to embed: %s", tt.name, out)
+		}
+
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(out), &data); err != nil {
+			t.Errorf("%s: invalid JSON %v: %s", tt.name, err, out)
+			continue
+		}
+		if headline, _ := data["headline"].(string); len([]rune(headline)) > maxHeadlineLength {
+			t.Errorf("%s: headline has %d characters", tt.name, len([]rune(headline)))
+		}
+		for key, want := range tt.check {
+			if !reflect.DeepEqual(data[key], want) {
+				t.Errorf("%s: %s = %#v, want %#v", tt.name, key, data[key], want)
+			}
+		}
+		for _, key := range tt.absent {
+			if _, ok := data[key]; ok {
+				t.Errorf("%s: unexpected %s %#v", tt.name, key, data[key])
+			}
+		}
+	}
+}
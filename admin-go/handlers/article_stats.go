@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"admin-go/models"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// topArticleSorts maps the sort parameter of the top articles report to its column
+var topArticleSorts = map[string]string{
+	"pageviews": "pv",
+	"readers":   "readers",
+	"read_time": "avg_read_time",
+	"scroll":    "avg_scroll_depth",
+}
+
+// GetArticleStats returns pageviews, unique readers, read time, scroll depth,
+// daily trend, traffic sources and devices of one article for a date range
+func GetArticleStats(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid article ID"})
+		return
+	}
+
+	var title, slug string
+	var viewCount int
+	err = models.DB.QueryRow(`
+		SELECT title, COALESCE(slug, ''), view_count FROM articles WHERE id = ?`, id).
+		Scan(&title, &slug, &viewCount)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
+		return
+	}
+
+	r, err := queryRange(c, 30)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	// Read time only counts views that reported a duration on leave, and
+	// scroll depth only views that reported a scroll position
+	var pageviews, readers, sessions, scrolled, reached25, reached50, reached75, reached100 int
+	var avgReadTime, avgScrollDepth float64
+	err = models.DB.QueryRow(`
+		SELECT COUNT(*), COUNT(DISTINCT visitor_id), COUNT(DISTINCT session_id),
+		       COALESCE(AVG(NULLIF(duration, 0)), 0), COALESCE(AVG(NULLIF(scroll_depth, 0)), 0),
+		       COALESCE(SUM(scroll_depth > 0), 0), COALESCE(SUM(scroll_depth >= 25), 0),
+		       COALESCE(SUM(scroll_depth >= 50), 0), COALESCE(SUM(scroll_depth >= 75), 0),
+		       COALESCE(SUM(scroll_depth >= 100), 0)
+		FROM page_views
+		WHERE article_id = ? AND viewed_at >= ? AND viewed_at < ?`, id, r.From, r.To).
+		Scan(&pageviews, &readers, &sessions, &avgReadTime, &avgScrollDepth,
+			&scrolled, &reached25, &reached50, &reached75, &reached100)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	// Daily trend, with empty days filled in
+	daily := map[string][2]int{}
+	local, localArgs := localTimeExpr("viewed_at", r.From, r.To)
+	trendRows, err := models.DB.Query(`
+		SELECT DATE_FORMAT(`+local+`, '%Y-%m-%d') as d,
+		       COUNT(*), COUNT(DISTINCT visitor_id)
+		FROM page_views
+		WHERE article_id = ? AND viewed_at >= ? AND viewed_at < ?
+		GROUP BY d`, append(localArgs, id, r.From, r.To)...)
+	if err == nil {
+		for trendRows.Next() {
+			var date string
+			var pv, uv int
+			if trendRows.Scan(&date, &pv, &uv) == nil {
+				daily[date] = [2]int{pv, uv}
+			}
+		}
+		trendRows.Close()
+	}
+	trend := []map[string]interface{}{}
+	for d := r.From; d.Before(r.To); d = d.AddDate(0, 0, 1) {
+		date := d.Format("2006-01-02")
+		trend = append(trend, map[string]interface{}{"date": date, "pv": daily[date][0], "uv": daily[date][1]})
+	}
+
+	sources := []map[string]interface{}{}
+	sourceRows, err := models.DB.Query(`
+		SELECT `+referrerSourceExpr+` as source, COUNT(*) as pv, COUNT(DISTINCT visitor_id) as uv
+		FROM page_views
+		WHERE article_id = ? AND viewed_at >= ? AND viewed_at < ?
+		GROUP BY source
+		ORDER BY pv DESC`, id, r.From, r.To)
+	if err == nil {
+		for sourceRows.Next() {
+			var source string
+			var pv, uv int
+			sourceRows.Scan(&source, &pv, &uv)
+			sources = append(sources, map[string]interface{}{"source": source, "pv": pv, "uv": uv})
+		}
+		sourceRows.Close()
+	}
+
+	devices := []map[string]interface{}{}
+	deviceRows, err := models.DB.Query(`
+		SELECT COALESCE(NULLIF(l.device_type, ''), 'Unknown') as device, COUNT(*) as pv, COUNT(DISTINCT p.visitor_id) as uv
+		FROM page_views p
+		LEFT JOIN visitor_logs l ON l.id = p.log_id
+		WHERE p.article_id = ? AND p.viewed_at >= ? AND p.viewed_at < ?
+		GROUP BY device
+		ORDER BY pv DESC`, id, r.From, r.To)
+	if err == nil {
+		for deviceRows.Next() {
+			var device string
+			var pv, uv int
+			deviceRows.Scan(&device, &pv, &uv)
+			devices = append(devices, map[string]interface{}{"device": device, "pv": pv, "uv": uv})
+		}
+		deviceRows.Close()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"article": gin.H{
+				"id":         id,
+				"title":      title,
+				"slug":       slug,
+				"view_count": viewCount,
+			},
+			"range": r.JSON(),
+			"summary": gin.H{
+				"pageviews":        pageviews,
+				"unique_readers":   readers,
+				"sessions":         sessions,
+				"avg_read_time":    int(avgReadTime + 0.5),
+				"avg_scroll_depth": int(avgScrollDepth + 0.5),
+			},
+			"scroll_depth": gin.H{
+				"reported":    scrolled,
+				"reached_25":  funnelRate(reached25, scrolled),
+				"reached_50":  funnelRate(reached50, scrolled),
+				"reached_75":  funnelRate(reached75, scrolled),
+				"reached_100": funnelRate(reached100, scrolled),
+			},
+			"trend":   trend,
+			"sources": sources,
+			"devices": devices,
+		},
+	})
+}
+
+// GetTopArticles ranks articles by pageviews, unique readers, read time or
+// scroll depth over a date range (sort=pageviews|readers|read_time|scroll)
+func GetTopArticles(c *gin.Context) {
+	sortColumn, ok := topArticleSorts[c.DefaultQuery("sort", "pageviews")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid sort column"})
+		return
+	}
+
+	r, err := queryRange(c, 30)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	articles, err := topArticles(r, sortColumn, c.Query("category_id"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"range":    r.JSON(),
+			"articles": articles,
+		},
+	})
+}
+
+// topArticles ranks the articles read in a range by one of topArticleSorts,
+// optionally within a category
+func topArticles(r statsRange, sortColumn, categoryId string, limit int) ([]map[string]interface{}, error) {
+	whereClause := "p.viewed_at >= ? AND p.viewed_at < ?"
+	args := []interface{}{r.From, r.To}
+	if categoryId != "" {
+		whereClause += " AND a.category_id = ?"
+		args = append(args, categoryId)
+	}
+
+	rows, err := models.DB.Query(`
+		SELECT a.id, a.title, COALESCE(a.slug, ''), COALESCE(c.name, ''),
+		       COUNT(*) as pv, COUNT(DISTINCT p.visitor_id) as readers,
+		       COALESCE(AVG(NULLIF(p.duration, 0)), 0) as avg_read_time,
+		       COALESCE(AVG(NULLIF(p.scroll_depth, 0)), 0) as avg_scroll_depth
+		FROM page_views p
+		JOIN articles a ON a.id = p.article_id
+		LEFT JOIN categories c ON c.id = a.category_id
+		WHERE `+whereClause+`
+		GROUP BY a.id, a.title, a.slug, c.name
+		ORDER BY `+sortColumn+` DESC, pv DESC
+		LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	articles := []map[string]interface{}{}
+	for rows.Next() {
+		var id int64
+		var title, slug, categoryName string
+		var pv, readers int
+		var avgReadTime, avgScrollDepth float64
+		if rows.Scan(&id, &title, &slug, &categoryName, &pv, &readers, &avgReadTime, &avgScrollDepth) != nil {
+			continue
+		}
+		articles = append(articles, map[string]interface{}{
+			"id":               id,
+			"title":            title,
+			"slug":             slug,
+			"category_name":    categoryName,
+			"pageviews":        pv,
+			"unique_readers":   readers,
+			"avg_read_time":    int(avgReadTime + 0.5),
+			"avg_scroll_depth": int(avgScrollDepth + 0.5),
+		})
+	}
+	return articles, nil
+}
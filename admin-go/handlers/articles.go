@@ -3,6 +3,7 @@ package handlers
 import (
 	"admin-go/models"
 	"encoding/json"
+	"log"
 	"net/http"
 	"regexp"
 	"strconv"
@@ -14,15 +15,26 @@ import (
 )
 
 type ArticleRequest struct {
-	Title         string `json:"title" binding:"required"`
-	Slug          string `json:"slug" binding:"required"`
-	Content       string `json:"content"`
-	ContentJson   string `json:"content_json"`
-	Summary       string `json:"summary"`
-	CoverImage    string `json:"cover_image"`
-	CategoryId    *int64 `json:"category_id"`
-	IsPublished   bool   `json:"is_published"`
-	IsRecommended bool   `json:"is_recommended"`
+	Title         string   `json:"title" binding:"required"`
+	Slug          string   `json:"slug" binding:"required"`
+	Content       string   `json:"content"`
+	ContentJson   string   `json:"content_json"`
+	Summary       string   `json:"summary"`
+	CoverImage    string   `json:"cover_image"`
+	CategoryId    *int64   `json:"category_id"`
+	IsPublished   bool     `json:"is_published"`
+	IsRecommended bool     `json:"is_recommended"`
+	PublishAt     string   `json:"publish_at"`
+	UnpublishAt   string   `json:"unpublish_at"`
+	Tags          []string `json:"tags"`      // tag names; missing tags are created
+	AuthorId      *int64   `json:"author_id"` // byline; defaults to the saving admin on create
+
+	// SEO overrides; empty fields fall back to the title, summary, article URL and cover
+	MetaTitle       string `json:"meta_title"`
+	MetaDescription string `json:"meta_description"`
+	CanonicalUrl    string `json:"canonical_url"`
+	OgImage         string `json:"og_image"`
+	Noindex         bool   `json:"noindex"`
 }
 
 // ImportArticleItem supports both old format (ContentHtml, CoverImageUrl) and new format
@@ -46,6 +58,8 @@ func GetArticles(c *gin.Context) {
 	categoryId := c.Query("category_id")
 	status := c.Query("status")
 	keyword := c.Query("keyword")
+	tagId := c.Query("tag_id")
+	authorId := c.Query("author_id")
 
 	if page < 1 {
 		page = 1
@@ -63,31 +77,76 @@ func GetArticles(c *gin.Context) {
 		args = append(args, categoryId)
 	}
 
+	if authorId != "" {
+		whereClause += " AND a.author_id = ?"
+		args = append(args, authorId)
+	}
+
+	if tagId != "" {
+		whereClause += " AND a.id IN (SELECT article_id FROM article_tags WHERE tag_id = ?)"
+		args = append(args, tagId)
+	}
+
 	if status == "published" {
 		whereClause += " AND a.is_published = 1"
 	} else if status == "draft" {
 		whereClause += " AND a.is_published = 0"
+	} else if status == "scheduled" {
+		whereClause += " AND (a.publish_at IS NOT NULL OR a.unpublish_at IS NOT NULL)"
 	}
 
+	// Keyword matches are ranked by relevance. The index matches whole
+	// words (the last one also as a prefix), not substrings; LIKE is the
+	// fallback until it is built. The filters above narrow the best
+	// maxSearchResults matches, and only the requested page is loaded.
+	orderBy := "a.created_at DESC"
+	var total int
+	searched := false
 	if keyword != "" {
-		whereClause += " AND (a.title LIKE ? OR a.summary LIKE ?)"
-		args = append(args, "%"+keyword+"%", "%"+keyword+"%")
+		if ids, ok := searchArticleIDs(keyword); !ok {
+			whereClause += " AND (a.title LIKE ? OR a.summary LIKE ?)"
+			args = append(args, "%"+keyword+"%", "%"+keyword+"%")
+		} else {
+			ranked, err := filterArticleIDs(ids, whereClause, args)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				return
+			}
+			total = len(ranked)
+			pageIDs := []int64{}
+			if offset < len(ranked) {
+				pageIDs = ranked[offset:min(offset+pageSize, len(ranked))]
+			}
+			whereClause, args = "1=0", nil
+			if len(pageIDs) > 0 {
+				placeholders, idList := idArgs(pageIDs)
+				whereClause = "a.id IN (" + placeholders + ")"
+				orderBy = "FIELD(a.id, " + placeholders + ")"
+				args = append(idList, idList...)
+			}
+			searched = true
+			offset = 0
+		}
 	}
 
 	// Get total count
-	var total int
-	countQuery := "SELECT COUNT(*) FROM articles a WHERE " + whereClause
-	models.DB.QueryRow(countQuery, args...).Scan(&total)
+	if !searched {
+		countQuery := "SELECT COUNT(*) FROM articles a WHERE " + whereClause
+		models.DB.QueryRow(countQuery, args...).Scan(&total)
+	}
 
 	// Get articles
 	query := `
 		SELECT a.id, a.title, a.slug, COALESCE(a.summary, ''), COALESCE(a.cover_image, ''),
 		       a.category_id, COALESCE(c.name, ''), a.is_published, a.is_recommended, 
-		       a.view_count, a.created_at, a.updated_at
+		       a.view_count, a.publish_at, a.unpublish_at, a.author_id, COALESCE(au.display_name, ''),
+		       a.last_editor_id, COALESCE(le.display_name, ''), a.created_at, a.updated_at
 		FROM articles a
 		LEFT JOIN categories c ON a.category_id = c.id
+		LEFT JOIN authors au ON a.author_id = au.id
+		LEFT JOIN authors le ON a.last_editor_id = le.id
 		WHERE ` + whereClause + `
-		ORDER BY a.created_at DESC
+		ORDER BY ` + orderBy + `
 		LIMIT ? OFFSET ?`
 
 	args = append(args, pageSize, offset)
@@ -104,11 +163,21 @@ func GetArticles(c *gin.Context) {
 		var categoryId *int64
 		rows.Scan(&a.ID, &a.Title, &a.Slug, &a.Summary, &a.CoverImage,
 			&categoryId, &a.CategoryName, &a.IsPublished, &a.IsRecommended,
-			&a.ViewCount, &a.CreatedAt, &a.UpdatedAt)
+			&a.ViewCount, &a.PublishAt, &a.UnpublishAt, &a.AuthorId, &a.AuthorName,
+			&a.LastEditorId, &a.LastEditorName, &a.CreatedAt, &a.UpdatedAt)
 		a.CategoryId = categoryId
 		articles = append(articles, a)
 	}
 
+	ids := make([]int64, len(articles))
+	for i, a := range articles {
+		ids[i] = a.ID
+	}
+	tags := loadArticleTags(ids)
+	for i := range articles {
+		articles[i].Tags = articleTagList(tags, articles[i].ID)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data": gin.H{
@@ -120,6 +189,41 @@ func GetArticles(c *gin.Context) {
 	})
 }
 
+// filterArticleIDs returns the articles of ids that match whereClause, in
+// the order of ids
+func filterArticleIDs(ids []int64, whereClause string, args []interface{}) ([]int64, error) {
+	if len(ids) == 0 {
+		return []int64{}, nil
+	}
+	placeholders, idList := idArgs(ids)
+	rows, err := models.DB.Query("SELECT a.id FROM articles a WHERE "+whereClause+" AND a.id IN ("+placeholders+")",
+		append(append([]interface{}{}, args...), idList...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	matched := map[int64]bool{}
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		matched[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	ranked := []int64{}
+	for _, id := range ids {
+		if matched[id] {
+			ranked = append(ranked, id)
+		}
+	}
+	return ranked, nil
+}
+
 func GetArticle(c *gin.Context) {
 	id := c.Param("id")
 
@@ -128,13 +232,20 @@ func GetArticle(c *gin.Context) {
 	err := models.DB.QueryRow(`
 		SELECT a.id, a.title, COALESCE(a.slug, ''), COALESCE(a.content, ''), COALESCE(a.content_json, ''), 
 		       COALESCE(a.summary, ''), COALESCE(a.cover_image, ''), a.category_id, COALESCE(c.name, ''), 
-		       a.is_published, a.is_recommended, a.view_count, a.created_at, a.updated_at
+		       a.is_published, a.is_recommended, a.view_count, a.publish_at, a.unpublish_at, a.published_at,
+		       a.author_id, COALESCE(au.display_name, ''), a.last_editor_id, COALESCE(le.display_name, ''),
+		       COALESCE(a.meta_title, ''), COALESCE(a.meta_description, ''), COALESCE(a.canonical_url, ''),
+		       COALESCE(a.og_image, ''), a.noindex, a.created_at, a.updated_at
 		FROM articles a
 		LEFT JOIN categories c ON a.category_id = c.id
+		LEFT JOIN authors au ON a.author_id = au.id
+		LEFT JOIN authors le ON a.last_editor_id = le.id
 		WHERE a.id = ?`, id).
 		Scan(&a.ID, &a.Title, &a.Slug, &a.Content, &a.ContentJson, &a.Summary, &a.CoverImage,
 			&categoryId, &a.CategoryName, &a.IsPublished, &a.IsRecommended,
-			&a.ViewCount, &a.CreatedAt, &a.UpdatedAt)
+			&a.ViewCount, &a.PublishAt, &a.UnpublishAt, &a.PublishedAt, &a.AuthorId, &a.AuthorName,
+			&a.LastEditorId, &a.LastEditorName, &a.MetaTitle, &a.MetaDescription, &a.CanonicalUrl,
+			&a.OgImage, &a.Noindex, &a.CreatedAt, &a.UpdatedAt)
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Article not found"})
@@ -142,6 +253,7 @@ func GetArticle(c *gin.Context) {
 	}
 
 	a.CategoryId = categoryId
+	a.Tags = articleTagList(loadArticleTags([]int64{a.ID}), a.ID)
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": a})
 }
 
@@ -167,12 +279,56 @@ func CreateArticle(c *gin.Context) {
 		return
 	}
 
-	result, err := models.DB.Exec(`
+	now := time.Now()
+	isPublished, publishAt, unpublishAt, err := articleSchedule(req, now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Tags) > maxArticleTags {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many tags"})
+		return
+	}
+	if err := validateArticleSEO(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.AuthorId != nil {
+		var exists int
+		if models.DB.QueryRow("SELECT 1 FROM authors WHERE id = ?", *req.AuthorId).Scan(&exists) != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Author not found"})
+			return
+		}
+	}
+	editorId := currentAuthorId(c)
+
+	authorId := req.AuthorId
+	if authorId == nil {
+		authorId = editorId
+	}
+
+	// Tags are created up front; the article, its tags and its first
+	// revision are stored together
+	tagIds, err := resolveTags(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tags"})
+		return
+	}
+	tx, err := models.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create article"})
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
 		INSERT INTO articles (title, slug, content, content_json, summary, cover_image, category_id, 
-		                      is_published, is_recommended, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		                      is_published, is_recommended, publish_at, unpublish_at, published_at, author_id, last_editor_id,
+		                      meta_title, meta_description, canonical_url, og_image, noindex, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		req.Title, req.Slug, req.Content, req.ContentJson, req.Summary, req.CoverImage, req.CategoryId,
-		req.IsPublished, req.IsRecommended, time.Now(), time.Now())
+		isPublished, req.IsRecommended, publishAt, unpublishAt, firstPublishedAt(isPublished, now), authorId, editorId,
+		req.MetaTitle, req.MetaDescription, req.CanonicalUrl, req.OgImage, req.Noindex, time.Now(), time.Now())
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create article"})
@@ -180,6 +336,21 @@ func CreateArticle(c *gin.Context) {
 	}
 
 	id, _ := result.LastInsertId()
+	if err := setArticleTags(tx, id, tagIds); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tags"})
+		return
+	}
+	if err := saveArticleRevision(tx, c, id, "create", nil); err != nil {
+		log.Printf("Article %d: saving revision failed: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create article"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create article"})
+		return
+	}
+	reindexArticles(id)
+
 	c.JSON(http.StatusOK, gin.H{"success": true, "id": id, "message": "Article created successfully"})
 }
 
@@ -215,20 +386,87 @@ func UpdateArticle(c *gin.Context) {
 		return
 	}
 
+	now := time.Now()
+	isPublished, publishAt, unpublishAt, err := articleSchedule(req, now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.Tags) > maxArticleTags {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Too many tags"})
+		return
+	}
+	if err := validateArticleSEO(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.AuthorId != nil {
+		var exists int
+		if models.DB.QueryRow("SELECT 1 FROM authors WHERE id = ?", *req.AuthorId).Scan(&exists) != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Author not found"})
+			return
+		}
+	}
+	editorId := currentAuthorId(c)
+
+	// Tags are only replaced when sent
+	var tagIds []int64
+	if req.Tags != nil {
+		if tagIds, err = resolveTags(req.Tags); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tags"})
+			return
+		}
+	}
+
+	// The update is stored together with its tags and revision, after the
+	// state before this first tracked edit
+	articleId, _ := strconv.ParseInt(id, 10, 64)
+	tx, err := models.DB.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update article"})
+		return
+	}
+	defer tx.Rollback()
+	if err := ensureBaselineRevision(tx, articleId); err != nil {
+		log.Printf("Article %d: saving baseline revision failed: %v", articleId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update article"})
+		return
+	}
+
 	// Update the article
-	_, err = models.DB.Exec(`
+	_, err = tx.Exec(`
 		UPDATE articles 
 		SET title = ?, slug = ?, content = ?, content_json = ?, summary = ?, cover_image = ?, category_id = ?,
-		    is_published = ?, is_recommended = ?, updated_at = ?
+		    is_published = ?, is_recommended = ?, publish_at = ?, unpublish_at = ?,
+		    published_at = COALESCE(published_at, ?),
+		    author_id = COALESCE(?, author_id), last_editor_id = ?,
+		    meta_title = ?, meta_description = ?, canonical_url = ?, og_image = ?, noindex = ?, updated_at = ?
 		WHERE id = ?`,
 		req.Title, req.Slug, req.Content, req.ContentJson, req.Summary, req.CoverImage, req.CategoryId,
-		req.IsPublished, req.IsRecommended, time.Now(), id)
+		isPublished, req.IsRecommended, publishAt, unpublishAt, firstPublishedAt(isPublished, now), req.AuthorId, editorId,
+		req.MetaTitle, req.MetaDescription, req.CanonicalUrl, req.OgImage, req.Noindex, time.Now(), id)
 
 	if err != nil {
 		// Log the actual error for debugging
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update article", "details": err.Error()})
 		return
 	}
+	if req.Tags != nil {
+		if err := setArticleTags(tx, articleId, tagIds); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save tags"})
+			return
+		}
+	}
+	if err := saveArticleRevision(tx, c, articleId, "update", nil); err != nil {
+		log.Printf("Article %d: saving revision failed: %v", articleId, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update article"})
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update article"})
+		return
+	}
+	reindexArticles(articleId)
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Article updated successfully"})
 }
@@ -241,6 +479,11 @@ func DeleteArticle(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete article"})
 		return
 	}
+	models.DB.Exec("DELETE FROM article_revisions WHERE article_id = ?", id)
+	models.DB.Exec("DELETE FROM article_tags WHERE article_id = ?", id)
+	if articleId, err := strconv.ParseInt(id, 10, 64); err == nil {
+		reindexArticles(articleId)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Article deleted successfully"})
 }
@@ -272,6 +515,9 @@ func BatchDeleteArticles(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete articles"})
 		return
 	}
+	models.DB.Exec("DELETE FROM article_revisions WHERE article_id IN ("+placeholders+")", args...)
+	models.DB.Exec("DELETE FROM article_tags WHERE article_id IN ("+placeholders+")", args...)
+	reindexArticles(req.IDs...)
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Articles deleted successfully"})
 }
@@ -299,11 +545,14 @@ func BatchUpdateArticleStatus(c *gin.Context) {
 		args = append(args, id)
 	}
 
-	_, err := models.DB.Exec("UPDATE articles SET is_published = ?, updated_at = CURRENT_TIMESTAMP WHERE id IN ("+placeholders+")", args...)
+	// Publishing or unpublishing by hand replaces a pending publish_at
+	args = append([]interface{}{firstPublishedAt(req.IsPublished, time.Now())}, args...)
+	_, err := models.DB.Exec("UPDATE articles SET published_at = COALESCE(published_at, ?), is_published = ?, publish_at = NULL, updated_at = CURRENT_TIMESTAMP WHERE id IN ("+placeholders+")", args...)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update articles"})
 		return
 	}
+	reindexArticles(req.IDs...)
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Articles updated successfully"})
 }
@@ -510,6 +759,8 @@ func ImportArticles(c *gin.Context) {
 	}
 
 	imported := 0
+	importedIds := []int64{}
+	importerId := currentAuthorId(c)
 	skipped := 0
 	errors := []string{}
 
@@ -535,22 +786,16 @@ func ImportArticles(c *gin.Context) {
 			continue
 		}
 
-		// Insert article
-		_, err = models.DB.Exec(`
-			INSERT INTO articles (title, slug, content, content_json, summary, cover_image, category_id, 
-			                      is_published, is_recommended, created_at, updated_at)
-			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-			article.Title, article.Slug, article.Content, article.ContentJson, article.Summary,
-			article.CoverImage, article.CategoryId, article.IsPublished, article.IsRecommended,
-			time.Now(), time.Now())
-
+		// Insert article with its first revision
+		id, err := importArticle(c, article, importerId)
 		if err != nil {
 			errors = append(errors, "Article "+strconv.Itoa(i+1)+": "+err.Error())
 			continue
 		}
-
+		importedIds = append(importedIds, id)
 		imported++
 	}
+	reindexArticles(importedIds...)
 
 	response := gin.H{
 		"success":  true,
@@ -566,6 +811,34 @@ func ImportArticles(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// importArticle stores one imported article and its "import" revision
+func importArticle(c *gin.Context, article ArticleRequest, importerId *int64) (int64, error) {
+	tx, err := models.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO articles (title, slug, content, content_json, summary, cover_image, category_id, 
+		                      is_published, is_recommended, published_at, author_id, last_editor_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		article.Title, article.Slug, article.Content, article.ContentJson, article.Summary,
+		article.CoverImage, article.CategoryId, article.IsPublished, article.IsRecommended,
+		firstPublishedAt(article.IsPublished, time.Now()), importerId, importerId, time.Now(), time.Now())
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := saveArticleRevision(tx, c, id, "import", nil); err != nil {
+		return 0, err
+	}
+	return id, tx.Commit()
+}
+
 func ExportArticleTemplate(c *gin.Context) {
 	f := excelize.NewFile()
 	defer f.Close()
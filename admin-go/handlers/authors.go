@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"admin-go/models"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthorRequest struct {
+	AdminId     *int64 `json:"admin_id"`
+	DisplayName string `json:"display_name" binding:"required"`
+	Slug        string `json:"slug"`
+	Avatar      string `json:"avatar"`
+	Bio         string `json:"bio"`
+}
+
+// validateAuthorRequest normalizes an author request, returning an error
+// message
+func validateAuthorRequest(req *AuthorRequest) string {
+	req.DisplayName = strings.TrimSpace(req.DisplayName)
+	if req.DisplayName == "" || len([]rune(req.DisplayName)) > 100 {
+		return "Display name must be 1-100 characters"
+	}
+	if req.Slug == "" {
+		req.Slug = generateTagSlug(req.DisplayName)
+	}
+	if !tagSlugPattern.MatchString(req.Slug) {
+		return "Slug can only contain lowercase letters, numbers, and hyphens"
+	}
+	if len([]rune(req.Bio)) > 2000 {
+		return "Bio must be at most 2000 characters"
+	}
+	if req.AdminId != nil {
+		var exists int
+		if models.DB.QueryRow("SELECT 1 FROM admins WHERE id = ?", *req.AdminId).Scan(&exists) != nil {
+			return "Admin user not found"
+		}
+	}
+	return ""
+}
+
+// currentAuthorId returns the author profile linked to the logged-in admin
+// (authors.admin_id), or nil when there is none. Profiles are only created
+// explicitly; the login name is never published as a byline.
+func currentAuthorId(c *gin.Context) *int64 {
+	adminId := c.GetInt64("user_id")
+	if adminId == 0 {
+		return nil
+	}
+	var id int64
+	if models.DB.QueryRow("SELECT id FROM authors WHERE admin_id = ?", adminId).Scan(&id) != nil {
+		return nil
+	}
+	return &id
+}
+
+func GetAuthors(c *gin.Context) {
+	rows, err := models.DB.Query(`
+		SELECT au.id, au.admin_id, au.display_name, au.slug, COALESCE(au.avatar, ''), COALESCE(au.bio, ''), au.created_at,
+		       (SELECT COUNT(*) FROM articles WHERE author_id = au.id) as article_count
+		FROM authors au
+		ORDER BY au.display_name`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	authors := []models.Author{}
+	for rows.Next() {
+		var a models.Author
+		rows.Scan(&a.ID, &a.AdminId, &a.DisplayName, &a.Slug, &a.Avatar, &a.Bio, &a.CreatedAt, &a.ArticleCount)
+		authors = append(authors, a)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": authors})
+}
+
+// GetMyAuthor returns the author profile linked to the logged-in admin
+func GetMyAuthor(c *gin.Context) {
+	id := currentAuthorId(c)
+	if id == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
+		return
+	}
+
+	var a models.Author
+	err := models.DB.QueryRow(`
+		SELECT id, admin_id, display_name, slug, COALESCE(avatar, ''), COALESCE(bio, ''), created_at
+		FROM authors WHERE id = ?`, *id).
+		Scan(&a.ID, &a.AdminId, &a.DisplayName, &a.Slug, &a.Avatar, &a.Bio, &a.CreatedAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": a})
+}
+
+func CreateAuthor(c *gin.Context) {
+	var req AuthorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if msg := validateAuthorRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var existingId int64
+	if models.DB.QueryRow("SELECT id FROM authors WHERE slug = ?", req.Slug).Scan(&existingId) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Slug already exists"})
+		return
+	}
+	if req.AdminId != nil && models.DB.QueryRow("SELECT id FROM authors WHERE admin_id = ?", *req.AdminId).Scan(&existingId) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Admin user already has an author profile"})
+		return
+	}
+
+	result, err := models.DB.Exec(`
+		INSERT INTO authors (admin_id, display_name, slug, avatar, bio, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		req.AdminId, req.DisplayName, req.Slug, req.Avatar, req.Bio, time.Now(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create author"})
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	c.JSON(http.StatusOK, gin.H{"success": true, "id": id, "slug": req.Slug, "message": "Author created successfully"})
+}
+
+func UpdateAuthor(c *gin.Context) {
+	id := c.Param("id")
+
+	var req AuthorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if msg := validateAuthorRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var existingId int64
+	if models.DB.QueryRow("SELECT id FROM authors WHERE slug = ? AND id != ?", req.Slug, id).Scan(&existingId) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Slug already exists"})
+		return
+	}
+	if req.AdminId != nil && models.DB.QueryRow("SELECT id FROM authors WHERE admin_id = ? AND id != ?", *req.AdminId, id).Scan(&existingId) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Admin user already has an author profile"})
+		return
+	}
+
+	result, err := models.DB.Exec(`
+		UPDATE authors SET admin_id = ?, display_name = ?, slug = ?, avatar = ?, bio = ?, updated_at = ?
+		WHERE id = ?`,
+		req.AdminId, req.DisplayName, req.Slug, req.Avatar, req.Bio, time.Now(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update author"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists int
+		if models.DB.QueryRow("SELECT 1 FROM authors WHERE id = ?", id).Scan(&exists) == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Author not found"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Author updated successfully"})
+}
+
+// DeleteAuthor deletes an author profile; their articles lose the byline
+func DeleteAuthor(c *gin.Context) {
+	id := c.Param("id")
+
+	_, err := models.DB.Exec("DELETE FROM authors WHERE id = ?", id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete author"})
+		return
+	}
+	models.DB.Exec("UPDATE articles SET author_id = NULL WHERE author_id = ?", id)
+	models.DB.Exec("UPDATE articles SET last_editor_id = NULL WHERE last_editor_id = ?", id)
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Author deleted successfully"})
+}
+
+// publicByline is the author shown on a public article, or nil
+func publicByline(name, slug, avatar, bio sql.NullString) map[string]interface{} {
+	if !name.Valid {
+		return nil
+	}
+	return map[string]interface{}{
+		"name":   name.String,
+		"slug":   slug.String,
+		"avatar": avatar.String,
+		"bio":    bio.String,
+	}
+}
+
+// GetPublicAuthorBySlug returns an author profile with their published
+// articles, newest first
+func GetPublicAuthorBySlug(c *gin.Context) {
+	if models.DB == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Author not found"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 50 {
+		pageSize = 10
+	}
+
+	var authorId int64
+	var name, slug, avatar, bio string
+	err := models.DB.QueryRow(`
+		SELECT id, display_name, slug, COALESCE(avatar, ''), COALESCE(bio, '')
+		FROM authors WHERE slug = ?`, c.Param("slug")).
+		Scan(&authorId, &name, &slug, &avatar, &bio)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Author not found"})
+		return
+	}
+
+	var total int
+	models.DB.QueryRow(`SELECT COUNT(*) FROM articles a WHERE a.author_id = ? AND `+publicArticleCondition, authorId).Scan(&total)
+
+	rows, err := models.DB.Query(`
+		SELECT a.id, a.title, a.slug, COALESCE(a.summary, ''), COALESCE(a.cover_image, ''),
+		       COALESCE(c.name, ''), COALESCE(c.slug, ''), a.view_count, a.created_at
+		FROM articles a
+		LEFT JOIN categories c ON a.category_id = c.id
+		WHERE a.author_id = ? AND `+publicArticleCondition+`
+		ORDER BY a.created_at DESC
+		LIMIT ? OFFSET ?`, authorId, pageSize, (page-1)*pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	articles := []map[string]interface{}{}
+	for rows.Next() {
+		var id int64
+		var title, articleSlug, summary, coverImage, categoryName, categorySlug string
+		var viewCount int
+		var createdAt time.Time
+		if rows.Scan(&id, &title, &articleSlug, &summary, &coverImage, &categoryName, &categorySlug, &viewCount, &createdAt) != nil {
+			continue
+		}
+		articles = append(articles, map[string]interface{}{
+			"id":            id,
+			"title":         title,
+			"slug":          articleSlug,
+			"summary":       summary,
+			"cover_image":   coverImage,
+			"category_name": categoryName,
+			"category_slug": categorySlug,
+			"view_count":    viewCount,
+			"created_at":    createdAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"author": gin.H{
+				"name":          name,
+				"slug":          slug,
+				"avatar":        avatar,
+				"bio":           bio,
+				"article_count": total,
+			},
+			"articles": articles,
+		},
+		"total":     total,
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
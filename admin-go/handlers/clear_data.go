@@ -0,0 +1,674 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"admin-go/models"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// clearTokenTTL is how long a dry run's confirmation token stays valid
+const clearTokenTTL = 10 * time.Minute
+
+// clearFirstSeenBatch is the number of visitors whose first visit is
+// rebuilt per query after a scoped clear
+const clearFirstSeenBatch = 500
+
+// clearVisitBatch is the number of visits updated or deleted per query
+// after a path-scoped clear
+const clearVisitBatch = 500
+
+// ClearVisitorsRequest selects the analytics data to delete. Filters are
+// combined with AND; All must be set to delete everything.
+type ClearVisitorsRequest struct {
+	From         string `json:"from"`
+	To           string `json:"to"`
+	IpAddress    string `json:"ip_address"`
+	VisitorId    string `json:"visitor_id"`
+	Path         string `json:"path"`
+	All          bool   `json:"all"`
+	DryRun       bool   `json:"dry_run"`
+	ConfirmToken string `json:"confirm_token"`
+}
+
+// clearTokens holds the pending confirmation tokens of dry runs, each bound
+// to the scope it was issued for
+var clearTokens = struct {
+	sync.Mutex
+	pending map[string]clearToken
+}{pending: map[string]clearToken{}}
+
+type clearToken struct {
+	scope     string
+	expiresAt time.Time
+}
+
+// clearScope is a validated ClearVisitorsRequest as SQL conditions
+type clearScope struct {
+	from, to *time.Time
+	req      ClearVisitorsRequest
+}
+
+func (req *ClearVisitorsRequest) normalize() {
+	req.From = strings.TrimSpace(req.From)
+	req.To = strings.TrimSpace(req.To)
+	req.IpAddress = strings.TrimSpace(req.IpAddress)
+	req.VisitorId = strings.TrimSpace(req.VisitorId)
+	req.Path = strings.TrimSpace(req.Path)
+}
+
+// key identifies the scope a confirmation token is valid for
+func (req ClearVisitorsRequest) key() string {
+	req.DryRun = false
+	req.ConfirmToken = ""
+	b, _ := json.Marshal(req)
+	return string(b)
+}
+
+func newClearScope(req ClearVisitorsRequest) (clearScope, error) {
+	scope := clearScope{req: req}
+	hasFilter := req.From != "" || req.To != "" || req.IpAddress != "" || req.VisitorId != "" || req.Path != ""
+	if req.All && hasFilter {
+		return scope, errors.New("'all' cannot be combined with filters")
+	}
+	if !req.All && !hasFilter {
+		return scope, errors.New("at least one of from, to, ip_address, visitor_id or path is required (or all)")
+	}
+	if req.IpAddress != "" && req.VisitorId == "" && !ipIdentifiesVisitor() {
+		return scope, errors.New("IP addresses are stored truncated (PRIVACY_IP_MODE=truncate) and shared by other visitors; combine ip_address with visitor_id")
+	}
+
+	loc := config.GetReportLocation()
+	if req.From != "" {
+		day, err := dateRange(req.From, loc)
+		if err != nil {
+			return scope, err
+		}
+		scope.from = &day.From
+	}
+	if req.To != "" {
+		day, err := dateRange(req.To, loc)
+		if err != nil {
+			return scope, err
+		}
+		scope.to = &day.To
+	}
+	if scope.from != nil && scope.to != nil && !scope.to.After(*scope.from) {
+		return scope, errors.New("'to' must not be before 'from'")
+	}
+	return scope, nil
+}
+
+// pathPattern turns a path with * wildcards into a LIKE pattern
+func pathPattern(path string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`, `*`, `%`)
+	return replacer.Replace(path)
+}
+
+// where builds the condition on a table with the given time column. Tables
+// without ip_address are linked to the visitor_logs rows of the IP through
+// ipColumn (log_id or visitor_id). An empty condition means a path filter was
+// given for a table without page_path, which is then not affected.
+func (s clearScope) where(timeColumn string, hasPath bool, ipColumn string) (string, []interface{}) {
+	conditions := []string{}
+	args := []interface{}{}
+
+	if s.from != nil {
+		conditions = append(conditions, timeColumn+" >= ?")
+		args = append(args, *s.from)
+	}
+	if s.to != nil {
+		conditions = append(conditions, timeColumn+" < ?")
+		args = append(args, *s.to)
+	}
+	if s.req.VisitorId != "" {
+		conditions = append(conditions, "visitor_id = ?")
+		args = append(args, s.req.VisitorId)
+	}
+	if s.req.IpAddress != "" {
+		// In truncate mode the visitor_id condition above narrows the
+		// network-wide match down to one visitor
+		ipWhere := "ip_address IN (?, ?)"
+		ipArgs := []interface{}{s.req.IpAddress, anonymizeIP(s.req.IpAddress)}
+		switch ipColumn {
+		case "":
+			conditions = append(conditions, ipWhere)
+		case "log_id":
+			conditions = append(conditions, "log_id IN (SELECT id FROM visitor_logs WHERE "+ipWhere+")")
+		default:
+			conditions = append(conditions, ipColumn+" IN (SELECT "+ipColumn+" FROM visitor_logs WHERE "+ipWhere+")")
+		}
+		args = append(args, ipArgs...)
+	}
+	if s.req.Path != "" {
+		if !hasPath {
+			return "", nil
+		}
+		conditions = append(conditions, "page_path LIKE ?")
+		args = append(args, pathPattern(s.req.Path))
+	}
+	if len(conditions) == 0 {
+		return "1 = 1", args
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// visitWhere is the condition on the visitor_logs rows in scope. With a
+// path these are the visits with page views in scope, which only lose those
+// page views (see pathVisits).
+func (s clearScope) visitWhere() (string, []interface{}) {
+	if s.req.Path == "" {
+		return s.where("visit_time", true, "")
+	}
+	pageWhere, pageArgs := s.where("viewed_at", true, "log_id")
+	return "id IN (SELECT log_id FROM page_views WHERE " + pageWhere + ")", pageArgs
+}
+
+// clearTargets returns the condition for every table a scoped clear deletes
+// from; an empty condition means the table is not affected. Without a path,
+// page views also follow the visitor_logs rows they belong to; with a path,
+// visitor_logs rows are updated or deleted through pathVisits instead.
+func (s clearScope) clearTargets() []struct {
+	table string
+	where string
+	args  []interface{}
+} {
+	logWhere, logArgs := s.where("visit_time", true, "")
+	pageWhere, pageArgs := s.where("viewed_at", true, "log_id")
+	if s.req.Path == "" {
+		pageWhere = "(" + pageWhere + ") OR log_id IN (SELECT id FROM visitor_logs WHERE " + logWhere + ")"
+		pageArgs = append(pageArgs, logArgs...)
+	} else {
+		logWhere, logArgs = "", nil
+	}
+	eventWhere, eventArgs := s.where("created_at", true, "visitor_id")
+	liveWhere, liveArgs := s.where("last_seen_at", false, "visitor_id")
+
+	return []struct {
+		table string
+		where string
+		args  []interface{}
+	}{
+		{"page_views", pageWhere, pageArgs},
+		{"visitor_events", eventWhere, eventArgs},
+		{"live_viewers", liveWhere, liveArgs},
+		{"visitor_logs", logWhere, logArgs},
+	}
+}
+
+// affectedDates lists the reporting days of the visitor_logs rows in scope
+func (s clearScope) affectedDates() ([]string, error) {
+	where, args := s.visitWhere()
+	return visitorLogDates(where, args)
+}
+
+// affectedVisitors lists the visitor_ids with page views in scope, whose
+// first visit must be rebuilt
+func (s clearScope) affectedVisitors() ([]string, error) {
+	where, args := s.where("viewed_at", true, "log_id")
+	logWhere, logArgs := s.visitWhere()
+	rows, err := models.DB.Query(`
+		SELECT visitor_id FROM page_views WHERE visitor_id IS NOT NULL AND visitor_id != '' AND (`+where+`)
+		UNION
+		SELECT visitor_id FROM visitor_logs WHERE visitor_id IS NOT NULL AND visitor_id != '' AND (`+logWhere+`)`,
+		append(args, logArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	visitors := []string{}
+	for rows.Next() {
+		var visitorId string
+		if rows.Scan(&visitorId) == nil {
+			visitors = append(visitors, visitorId)
+		}
+	}
+	return visitors, rows.Err()
+}
+
+// clearQueryer is models.DB or a transaction
+type clearQueryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// pathVisits splits the visits with page views in a path scope into those
+// left without page views, which are deleted, and those keeping some, whose
+// counters are rebuilt by rebuildPathVisits. It must run before the page
+// views are deleted.
+func (s clearScope) pathVisits(q clearQueryer) (emptied, changed []int64, err error) {
+	pageWhere, pageArgs := s.where("viewed_at", true, "log_id")
+	rows, err := q.Query(`
+		SELECT log_id, SUM(CASE WHEN `+pageWhere+` THEN 0 ELSE 1 END)
+		FROM page_views
+		WHERE log_id IN (SELECT log_id FROM page_views WHERE `+pageWhere+`)
+		GROUP BY log_id`, append(append([]interface{}{}, pageArgs...), pageArgs...)...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var logID, remaining int64
+		if err := rows.Scan(&logID, &remaining); err != nil {
+			return nil, nil, err
+		}
+		if remaining == 0 {
+			emptied = append(emptied, logID)
+		} else {
+			changed = append(changed, logID)
+		}
+	}
+	return emptied, changed, rows.Err()
+}
+
+// idArgs returns the placeholders and arguments of an IN list of IDs
+func idArgs(ids []int64) (string, []interface{}) {
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", "), args
+}
+
+// deleteVisits deletes visitor_logs rows by ID
+func deleteVisits(tx *sql.Tx, ids []int64) error {
+	for start := 0; start < len(ids); start += clearVisitBatch {
+		end := min(start+clearVisitBatch, len(ids))
+		placeholders, args := idArgs(ids[start:end])
+		if _, err := tx.Exec("DELETE FROM visitor_logs WHERE id IN ("+placeholders+")", args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildPathVisits recomputes the page view count and visited pages of
+// visits from their remaining page views, and moves a landing page that
+// was cleared to the first page view left
+func rebuildPathVisits(tx *sql.Tx, ids []int64, path string) error {
+	type visit struct {
+		count                          int
+		pages                          []string
+		seen                           map[string]bool
+		landing, pageType, referenceId string
+	}
+	pattern := pathPattern(path)
+
+	for start := 0; start < len(ids); start += clearVisitBatch {
+		end := min(start+clearVisitBatch, len(ids))
+		placeholders, args := idArgs(ids[start:end])
+		rows, err := tx.Query(`
+			SELECT log_id, page_path, COALESCE(page_type, ''), COALESCE(reference_id, '')
+			FROM page_views
+			WHERE log_id IN (`+placeholders+`)
+			ORDER BY log_id, viewed_at, id`, args...)
+		if err != nil {
+			return err
+		}
+		visits := map[int64]*visit{}
+		for rows.Next() {
+			var logID int64
+			var pagePath, pageType, referenceId string
+			if err := rows.Scan(&logID, &pagePath, &pageType, &referenceId); err != nil {
+				rows.Close()
+				return err
+			}
+			v := visits[logID]
+			if v == nil {
+				v = &visit{seen: map[string]bool{}, landing: pagePath, pageType: pageType, referenceId: referenceId}
+				visits[logID] = v
+			}
+			v.count++
+			if !v.seen[pagePath] {
+				v.seen[pagePath] = true
+				v.pages = append(v.pages, pagePath)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for logID, v := range visits {
+			visitedPages, _ := json.Marshal(v.pages)
+			// page_path is assigned last: MySQL applies the assignments in
+			// order, so the conditions still see the cleared landing page
+			_, err := tx.Exec(`
+				UPDATE visitor_logs
+				SET page_view_count = ?,
+				    visited_pages = ?,
+				    page_type = IF(page_path LIKE ?, ?, page_type),
+				    reference_id = IF(page_path LIKE ?, ?, reference_id),
+				    page_path = IF(page_path LIKE ?, ?, page_path)
+				WHERE id = ?`,
+				v.count, string(visitedPages),
+				pattern, v.pageType, pattern, v.referenceId, pattern, v.landing,
+				logID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// rebuildFirstSeen recomputes the first visit of visitors from the data
+// left after a clear, dropping visitors with nothing left
+func rebuildFirstSeen(tx statsExecer, visitors []string) error {
+	for start := 0; start < len(visitors); start += clearFirstSeenBatch {
+		end := start + clearFirstSeenBatch
+		if end > len(visitors) {
+			end = len(visitors)
+		}
+		batch := visitors[start:end]
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(batch)), ", ")
+		args := make([]interface{}, len(batch))
+		for i, v := range batch {
+			args[i] = v
+		}
+
+		if _, err := tx.Exec("DELETE FROM visitor_first_seen WHERE visitor_id IN ("+placeholders+")", args...); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+			INSERT IGNORE INTO visitor_first_seen (visitor_id, first_seen_at)
+			SELECT visitor_id, MIN(seen_at) FROM (
+				SELECT visitor_id, visit_time as seen_at FROM visitor_logs WHERE visitor_id IN (`+placeholders+`)
+				UNION ALL
+				SELECT visitor_id, viewed_at FROM page_views WHERE visitor_id IN (`+placeholders+`)
+			) seen
+			GROUP BY visitor_id`, append(args, args...)...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClearAllVisitors deletes visitor analytics data in a scope (date range, IP,
+// visitor_id and/or path with * wildcards, or all). A dry run returns the
+// rows that would be deleted and a confirmation token; the deletion itself
+// requires that token for the same scope. The rollups and daily_stats of the
+// affected days are rebuilt afterwards.
+func ClearAllVisitors(c *gin.Context) {
+	var req ClearVisitorsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request"})
+		return
+	}
+	req.normalize()
+
+	scope, err := newClearScope(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	if req.DryRun {
+		previewClear(c, scope)
+		return
+	}
+
+	if !useClearToken(req) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "A valid confirm_token is required; run a dry run of the same scope first"})
+		return
+	}
+
+	if req.All {
+		clearEverything(c)
+		return
+	}
+
+	data, err := clearScoped(scope)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to clear visitor logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": data, "message": "Visitor records cleared"})
+}
+
+// clearScoped deletes the rows in scope and rebuilds the first visits and
+// aggregates of what is left, all in one transaction so a failure leaves
+// the data untouched. Deletes run in batches of RETENTION_BATCH_SIZE rows.
+func clearScoped(scope clearScope) (gin.H, error) {
+	affectedDates, err := scope.affectedDates()
+	if err != nil {
+		return nil, err
+	}
+	visitors, err := scope.affectedVisitors()
+	if err != nil {
+		return nil, err
+	}
+	logWhere, logArgs := scope.visitWhere()
+	ips, err := visitorLogIPs(logWhere, logArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	batchSize := config.GetRetentionConfig().RetentionBatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	tx, err := models.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var emptied, changed []int64
+	if scope.req.Path != "" {
+		if emptied, changed, err = scope.pathVisits(tx); err != nil {
+			return nil, err
+		}
+	}
+
+	deleted := gin.H{}
+	for _, target := range scope.clearTargets() {
+		if target.where == "" {
+			deleted[target.table] = 0
+			continue
+		}
+		args := append(append([]interface{}{}, target.args...), batchSize)
+		var total int64
+		for {
+			result, err := tx.Exec("DELETE FROM "+target.table+" WHERE "+target.where+" LIMIT ?", args...)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", target.table, err)
+			}
+			n, _ := result.RowsAffected()
+			total += n
+			if n < int64(batchSize) {
+				break
+			}
+		}
+		deleted[target.table] = total
+	}
+
+	if scope.req.Path != "" {
+		if err := deleteVisits(tx, emptied); err != nil {
+			return nil, fmt.Errorf("visitor_logs: %v", err)
+		}
+		if err := rebuildPathVisits(tx, changed, scope.req.Path); err != nil {
+			return nil, fmt.Errorf("visitor_logs: %v", err)
+		}
+		deleted["visitor_logs"] = len(emptied)
+	}
+
+	if err := rebuildFirstSeen(tx, visitors); err != nil {
+		return nil, err
+	}
+	loc := config.GetReportLocation()
+	for _, date := range affectedDates {
+		day, err := dateRange(date, loc)
+		if err != nil {
+			return nil, err
+		}
+		if err := recomputeStatsDayTx(tx, day); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	if err := forgetGeoLocations(ips); err != nil {
+		log.Printf("Clear visitors: geolocation cache not cleared: %v", err)
+	}
+
+	return gin.H{
+		"deleted":          deleted,
+		"visits_updated":   len(changed),
+		"affected_dates":   affectedDates,
+		"visitors_rebuilt": len(visitors),
+	}, nil
+}
+
+// previewClear counts the rows a clear would delete and issues its
+// confirmation token
+func previewClear(c *gin.Context, scope clearScope) {
+	counts := gin.H{}
+	affectedDates := []string{}
+	visitors := 0
+	visitsUpdated := 0
+
+	for _, target := range scope.clearTargets() {
+		if target.where == "" {
+			counts[target.table] = 0
+			continue
+		}
+		var n int64
+		if err := models.DB.QueryRow("SELECT COUNT(*) FROM "+target.table+" WHERE "+target.where, target.args...).Scan(&n); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Database error"})
+			return
+		}
+		counts[target.table] = n
+	}
+
+	if scope.req.Path != "" {
+		emptied, changed, err := scope.pathVisits(models.DB)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Database error"})
+			return
+		}
+		counts["visitor_logs"] = len(emptied)
+		visitsUpdated = len(changed)
+	}
+
+	if !scope.req.All {
+		dates, err := scope.affectedDates()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Database error"})
+			return
+		}
+		affectedDates = dates
+		ids, err := scope.affectedVisitors()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Database error"})
+			return
+		}
+		visitors = len(ids)
+	}
+
+	token := uuid.New().String()
+	expiresAt := time.Now().Add(clearTokenTTL)
+	clearTokens.Lock()
+	for t, pending := range clearTokens.pending {
+		if time.Now().After(pending.expiresAt) {
+			delete(clearTokens.pending, t)
+		}
+	}
+	clearTokens.pending[token] = clearToken{scope: scope.req.key(), expiresAt: expiresAt}
+	clearTokens.Unlock()
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"dry_run":          true,
+			"all":              scope.req.All,
+			"counts":           counts,
+			"visits_updated":   visitsUpdated,
+			"affected_dates":   affectedDates,
+			"visitors_rebuilt": visitors,
+			"confirm_token":    token,
+			"expires_at":       expiresAt,
+		},
+	})
+}
+
+// useClearToken consumes the confirmation token of a request; it is valid
+// once, until it expires, and only for the scope it was issued for
+func useClearToken(req ClearVisitorsRequest) bool {
+	clearTokens.Lock()
+	defer clearTokens.Unlock()
+
+	pending, ok := clearTokens.pending[req.ConfirmToken]
+	if !ok {
+		return false
+	}
+	delete(clearTokens.pending, req.ConfirmToken)
+	return pending.scope == req.key() && time.Now().Before(pending.expiresAt)
+}
+
+// clearEverything deletes all visitor logs, their aggregates and live
+// match audiences
+func clearEverything(c *gin.Context) {
+	// Delete all visitor logs, their page views and custom events
+	_, err := models.DB.Exec("DELETE FROM page_views")
+	if err == nil {
+		_, err = models.DB.Exec("DELETE FROM visitor_events")
+	}
+	if err == nil {
+		_, err = models.DB.Exec("DELETE FROM visitor_first_seen")
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to clear visitor logs"})
+		return
+	}
+	_, err = models.DB.Exec("DELETE FROM visitor_logs")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to clear visitor logs"})
+		return
+	}
+
+	// Delete all daily stats and rollups
+	for _, table := range []string{"daily_stats", "stats_daily", "stats_hourly"} {
+		if _, err = models.DB.Exec("DELETE FROM " + table); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to clear daily stats"})
+			return
+		}
+	}
+
+	// Delete cached geolocations, which are keyed by IP
+	if err = forgetAllGeoLocations(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to clear visitor logs"})
+		return
+	}
+
+	// Delete realtime stats and live match audiences
+	_, err = models.DB.Exec("DELETE FROM realtime_stats")
+	if err == nil {
+		_, err = models.DB.Exec("DELETE FROM live_viewers")
+	}
+	if err == nil {
+		_, err = models.DB.Exec("DELETE FROM live_samples")
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to clear realtime stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "All visitor records cleared"})
+}
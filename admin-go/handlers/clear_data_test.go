@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewClearScope(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		req     ClearVisitorsRequest
+		wantErr bool
+	}{
+		{"everything", "none", ClearVisitorsRequest{All: true}, false},
+		{"no scope", "none", ClearVisitorsRequest{}, true},
+		{"all with a filter", "none", ClearVisitorsRequest{All: true, Path: "/news"}, true},
+		{"date range", "none", ClearVisitorsRequest{From: "2026-03-01", To: "2026-03-31"}, false},
+		{"single day", "none", ClearVisitorsRequest{From: "2026-03-01", To: "2026-03-01"}, false},
+		{"to before from", "none", ClearVisitorsRequest{From: "2026-03-02", To: "2026-03-01"}, true},
+		{"malformed date", "none", ClearVisitorsRequest{From: "03/01/2026"}, true},
+		{"IP", "none", ClearVisitorsRequest{IpAddress: "203.0.113.7"}, false},
+		{"truncated IP alone", "truncate", ClearVisitorsRequest{IpAddress: "203.0.113.7"}, true},
+		{"truncated IP with visitor_id", "truncate", ClearVisitorsRequest{IpAddress: "203.0.113.7", VisitorId: "v1"}, false},
+	}
+	for _, tt := range tests {
+		withConfig(t, &config.Config{PrivacyIPMode: tt.mode})
+		if _, err := newClearScope(tt.req); (err != nil) != tt.wantErr {
+			t.Errorf("%s: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPathPattern(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/news", "/news"},
+		{"/news/*", "/news/%"},
+		{"*/amp", "%/amp"},
+		{"/100%_real", `/100\%\_real`},
+		{`/a\b`, `/a\\b`},
+	}
+	for _, tt := range tests {
+		if got := pathPattern(tt.path); got != tt.want {
+			t.Errorf("pathPattern(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestClearScopeWhere(t *testing.T) {
+	withConfig(t, nil)
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC)
+	ipIn := "ip_address IN (?, ?)"
+
+	tests := []struct {
+		name       string
+		req        ClearVisitorsRequest
+		timeColumn string
+		hasPath    bool
+		ipColumn   string
+		wantWhere  string
+		wantArgs   []interface{}
+	}{
+		{
+			name:       "everything",
+			req:        ClearVisitorsRequest{All: true},
+			timeColumn: "visit_time", hasPath: true,
+			wantWhere: "1 = 1",
+			wantArgs:  []interface{}{},
+		},
+		{
+			name:       "date range",
+			req:        ClearVisitorsRequest{From: "2026-03-01", To: "2026-03-02"},
+			timeColumn: "viewed_at", hasPath: true, ipColumn: "log_id",
+			wantWhere: "viewed_at >= ? AND viewed_at < ?",
+			wantArgs:  []interface{}{from, to},
+		},
+		{
+			name:       "visitor and IP on visitor_logs",
+			req:        ClearVisitorsRequest{VisitorId: "v1", IpAddress: "203.0.113.7"},
+			timeColumn: "visit_time", hasPath: true,
+			wantWhere: "visitor_id = ? AND " + ipIn,
+			wantArgs:  []interface{}{"v1", "203.0.113.7", "203.0.113.7"},
+		},
+		{
+			name:       "IP through log_id",
+			req:        ClearVisitorsRequest{IpAddress: "203.0.113.7"},
+			timeColumn: "viewed_at", hasPath: true, ipColumn: "log_id",
+			wantWhere: "log_id IN (SELECT id FROM visitor_logs WHERE " + ipIn + ")",
+			wantArgs:  []interface{}{"203.0.113.7", "203.0.113.7"},
+		},
+		{
+			name:       "IP through visitor_id",
+			req:        ClearVisitorsRequest{IpAddress: "203.0.113.7"},
+			timeColumn: "created_at", hasPath: true, ipColumn: "visitor_id",
+			wantWhere: "visitor_id IN (SELECT visitor_id FROM visitor_logs WHERE " + ipIn + ")",
+			wantArgs:  []interface{}{"203.0.113.7", "203.0.113.7"},
+		},
+		{
+			name:       "path",
+			req:        ClearVisitorsRequest{From: "2026-03-01", Path: "/news/*"},
+			timeColumn: "viewed_at", hasPath: true, ipColumn: "log_id",
+			wantWhere: "viewed_at >= ? AND page_path LIKE ?",
+			wantArgs:  []interface{}{from, "/news/%"},
+		},
+		{
+			name:       "path on a table without page_path",
+			req:        ClearVisitorsRequest{Path: "/news/*"},
+			timeColumn: "last_seen_at", hasPath: false, ipColumn: "visitor_id",
+			wantWhere: "",
+			wantArgs:  nil,
+		},
+	}
+	for _, tt := range tests {
+		scope, err := newClearScope(tt.req)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		where, args := scope.where(tt.timeColumn, tt.hasPath, tt.ipColumn)
+		if where != tt.wantWhere || !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("%s: where = %q %v, want %q %v", tt.name, where, args, tt.wantWhere, tt.wantArgs)
+		}
+	}
+}
+
+func TestClearTargets(t *testing.T) {
+	withConfig(t, nil)
+	tests := []struct {
+		name string
+		req  ClearVisitorsRequest
+		want map[string]string
+	}{
+		{
+			name: "visitor",
+			req:  ClearVisitorsRequest{VisitorId: "v1"},
+			want: map[string]string{
+				"page_views":     "(visitor_id = ?) OR log_id IN (SELECT id FROM visitor_logs WHERE visitor_id = ?)",
+				"visitor_events": "visitor_id = ?",
+				"live_viewers":   "visitor_id = ?",
+				"visitor_logs":   "visitor_id = ?",
+			},
+		},
+		{
+			// Visits only lose their matching page views, so visitor_logs is
+			// left to pathVisits and live viewers have no path to match
+			name: "path",
+			req:  ClearVisitorsRequest{VisitorId: "v1", Path: "/news/*"},
+			want: map[string]string{
+				"page_views":     "visitor_id = ? AND page_path LIKE ?",
+				"visitor_events": "visitor_id = ? AND page_path LIKE ?",
+				"live_viewers":   "",
+				"visitor_logs":   "",
+			},
+		},
+	}
+	for _, tt := range tests {
+		scope, err := newClearScope(tt.req)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.name, err)
+			continue
+		}
+		got := map[string]string{}
+		for _, target := range scope.clearTargets() {
+			got[target.table] = target.where
+			if target.where == "" && len(target.args) != 0 {
+				t.Errorf("%s: %s has args %v without a condition", tt.name, target.table, target.args)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: clearTargets = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
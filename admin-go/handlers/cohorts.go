@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"admin-go/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// cohortBucketExpr returns the SQL grouping a UTC timestamp column between
+// from and to into the YYYY-MM-DD start of its day or ISO week (Monday) in
+// the reporting timezone
+func cohortBucketExpr(column, granularity string, from, to time.Time) (string, []interface{}) {
+	local, args := localTimeExpr(column, from, to)
+	if granularity == "week" {
+		return "DATE_FORMAT(DATE_SUB(DATE(" + local + "), INTERVAL WEEKDAY(" + local + ") DAY), '%Y-%m-%d')",
+			append(append([]interface{}{}, args...), args...)
+	}
+	return "DATE_FORMAT(" + local + ", '%Y-%m-%d')", args
+}
+
+// GetRetentionCohorts returns the cohort matrix: for visitors first seen in
+// each day/week of the range, the share that came back in each following period.
+// Visitors are identified by visitor_id.
+func GetRetentionCohorts(c *gin.Context) {
+	granularity := c.DefaultQuery("granularity", "week")
+	if granularity != "week" && granularity != "day" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "granularity must be day or week"})
+		return
+	}
+
+	loc, err := reportLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	now := time.Now().In(loc)
+
+	periodDays := 1
+	periods := 14
+	if granularity == "week" {
+		periodDays = 7
+		periods = 8
+	}
+	if p, err := strconv.Atoi(c.Query("periods")); err == nil && p > 0 && p <= 52 {
+		periods = p
+	}
+
+	r := dayRange(now, periods*periodDays)
+	if c.Query("from") != "" || c.Query("to") != "" {
+		r, err = parseDateRange(c.Query("from"), c.Query("to"), now)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+	}
+	// Cohorts start on a Monday for weekly reports
+	if granularity == "week" {
+		offset := (int(r.From.Weekday()) + 6) % 7
+		r.From = r.From.AddDate(0, 0, -offset)
+	}
+
+	cohortStarts := []time.Time{}
+	for d := r.From; d.Before(r.To); d = d.AddDate(0, 0, periodDays) {
+		cohortStarts = append(cohortStarts, d)
+	}
+	activityEnd := cohortStarts[len(cohortStarts)-1].AddDate(0, 0, periods*periodDays)
+	if activityEnd.After(now) {
+		activityEnd = now
+	}
+	bucketEnd := r.To
+	if activityEnd.After(bucketEnd) {
+		bucketEnd = activityEnd
+	}
+
+	// Cohort sizes
+	sizes := map[string]int{}
+	bucket, bucketArgs := cohortBucketExpr("first_seen_at", granularity, r.From, bucketEnd)
+	rows, err := models.DB.Query(`
+		SELECT `+bucket+` as cohort, COUNT(*)
+		FROM visitor_first_seen
+		WHERE first_seen_at >= ? AND first_seen_at < ?
+		GROUP BY cohort`, append(bucketArgs, r.From, r.To)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	for rows.Next() {
+		var cohort string
+		var size int
+		rows.Scan(&cohort, &size)
+		sizes[cohort] = size
+	}
+	rows.Close()
+
+	// Active cohort members per (cohort, period), from page views and visits
+	cohortBucket, cohortArgs := cohortBucketExpr("f.first_seen_at", granularity, r.From, bucketEnd)
+	activityBucket, activityArgs := cohortBucketExpr("a.t", granularity, r.From, bucketEnd)
+	args := append(cohortArgs, activityArgs...)
+	args = append(args, r.From, activityEnd, r.From, activityEnd, r.From, r.To)
+	rows, err = models.DB.Query(`
+		SELECT `+cohortBucket+` as cohort, `+activityBucket+` as period, COUNT(DISTINCT f.visitor_id)
+		FROM visitor_first_seen f
+		JOIN (
+			SELECT visitor_id, viewed_at as t FROM page_views WHERE viewed_at >= ? AND viewed_at < ?
+			UNION ALL
+			SELECT visitor_id, visit_time as t FROM visitor_logs WHERE visit_time >= ? AND visit_time < ?
+		) a ON a.visitor_id = f.visitor_id
+		WHERE f.first_seen_at >= ? AND f.first_seen_at < ?
+		GROUP BY cohort, period`, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	active := map[string]map[int]int{}
+	for rows.Next() {
+		var cohort, period string
+		var visitors int
+		if rows.Scan(&cohort, &period, &visitors) != nil {
+			continue
+		}
+		cohortStart, err1 := time.ParseInLocation("2006-01-02", cohort, loc)
+		periodStart, err2 := time.ParseInLocation("2006-01-02", period, loc)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		index := int(periodStart.Sub(cohortStart).Hours()/24+0.5) / periodDays
+		if index < 0 || index > periods {
+			continue
+		}
+		if active[cohort] == nil {
+			active[cohort] = map[int]int{}
+		}
+		active[cohort][index] = visitors
+	}
+
+	// Build the matrix; periods that have not started yet are null
+	cohorts := []gin.H{}
+	returned := make([]int, periods+1)
+	eligible := make([]int, periods+1)
+	for _, start := range cohortStarts {
+		key := start.Format("2006-01-02")
+		size := sizes[key]
+
+		retention := []gin.H{}
+		for k := 0; k <= periods; k++ {
+			if start.AddDate(0, 0, k*periodDays).After(now) {
+				retention = append(retention, gin.H{"period": k, "visitors": nil, "rate": nil})
+				continue
+			}
+			visitors := active[key][k]
+			if k == 0 {
+				visitors = size
+			}
+			retention = append(retention, gin.H{"period": k, "visitors": visitors, "rate": funnelRate(visitors, size)})
+			returned[k] += visitors
+			eligible[k] += size
+		}
+
+		cohorts = append(cohorts, gin.H{
+			"cohort":    key,
+			"size":      size,
+			"retention": retention,
+		})
+	}
+
+	average := []gin.H{}
+	for k := 0; k <= periods; k++ {
+		average = append(average, gin.H{"period": k, "rate": funnelRate(returned[k], eligible[k])})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"granularity": granularity,
+			"periods":     periods,
+			"range":       r.JSON(),
+			"cohorts":     cohorts,
+			"average":     average,
+		},
+	})
+}
@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCohortBucketExpr(t *testing.T) {
+	saigon, err := time.LoadLocation("Asia/Ho_Chi_Minh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := "CONVERT_TZ(first_seen, '+00:00', ?)"
+
+	tests := []struct {
+		name        string
+		granularity string
+		from, to    time.Time
+		wantExpr    string
+		wantArgs    []interface{}
+	}{
+		{
+			name:        "day",
+			granularity: "day",
+			from:        time.Date(2026, 9, 1, 0, 0, 0, 0, saigon),
+			to:          time.Date(2026, 10, 1, 0, 0, 0, 0, saigon),
+			wantExpr:    "DATE_FORMAT(" + local + ", '%Y-%m-%d')",
+			wantArgs:    []interface{}{"+07:00"},
+		},
+		{
+			name:        "week",
+			granularity: "week",
+			from:        time.Date(2026, 9, 1, 0, 0, 0, 0, saigon),
+			to:          time.Date(2026, 10, 1, 0, 0, 0, 0, saigon),
+			wantExpr:    "DATE_FORMAT(DATE_SUB(DATE(" + local + "), INTERVAL WEEKDAY(" + local + ") DAY), '%Y-%m-%d')",
+			wantArgs:    []interface{}{"+07:00", "+07:00"},
+		},
+		{
+			name:        "week across a DST change",
+			granularity: "week",
+			from:        time.Date(2026, 10, 1, 0, 0, 0, 0, newYork),
+			to:          time.Date(2026, 12, 1, 0, 0, 0, 0, newYork),
+			wantExpr: "DATE_FORMAT(DATE_SUB(DATE(CONVERT_TZ(first_seen, '+00:00', CASE WHEN first_seen < ? THEN ? ELSE ? END)), " +
+				"INTERVAL WEEKDAY(CONVERT_TZ(first_seen, '+00:00', CASE WHEN first_seen < ? THEN ? ELSE ? END)) DAY), '%Y-%m-%d')",
+			wantArgs: []interface{}{
+				time.Date(2026, 11, 1, 6, 0, 0, 0, time.UTC), "-04:00", "-05:00",
+				time.Date(2026, 11, 1, 6, 0, 0, 0, time.UTC), "-04:00", "-05:00",
+			},
+		},
+	}
+	for _, tt := range tests {
+		expr, args := cohortBucketExpr("first_seen", tt.granularity, tt.from, tt.to)
+		if expr != tt.wantExpr {
+			t.Errorf("%s: expr = %q, want %q", tt.name, expr, tt.wantExpr)
+		}
+		if len(args) != len(tt.wantArgs) {
+			t.Errorf("%s: args = %v, want %v", tt.name, args, tt.wantArgs)
+			continue
+		}
+		for i := range args {
+			if at, ok := args[i].(time.Time); ok {
+				if want, ok := tt.wantArgs[i].(time.Time); !ok || !at.Equal(want) {
+					t.Errorf("%s: arg %d = %v, want %v", tt.name, i, args[i], tt.wantArgs[i])
+				}
+			} else if !reflect.DeepEqual(args[i], tt.wantArgs[i]) {
+				t.Errorf("%s: arg %d = %v, want %v", tt.name, i, args[i], tt.wantArgs[i])
+			}
+		}
+	}
+}
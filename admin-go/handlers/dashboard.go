@@ -1,119 +1,284 @@
 package handlers
 
 import (
+	"admin-go/config"
 	"admin-go/models"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"log"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func GetDashboard(c *gin.Context) {
-	today := time.Now().Format("2006-01-02")
-	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+// dashboardRecentVisitors is the number of latest visits on the dashboard
+const dashboardRecentVisitors = 10
+
+type dashboardToday struct {
+	PV  int64 `json:"pv"`
+	UV  int64 `json:"uv"`
+	IPs int64 `json:"ips"`
+}
+
+type dashboardDay struct {
+	PV int64 `json:"pv"`
+	UV int64 `json:"uv"`
+}
+
+type dashboardAlerts struct {
+	Open   int            `json:"open"`
+	Recent []models.Alert `json:"recent"`
+}
 
-	// Today's stats - PV is sum of page_view_count, UV is count of unique IPs
-	var todayPV int
-	models.DB.QueryRow(`SELECT COALESCE(SUM(page_view_count), 0) FROM visitor_logs WHERE DATE(visit_time) = ?`, today).Scan(&todayPV)
+// dashboardPayload is the data returned by GET /api/dashboard
+type dashboardPayload struct {
+	Today             dashboardToday           `json:"today"`
+	Yesterday         dashboardDay             `json:"yesterday"`
+	RealtimeOnline    int                      `json:"realtime_online"`
+	TotalArticles     int                      `json:"total_articles"`
+	PublishedArticles int                      `json:"published_articles"`
+	TotalCategories   int                      `json:"total_categories"`
+	BlacklistCount    int                      `json:"blacklist_count"`
+	Trends            []map[string]interface{} `json:"trends"`
+	RecentVisitors    []map[string]interface{} `json:"recent_visitors"`
+	Alerts            dashboardAlerts          `json:"alerts"`
+	GeneratedAt       time.Time                `json:"generated_at"`
 
-	// UV = unique IPs (count of distinct IP addresses)
-	var todayUV int
-	models.DB.QueryRow(`SELECT COUNT(DISTINCT ip_address) FROM visitor_logs WHERE DATE(visit_time) = ?`, today).Scan(&todayUV)
+	date string // reporting day "today" refers to
+}
+
+// dashboardSnapshot holds the dashboard of the reporting timezone. It is
+// rebuilt every DASHBOARD_REFRESH_INTERVAL seconds and kept current in
+// between by the tracking pipeline; the JSON body and its ETag are
+// re-encoded only when the payload changed.
+var dashboardSnapshot struct {
+	sync.Mutex
+	payload     *dashboardPayload
+	version     int64
+	body        []byte
+	etag        string
+	bodyVersion int64
+}
+
+// dashboardRefreshMu serializes snapshot rebuilds done on request
+var dashboardRefreshMu sync.Mutex
 
-	// Unique IPs is same as UV for today
-	todayIPs := todayUV
+// StartDashboardSnapshots rebuilds the dashboard snapshot in the background
+func StartDashboardSnapshots() {
+	interval := time.Duration(config.GetStatsConfig().DashboardRefreshInterval) * time.Second
+	if interval <= 0 {
+		log.Println("Dashboard snapshots disabled (built per request)")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if models.DB != nil {
+				refreshDashboardSnapshot()
+			}
+			<-ticker.C
+		}
+	}()
+}
 
-	// Yesterday's stats
-	var yesterdayPV int
-	models.DB.QueryRow(`SELECT COALESCE(SUM(page_view_count), 0) FROM visitor_logs WHERE DATE(visit_time) = ?`, yesterday).Scan(&yesterdayPV)
-	var yesterdayUV int
-	models.DB.QueryRow(`SELECT COUNT(DISTINCT ip_address) FROM visitor_logs WHERE DATE(visit_time) = ?`, yesterday).Scan(&yesterdayUV)
+// refreshDashboardSnapshot replaces the snapshot with a freshly built one
+func refreshDashboardSnapshot() {
+	payload := buildDashboard(reportNow())
+
+	dashboardSnapshot.Lock()
+	dashboardSnapshot.payload = payload
+	dashboardSnapshot.version++
+	dashboardSnapshot.Unlock()
+}
+
+// buildDashboard queries every dashboard figure for the day of now
+func buildDashboard(now time.Time) *dashboardPayload {
+	today := dayRange(now, 1)
+	yesterday := dayRange(now.AddDate(0, 0, -1), 1)
+
+	d := &dashboardPayload{
+		Trends:         []map[string]interface{}{},
+		RecentVisitors: []map[string]interface{}{},
+		GeneratedAt:    time.Now(),
+		date:           today.FromDate(),
+	}
+
+	// Today's PV/UV come from the raw logs so they are live (and the tracking
+	// pipeline can count on top of them); PV is the sum of page_view_count and
+	// UV the count of unique visitors (see visitorColumn)
+	models.DB.QueryRow(`
+		SELECT COALESCE(SUM(page_view_count), 0), COUNT(DISTINCT `+visitorColumn()+`)
+		FROM visitor_logs
+		WHERE visit_time >= ? AND visit_time < ?`, today.From, today.To).Scan(&d.Today.PV, &d.Today.UV)
+	d.Today.IPs = d.Today.UV
+	yesterdayStats := getVisitorSummary(yesterday)
+	d.Yesterday = dashboardDay{PV: yesterdayStats.PV, UV: yesterdayStats.UV}
 
 	// Total articles
-	var totalArticles, publishedArticles int
-	models.DB.QueryRow("SELECT COUNT(*) FROM articles").Scan(&totalArticles)
-	models.DB.QueryRow("SELECT COUNT(*) FROM articles WHERE is_published = 1").Scan(&publishedArticles)
+	models.DB.QueryRow("SELECT COUNT(*) FROM articles").Scan(&d.TotalArticles)
+	models.DB.QueryRow("SELECT COUNT(*) FROM articles WHERE is_published = 1").Scan(&d.PublishedArticles)
 
 	// Total categories
-	var totalCategories int
-	models.DB.QueryRow("SELECT COUNT(*) FROM categories WHERE is_enabled = 1").Scan(&totalCategories)
+	models.DB.QueryRow("SELECT COUNT(*) FROM categories WHERE is_enabled = 1").Scan(&d.TotalCategories)
 
 	// Blacklist count
-	var blacklistCount int
-	models.DB.QueryRow("SELECT COUNT(*) FROM ip_blacklist").Scan(&blacklistCount)
+	models.DB.QueryRow("SELECT COUNT(*) FROM ip_blacklist").Scan(&d.BlacklistCount)
 
 	// Real-time online (visitors in last 5 minutes)
-	var realtimeOnline int
-	fiveMinutesAgo := time.Now().Add(-5 * time.Minute).Format("2006-01-02 15:04:05")
 	models.DB.QueryRow(`
-		SELECT COUNT(DISTINCT visitor_id) FROM visitor_logs 
-		WHERE last_visit_time >= ?`, fiveMinutesAgo).Scan(&realtimeOnline)
-
-	// Last 7 days trend - Calculate from visitor_logs directly
-	var trends []map[string]interface{}
-	sevenDaysAgo := time.Now().AddDate(0, 0, -7).Format("2006-01-02")
-	rows, _ := models.DB.Query(`
-		SELECT DATE(visit_time) as date, 
-		       SUM(page_view_count) as pv, 
-		       COUNT(DISTINCT ip_address) as uv 
-		FROM visitor_logs 
-		WHERE DATE(visit_time) >= ? 
-		GROUP BY DATE(visit_time)
-		ORDER BY date`, sevenDaysAgo)
-	defer rows.Close()
-
-	for rows.Next() {
-		var date string
-		var pv, uv int
-		rows.Scan(&date, &pv, &uv)
-		trends = append(trends, map[string]interface{}{
-			"date": date,
-			"pv":   pv,
-			"uv":   uv,
-		})
-	}
+		SELECT COUNT(DISTINCT visitor_id) FROM visitor_logs
+		WHERE last_visit_time >= ?`, now.Add(-5*time.Minute)).Scan(&d.RealtimeOnline)
+
+	// Unacknowledged traffic alerts
+	models.DB.QueryRow("SELECT COUNT(*) FROM alerts WHERE acknowledged = 0").Scan(&d.Alerts.Open)
+	d.Alerts.Recent, _ = loadAlerts("acknowledged = 0", 5)
+
+	// Last 7 days trend, with today's live figures
+	d.Trends = getTrend(dayRange(now, 8))
+	d.setTodayTrend()
 
 	// Recent visitors (last 10)
-	var recentVisitors []map[string]interface{}
-	visitorRows, _ := models.DB.Query(`
-		SELECT ip_address, page_path, device_type, browser, visit_time 
-		FROM visitor_logs 
-		ORDER BY visit_time DESC 
-		LIMIT 10`)
-	defer visitorRows.Close()
-
-	for visitorRows.Next() {
-		var ip, path, device, browser string
-		var visitTime time.Time
-		visitorRows.Scan(&ip, &path, &device, &browser, &visitTime)
-		recentVisitors = append(recentVisitors, map[string]interface{}{
-			"ip":         ip,
-			"page":       path,
-			"device":     device,
-			"browser":    browser,
-			"visit_time": visitTime,
-		})
+	visitorRows, err := models.DB.Query(`
+		SELECT ip_address, page_path, device_type, browser, visit_time
+		FROM visitor_logs
+		ORDER BY visit_time DESC
+		LIMIT ?`, dashboardRecentVisitors)
+	if err == nil {
+		defer visitorRows.Close()
+		for visitorRows.Next() {
+			var ip, path, device, browser string
+			var visitTime time.Time
+			if visitorRows.Scan(&ip, &path, &device, &browser, &visitTime) != nil {
+				continue
+			}
+			d.RecentVisitors = append(d.RecentVisitors, dashboardVisitor(ip, path, device, browser, visitTime))
+		}
+	}
+
+	return d
+}
+
+func dashboardVisitor(ip, path, device, browser string, visitTime time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"ip":         ip,
+		"page":       path,
+		"device":     device,
+		"browser":    browser,
+		"visit_time": visitTime,
+	}
+}
+
+// setTodayTrend puts today's PV/UV into the trend
+func (d *dashboardPayload) setTodayTrend() {
+	for _, point := range d.Trends {
+		if point["date"] == d.date {
+			point["pv"] = d.Today.PV
+			point["uv"] = d.Today.UV
+			return
+		}
+	}
+	d.Trends = append(d.Trends, map[string]interface{}{"date": d.date, "pv": d.Today.PV, "uv": d.Today.UV})
+}
+
+// dashboardPageView counts a tracked pageview into the snapshot. A new visit
+// (the first visitor_logs row of an IP today) also adds a unique visitor and
+// a recent visitor; other figures wait for the next rebuild.
+func dashboardPageView(now time.Time, newVisit bool, ip string, req models.TrackingRequest) {
+	dashboardSnapshot.Lock()
+	defer dashboardSnapshot.Unlock()
+
+	d := dashboardSnapshot.payload
+	if d == nil || d.date != now.Format("2006-01-02") {
+		return
+	}
+
+	d.Today.PV++
+	if newVisit {
+		d.Today.UV++
+		d.Today.IPs = d.Today.UV
+
+		recent := []map[string]interface{}{dashboardVisitor(ip, req.PagePath, req.DeviceType, req.Browser, now)}
+		for _, v := range d.RecentVisitors {
+			if len(recent) == dashboardRecentVisitors {
+				break
+			}
+			recent = append(recent, v)
+		}
+		d.RecentVisitors = recent
 	}
+	d.setTodayTrend()
+	dashboardSnapshot.version++
+}
+
+// dashboardBody returns the encoded snapshot and its ETag, building the
+// snapshot first when there is none for today or it is older than twice the
+// refresh interval (the background rebuild is disabled or stuck)
+func dashboardBody() ([]byte, string) {
+	now := reportNow()
+	maxAge := 2 * time.Duration(config.GetStatsConfig().DashboardRefreshInterval) * time.Second
+
+	dashboardSnapshot.Lock()
+	d := dashboardSnapshot.payload
+	stale := d == nil || d.date != now.Format("2006-01-02") || time.Since(d.GeneratedAt) > maxAge
+	dashboardSnapshot.Unlock()
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data": gin.H{
-			"today": gin.H{
-				"pv":  todayPV,
-				"uv":  todayUV,
-				"ips": todayIPs,
-			},
-			"yesterday": gin.H{
-				"pv": yesterdayPV,
-				"uv": yesterdayUV,
-			},
-			"realtime_online":    realtimeOnline,
-			"total_articles":     totalArticles,
-			"published_articles": publishedArticles,
-			"total_categories":   totalCategories,
-			"blacklist_count":    blacklistCount,
-			"trends":             trends,
-			"recent_visitors":    recentVisitors,
-		},
-	})
+	if stale {
+		// Concurrent requests wait for a single rebuild
+		dashboardRefreshMu.Lock()
+		dashboardSnapshot.Lock()
+		d = dashboardSnapshot.payload
+		stale = d == nil || d.date != now.Format("2006-01-02") || time.Since(d.GeneratedAt) > maxAge
+		dashboardSnapshot.Unlock()
+		if stale {
+			refreshDashboardSnapshot()
+		}
+		dashboardRefreshMu.Unlock()
+	}
+
+	dashboardSnapshot.Lock()
+	defer dashboardSnapshot.Unlock()
+	if dashboardSnapshot.body == nil || dashboardSnapshot.bodyVersion != dashboardSnapshot.version {
+		dashboardSnapshot.body, dashboardSnapshot.etag = encodeDashboard(dashboardSnapshot.payload)
+		dashboardSnapshot.bodyVersion = dashboardSnapshot.version
+	}
+	return dashboardSnapshot.body, dashboardSnapshot.etag
+}
+
+func encodeDashboard(d *dashboardPayload) ([]byte, string) {
+	body, _ := json.Marshal(gin.H{"success": true, "data": d})
+	sum := sha1.Sum(body)
+	return body, `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+// GetDashboard serves the dashboard snapshot with an ETag, answering 304 Not
+// Modified when it did not change. Other timezones (?tz=) are built per
+// request.
+func GetDashboard(c *gin.Context) {
+	loc, err := reportLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	var body []byte
+	var etag string
+	if loc.String() == config.GetReportLocation().String() {
+		body, etag = dashboardBody()
+	} else {
+		body, etag = encodeDashboard(buildDashboard(time.Now().In(loc)))
+	}
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	if match := c.GetHeader("If-None-Match"); match != "" && strings.Contains(match, etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
 }
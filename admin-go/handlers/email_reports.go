@@ -0,0 +1,440 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"admin-go/models"
+	"bytes"
+	"database/sql"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReportSubscriptionRequest struct {
+	Email     string `json:"email" binding:"required"`
+	Frequency string `json:"frequency"`
+	SendHour  *int   `json:"send_hour"`
+	Weekday   int    `json:"weekday"`
+	IsEnabled *bool  `json:"is_enabled"`
+}
+
+// normalize validates the request and fills in the defaults: daily at 07:00,
+// weekly reports on Monday (weekday 1 = Monday ... 7 = Sunday)
+func (r *ReportSubscriptionRequest) normalize() error {
+	addr, err := mail.ParseAddress(strings.TrimSpace(r.Email))
+	if err != nil {
+		return fmt.Errorf("invalid email address")
+	}
+	r.Email = strings.ToLower(addr.Address)
+
+	if r.Frequency == "" {
+		r.Frequency = "daily"
+	}
+	if r.Frequency != "daily" && r.Frequency != "weekly" {
+		return fmt.Errorf("frequency must be daily or weekly")
+	}
+	if r.SendHour == nil {
+		hour := 7
+		r.SendHour = &hour
+	}
+	if *r.SendHour < 0 || *r.SendHour > 23 {
+		return fmt.Errorf("send_hour must be between 0 and 23")
+	}
+	if r.Weekday == 0 {
+		r.Weekday = 1
+	}
+	if r.Weekday < 1 || r.Weekday > 7 {
+		return fmt.Errorf("weekday must be between 1 (Monday) and 7 (Sunday)")
+	}
+	if r.IsEnabled == nil {
+		enabled := true
+		r.IsEnabled = &enabled
+	}
+	return nil
+}
+
+// reportPeriod returns the period a report sent at now covers: yesterday for
+// daily reports, the 7 days up to yesterday for weekly ones
+func reportPeriod(frequency string, now time.Time) statsRange {
+	if frequency == "weekly" {
+		return dayRange(now.AddDate(0, 0, -1), 7)
+	}
+	return dayRange(now.AddDate(0, 0, -1), 1)
+}
+
+// StartReportScheduler checks every minute for subscriptions whose report is
+// due and emails them
+func StartReportScheduler() {
+	if !config.GetMailConfig().EmailReportsEnabled {
+		log.Println("Scheduled email reports disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			if models.DB == nil {
+				continue
+			}
+			sendDueReports(reportNow())
+		}
+	}()
+}
+
+// sendDueReports emails every enabled subscription whose send hour (and
+// weekday) has passed and that has not received the current period yet
+func sendDueReports(now time.Time) {
+	subscriptions, err := loadReportSubscriptions("is_enabled = 1")
+	if err != nil {
+		log.Printf("Email reports: %v", err)
+		return
+	}
+
+	weekday := (int(now.Weekday())+6)%7 + 1
+	rendered := map[string]*emailReport{}
+	for _, sub := range subscriptions {
+		if now.Hour() < sub.SendHour || (sub.Frequency == "weekly" && weekday != sub.Weekday) {
+			continue
+		}
+		period := reportPeriod(sub.Frequency, now)
+		if sub.LastPeriod == period.FromDate() {
+			continue
+		}
+
+		// Subscribers of the same report share one rendering; a report
+		// that failed to build is retried on the next tick
+		report, ok := rendered[sub.Frequency]
+		if !ok {
+			report, err = buildEmailReport(sub.Frequency, period)
+			if err != nil {
+				log.Printf("Email reports: %s report: %v", sub.Frequency, err)
+			}
+			rendered[sub.Frequency] = report
+		}
+		if report == nil {
+			continue
+		}
+
+		if err := sendMail(mailMessage{To: []string{sub.Email}, Subject: report.Subject, Body: report.HTML, HTML: true}); err != nil {
+			log.Printf("Email report to %s failed: %v", sub.Email, err)
+			continue
+		}
+		models.DB.Exec("UPDATE report_subscriptions SET last_period = ?, last_sent_at = ? WHERE id = ?",
+			period.FromDate(), time.Now(), sub.ID)
+	}
+}
+
+type emailReport struct {
+	Subject string
+	HTML    string
+}
+
+// emailReportData is what the report template renders
+type emailReportData struct {
+	Title       string
+	Period      string
+	Summary     visitorSummary
+	Deltas      map[string]metricDelta
+	TopArticles []map[string]interface{}
+	TopPages    []map[string]interface{}
+	Countries   []map[string]interface{}
+	Sources     []map[string]interface{}
+}
+
+// buildEmailReport renders the report of a period with the same summary and
+// breakdown queries as the visitor stats page, compared to the period before
+func buildEmailReport(frequency string, period statsRange) (*emailReport, error) {
+	previous := statsRange{From: period.From.AddDate(0, 0, -period.Days()), To: period.From}
+	summary := getVisitorSummary(period)
+
+	articles, err := topArticles(period, "pv", "", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	data := emailReportData{
+		Title:       "Daily analytics report",
+		Period:      period.FromDate(),
+		Summary:     summary,
+		Deltas:      summary.compare(getVisitorSummary(previous)),
+		TopArticles: articles,
+		TopPages:    dimensionBreakdown(period, "page", "page", "pv", 10),
+		Countries:   dimensionBreakdown(period, "country", "country", "uv", 10),
+		Sources:     dimensionBreakdown(period, "referrer", "source", "uv", 0),
+	}
+	if frequency == "weekly" {
+		data.Title = "Weekly analytics report"
+		data.Period = period.FromDate() + " – " + period.ToDate()
+	}
+
+	var buf bytes.Buffer
+	if err := emailReportTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return &emailReport{
+		Subject: fmt.Sprintf("%s %s: %d PV, %d UV", data.Title, data.Period, summary.PV, summary.UV),
+		HTML:    buf.String(),
+	}, nil
+}
+
+var emailReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"change": func(d metricDelta) template.HTML {
+		if d.ChangePct == nil {
+			return ""
+		}
+		color, sign := "#c0392b", ""
+		if *d.ChangePct >= 0 {
+			color, sign = "#27ae60", "+"
+		}
+		return template.HTML(fmt.Sprintf(`<span style="color:%s">%s%.1f%%</span>`, color, sign, *d.ChangePct))
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family:Arial,sans-serif;color:#333;max-width:640px;margin:0 auto">
+<h2 style="color:#4472C4">{{.Title}}</h2>
+<p>{{.Period}}</p>
+
+<table cellpadding="8" style="border-collapse:collapse;width:100%">
+<tr style="background:#f4f6fa">
+<td><b>Pageviews</b><br>{{.Summary.PV}} {{change (index .Deltas "pv")}}</td>
+<td><b>Unique visitors</b><br>{{.Summary.UV}} {{change (index .Deltas "uv")}}</td>
+<td><b>New visitors</b><br>{{.Summary.NewVisitors}} {{change (index .Deltas "new_visitors")}}</td>
+<td><b>Avg. duration</b><br>{{.Summary.AvgDuration}}s {{change (index .Deltas "avg_duration")}}</td>
+</tr>
+</table>
+
+<h3>Top articles</h3>
+<table cellpadding="6" style="border-collapse:collapse;width:100%">
+<tr style="background:#4472C4;color:#fff"><th align="left">Article</th><th align="right">PV</th><th align="right">Readers</th><th align="right">Read time</th></tr>
+{{range .TopArticles}}<tr style="border-bottom:1px solid #eee"><td>{{.title}}</td><td align="right">{{.pageviews}}</td><td align="right">{{.unique_readers}}</td><td align="right">{{.avg_read_time}}s</td></tr>
+{{else}}<tr><td colspan="4">No article views</td></tr>
+{{end}}</table>
+
+<h3>Top pages</h3>
+<table cellpadding="6" style="border-collapse:collapse;width:100%">
+<tr style="background:#4472C4;color:#fff"><th align="left">Page</th><th align="right">PV</th><th align="right">UV</th></tr>
+{{range .TopPages}}<tr style="border-bottom:1px solid #eee"><td>{{.page}}</td><td align="right">{{.pv}}</td><td align="right">{{.uv}}</td></tr>
+{{end}}</table>
+
+<h3>Top countries</h3>
+<table cellpadding="6" style="border-collapse:collapse;width:100%">
+<tr style="background:#4472C4;color:#fff"><th align="left">Country</th><th align="right">UV</th><th align="right">PV</th></tr>
+{{range .Countries}}<tr style="border-bottom:1px solid #eee"><td>{{.country}}</td><td align="right">{{.uv}}</td><td align="right">{{.pv}}</td></tr>
+{{end}}</table>
+
+<h3>Traffic sources</h3>
+<table cellpadding="6" style="border-collapse:collapse;width:100%">
+<tr style="background:#4472C4;color:#fff"><th align="left">Source</th><th align="right">UV</th><th align="right">PV</th></tr>
+{{range .Sources}}<tr style="border-bottom:1px solid #eee"><td>{{.source}}</td><td align="right">{{.uv}}</td><td align="right">{{.pv}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func loadReportSubscriptions(whereClause string, args ...interface{}) ([]models.ReportSubscription, error) {
+	rows, err := models.DB.Query(`
+		SELECT id, email, frequency, send_hour, weekday, is_enabled, COALESCE(last_period, ''),
+		       last_sent_at, created_at, updated_at
+		FROM report_subscriptions
+		WHERE `+whereClause+`
+		ORDER BY frequency, email`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	subscriptions := []models.ReportSubscription{}
+	for rows.Next() {
+		var s models.ReportSubscription
+		if rows.Scan(&s.ID, &s.Email, &s.Frequency, &s.SendHour, &s.Weekday, &s.IsEnabled,
+			&s.LastPeriod, &s.LastSentAt, &s.CreatedAt, &s.UpdatedAt) == nil {
+			subscriptions = append(subscriptions, s)
+		}
+	}
+	return subscriptions, nil
+}
+
+func GetReportSubscriptions(c *gin.Context) {
+	subscriptions, err := loadReportSubscriptions("1=1")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": subscriptions})
+}
+
+func CreateReportSubscription(c *gin.Context) {
+	var req ReportSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: email is required"})
+		return
+	}
+	if err := req.normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var existingId int64
+	err := models.DB.QueryRow("SELECT id FROM report_subscriptions WHERE email = ? AND frequency = ?",
+		req.Email, req.Frequency).Scan(&existingId)
+	if err == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This email is already subscribed to the " + req.Frequency + " report"})
+		return
+	} else if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	result, err := models.DB.Exec(`
+		INSERT INTO report_subscriptions (email, frequency, send_hour, weekday, is_enabled)
+		VALUES (?, ?, ?, ?, ?)`,
+		req.Email, req.Frequency, *req.SendHour, req.Weekday, *req.IsEnabled)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create subscription"})
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	c.JSON(http.StatusOK, gin.H{"success": true, "id": id, "message": "Subscription created successfully"})
+}
+
+func UpdateReportSubscription(c *gin.Context) {
+	id := c.Param("id")
+
+	var req ReportSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: email is required"})
+		return
+	}
+	if err := req.normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var existingId int64
+	err := models.DB.QueryRow("SELECT id FROM report_subscriptions WHERE email = ? AND frequency = ? AND id != ?",
+		req.Email, req.Frequency, id).Scan(&existingId)
+	if err == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This email is already subscribed to the " + req.Frequency + " report"})
+		return
+	} else if err != sql.ErrNoRows {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	result, err := models.DB.Exec(`
+		UPDATE report_subscriptions
+		SET email = ?, frequency = ?, send_hour = ?, weekday = ?, is_enabled = ?
+		WHERE id = ?`,
+		req.Email, req.Frequency, *req.SendHour, req.Weekday, *req.IsEnabled, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update subscription"})
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		var exists int
+		models.DB.QueryRow("SELECT COUNT(*) FROM report_subscriptions WHERE id = ?", id).Scan(&exists)
+		if exists == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Subscription not found"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Subscription updated successfully"})
+}
+
+func DeleteReportSubscription(c *gin.Context) {
+	id := c.Param("id")
+
+	_, err := models.DB.Exec("DELETE FROM report_subscriptions WHERE id = ?", id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete subscription"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Subscription deleted successfully"})
+}
+
+// PreviewEmailReport renders the report that would be sent now as HTML
+// (frequency=daily|weekly)
+func PreviewEmailReport(c *gin.Context) {
+	frequency := c.DefaultQuery("frequency", "daily")
+	if frequency != "daily" && frequency != "weekly" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "frequency must be daily or weekly"})
+		return
+	}
+
+	report, err := buildEmailReport(frequency, reportPeriod(frequency, reportNow()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(report.HTML))
+}
+
+type SendReportRequest struct {
+	Frequency string   `json:"frequency"`
+	Emails    []string `json:"emails"`
+}
+
+// SendEmailReportNow sends the current report immediately to the given
+// emails, or to every enabled subscriber of that frequency. It does not
+// affect the schedule.
+func SendEmailReportNow(c *gin.Context) {
+	var req SendReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request"})
+		return
+	}
+	if req.Frequency == "" {
+		req.Frequency = "daily"
+	}
+	if req.Frequency != "daily" && req.Frequency != "weekly" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "frequency must be daily or weekly"})
+		return
+	}
+
+	recipients := []string{}
+	for _, email := range req.Emails {
+		addr, err := mail.ParseAddress(strings.TrimSpace(email))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid email address " + email})
+			return
+		}
+		recipients = append(recipients, addr.Address)
+	}
+	if len(recipients) == 0 {
+		subscriptions, err := loadReportSubscriptions("is_enabled = 1 AND frequency = ?", req.Frequency)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		for _, s := range subscriptions {
+			recipients = append(recipients, s.Email)
+		}
+	}
+	if len(recipients) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "No recipients"})
+		return
+	}
+
+	report, err := buildEmailReport(req.Frequency, reportPeriod(req.Frequency, reportNow()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	results := map[string]string{}
+	for _, email := range recipients {
+		results[email] = channelResult(sendMail(mailMessage{To: []string{email}, Subject: report.Subject, Body: report.HTML, HTML: true}))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": results})
+}
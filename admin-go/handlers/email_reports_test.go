@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReportPeriod(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Ho_Chi_Minh")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		frequency string
+		now       time.Time
+		from, to  string
+		days      int
+	}{
+		{"daily", time.Date(2026, 10, 19, 7, 0, 0, 0, loc), "2026-10-18", "2026-10-18", 1},
+		{"weekly", time.Date(2026, 10, 19, 7, 0, 0, 0, loc), "2026-10-12", "2026-10-18", 7},
+		{"weekly", time.Date(2026, 10, 19, 23, 59, 0, 0, loc), "2026-10-12", "2026-10-18", 7},
+		{"daily", time.Date(2026, 1, 1, 0, 30, 0, 0, loc), "2025-12-31", "2025-12-31", 1},
+		{"weekly", time.Date(2026, 3, 2, 8, 0, 0, 0, loc), "2026-02-23", "2026-03-01", 7},
+	}
+	for _, tt := range tests {
+		r := reportPeriod(tt.frequency, tt.now)
+		if r.FromDate() != tt.from || r.ToDate() != tt.to || r.Days() != tt.days {
+			t.Errorf("reportPeriod(%s, %s) = %s..%s (%d days), want %s..%s (%d days)",
+				tt.frequency, tt.now.Format(time.RFC3339), r.FromDate(), r.ToDate(), r.Days(), tt.from, tt.to, tt.days)
+		}
+	}
+}
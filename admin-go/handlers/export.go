@@ -0,0 +1,370 @@
+package handlers
+
+import (
+	"admin-go/models"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+// maxExportRows stays below the Excel sheet limit of 1,048,576 rows
+const maxExportRows = 1000000
+
+// exportWriter streams tabular data as CSV or as sheets of an XLSX workbook
+type exportWriter interface {
+	Sheet(name string, headers []string) error
+	Row(values []interface{}) error
+	Close() error
+}
+
+// newExportWriter sets the download headers and returns a writer for format
+func newExportWriter(c *gin.Context, format, baseName string) (exportWriter, error) {
+	filename := fmt.Sprintf("%s-%s.%s", baseName, reportNow().Format("20060102-1504"), format)
+
+	switch format {
+	case "csv":
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", "attachment; filename="+filename)
+		c.Status(http.StatusOK)
+		// UTF-8 BOM so Excel shows Vietnamese text correctly
+		c.Writer.Write([]byte("\xEF\xBB\xBF"))
+		return &csvExportWriter{w: csv.NewWriter(c.Writer), flusher: c.Writer}, nil
+	case "xlsx":
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		c.Header("Content-Disposition", "attachment; filename="+filename)
+		c.Status(http.StatusOK)
+		return &xlsxExportWriter{f: excelize.NewFile(), out: c.Writer}, nil
+	}
+	return nil, fmt.Errorf("format must be csv or xlsx")
+}
+
+// spreadsheetText neutralizes text that a spreadsheet would evaluate as a
+// formula. Referrers, paths and visitor ids come from the public tracking
+// endpoint, so they are attacker-controlled.
+func spreadsheetText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+type csvExportWriter struct {
+	w       *csv.Writer
+	flusher http.Flusher
+	rows    int
+}
+
+func (e *csvExportWriter) Sheet(name string, headers []string) error {
+	return e.w.Write(headers)
+}
+
+func (e *csvExportWriter) Row(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			record[i] = spreadsheetText(s)
+		} else {
+			record[i] = fmt.Sprint(v)
+		}
+	}
+	if err := e.w.Write(record); err != nil {
+		return err
+	}
+	e.rows++
+	if e.rows%1000 == 0 {
+		e.w.Flush()
+		e.flusher.Flush()
+	}
+	return e.w.Error()
+}
+
+func (e *csvExportWriter) Close() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// xlsxExportWriter uses excelize stream writers, which spill rows to a
+// temporary file instead of keeping the whole sheet in memory
+type xlsxExportWriter struct {
+	f      *excelize.File
+	out    io.Writer
+	sw     *excelize.StreamWriter
+	sheets int
+	row    int
+}
+
+func (e *xlsxExportWriter) Sheet(name string, headers []string) error {
+	if e.sw != nil {
+		if err := e.sw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if e.sheets == 0 {
+		if err := e.f.SetSheetName("Sheet1", name); err != nil {
+			return err
+		}
+	} else if _, err := e.f.NewSheet(name); err != nil {
+		return err
+	}
+	e.sheets++
+
+	sw, err := e.f.NewStreamWriter(name)
+	if err != nil {
+		return err
+	}
+	e.sw = sw
+
+	headerStyle, err := e.f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true, Color: "#FFFFFF"},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#4472C4"}, Pattern: 1},
+	})
+	if err != nil {
+		return err
+	}
+	header := make([]interface{}, len(headers))
+	for i, h := range headers {
+		header[i] = excelize.Cell{StyleID: headerStyle, Value: h}
+	}
+	e.row = 1
+	return e.sw.SetRow("A1", header)
+}
+
+func (e *xlsxExportWriter) Row(values []interface{}) error {
+	e.row++
+	cell, err := excelize.CoordinatesToCellName(1, e.row)
+	if err != nil {
+		return err
+	}
+	row := make([]interface{}, len(values))
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			row[i] = spreadsheetText(s)
+		} else {
+			row[i] = v
+		}
+	}
+	return e.sw.SetRow(cell, row)
+}
+
+func (e *xlsxExportWriter) Close() error {
+	defer e.f.Close()
+	if e.sw != nil {
+		if err := e.sw.Flush(); err != nil {
+			return err
+		}
+	}
+	return e.f.Write(e.out)
+}
+
+// ExportVisitors streams the visitor list, with the same filters and sort as
+// /api/visitors/list, as CSV or XLSX (format=csv|xlsx)
+func ExportVisitors(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "format must be csv or xlsx"})
+		return
+	}
+
+	sortColumn, ok := visitorSortColumns[c.DefaultQuery("sort", "visit_time")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid sort column"})
+		return
+	}
+	order := strings.ToLower(c.DefaultQuery("order", "desc"))
+	if order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "order must be asc or desc"})
+		return
+	}
+
+	loc, err := reportLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	whereClause, args, err := visitorListFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	rows, err := models.DB.Query(`
+		SELECT id, ip_address, COALESCE(visitor_id, ''), COALESCE(page_path, '/'),
+		       COALESCE(device_type, ''), COALESCE(os, ''), COALESCE(browser, ''),
+		       COALESCE(country_code, ''), COALESCE(country_name, ''), COALESCE(region, ''), COALESCE(city, ''),
+		       COALESCE(asn, 0), COALESCE(org, ''), COALESCE(referrer, ''), is_new_visitor,
+		       visit_time, last_visit_time, COALESCE(duration, 0), page_view_count
+		FROM visitor_logs
+		WHERE `+whereClause+`
+		ORDER BY `+sortColumn+` `+order+`, id `+order+`
+		LIMIT ?`, append(args, maxExportRows)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	w, err := newExportWriter(c, format, "visitors")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err := w.Sheet("Visitors", []string{"ID", "IP", "Visitor ID", "Landing Page", "Device", "OS", "Browser",
+		"Country Code", "Country", "Region", "City", "ASN", "Network", "Referrer", "New Visitor",
+		"Visit Time", "Last Visit Time", "Duration (s)", "Page Views"}); err != nil {
+		log.Printf("Visitor export error: %v", err)
+		return
+	}
+
+	for rows.Next() {
+		var id int64
+		var ip, visitorId, path, device, os, browser, countryCode, country, region, city, org, referrer string
+		var asn uint32
+		var isNewVisitor bool
+		var visitTime, lastVisitTime time.Time
+		var duration, pvCount int
+
+		if err := rows.Scan(&id, &ip, &visitorId, &path, &device, &os, &browser,
+			&countryCode, &country, &region, &city, &asn, &org, &referrer, &isNewVisitor,
+			&visitTime, &lastVisitTime, &duration, &pvCount); err != nil {
+			log.Printf("Visitor export aborted: %v", err)
+			return
+		}
+
+		if err := w.Row([]interface{}{id, ip, visitorId, path, device, os, browser,
+			countryCode, country, region, city, asn, org, referrer, isNewVisitor,
+			visitTime.In(loc).Format("2006-01-02 15:04:05"), lastVisitTime.In(loc).Format("2006-01-02 15:04:05"),
+			duration, pvCount}); err != nil {
+			log.Printf("Visitor export aborted: %v", err)
+			return
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Visitor export aborted: %v", err)
+		return
+	}
+
+	if err := w.Close(); err != nil {
+		log.Printf("Visitor export error: %v", err)
+	}
+}
+
+// statsExportReports lists the reports of /api/export/stats in sheet order
+var statsExportReports = []string{"trend", "top_pages", "countries", "referrers"}
+
+// ExportStats exports the daily trend, top pages, country and referrer
+// breakdowns of a date range. XLSX holds one sheet per report; CSV holds
+// the single report selected with report= (default trend).
+func ExportStats(c *gin.Context) {
+	format := c.DefaultQuery("format", "xlsx")
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "format must be csv or xlsx"})
+		return
+	}
+
+	loc, err := reportLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	now := time.Now().In(loc)
+	r := dayRange(now, 30)
+	if c.Query("from") != "" || c.Query("to") != "" {
+		r, err = parseDateRange(c.Query("from"), c.Query("to"), now)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "1000"))
+	if limit < 1 {
+		limit = 1000
+	}
+
+	reports := statsExportReports
+	if report := c.Query("report"); report != "" {
+		reports = strings.Split(report, ",")
+	} else if format == "csv" {
+		reports = []string{"trend"}
+	}
+	for _, report := range reports {
+		if _, ok := statsExportTables[report]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Unknown report " + report})
+			return
+		}
+	}
+	if format == "csv" && len(reports) > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "CSV export holds a single report"})
+		return
+	}
+
+	w, err := newExportWriter(c, format, fmt.Sprintf("stats-%s-%s", r.FromDate(), r.ToDate()))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	for _, report := range reports {
+		table := statsExportTables[report]
+		if err := w.Sheet(table.sheet, table.headers); err != nil {
+			log.Printf("Stats export error: %v", err)
+			return
+		}
+		for _, row := range table.load(r, limit) {
+			values := make([]interface{}, len(table.keys))
+			for i, key := range table.keys {
+				values[i] = row[key]
+			}
+			if err := w.Row(values); err != nil {
+				log.Printf("Stats export aborted: %v", err)
+				return
+			}
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		log.Printf("Stats export error: %v", err)
+	}
+}
+
+// statsExportTable describes one report of /api/export/stats
+type statsExportTable struct {
+	sheet   string
+	headers []string
+	keys    []string
+	load    func(r statsRange, limit int) []map[string]interface{}
+}
+
+// statsExportTables maps the report= names to their sheets
+var statsExportTables = map[string]statsExportTable{
+	"trend": {
+		sheet: "Daily Trend", headers: []string{"Date", "PV", "UV"}, keys: []string{"date", "pv", "uv"},
+		load: func(r statsRange, limit int) []map[string]interface{} { return getTrend(r) },
+	},
+	"top_pages": {
+		sheet: "Top Pages", headers: []string{"Page", "PV", "UV"}, keys: []string{"page", "pv", "uv"},
+		load: func(r statsRange, limit int) []map[string]interface{} {
+			return dimensionBreakdown(r, "page", "page", "pv", limit)
+		},
+	},
+	"countries": {
+		sheet: "Countries", headers: []string{"Country", "UV", "PV"}, keys: []string{"country", "uv", "pv"},
+		load: func(r statsRange, limit int) []map[string]interface{} {
+			return dimensionBreakdown(r, "country", "country", "uv", limit)
+		},
+	},
+	"referrers": {
+		sheet: "Referrers", headers: []string{"Source", "UV", "PV"}, keys: []string{"source", "uv", "pv"},
+		load: func(r statsRange, limit int) []map[string]interface{} {
+			return dimensionBreakdown(r, "referrer", "source", "uv", limit)
+		},
+	},
+}
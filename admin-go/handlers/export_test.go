@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestSpreadsheetText(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"=HYPERLINK(\"http://evil\")", "'=HYPERLINK(\"http://evil\")"},
+		{"+1", "'+1"},
+		{"-cmd", "'-cmd"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tx", "'\tx"},
+		{"\rx", "'\rx"},
+		{"https://google.com/", "https://google.com/"},
+		{"/tin-tuc", "/tin-tuc"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := spreadsheetText(tt.in); got != tt.want {
+			t.Errorf("spreadsheetText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestXLSXExportWriter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+
+	w, err := newExportWriter(c, "xlsx", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Sheet("Visitors", []string{"ID", "Referrer", "New"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Row([]interface{}{int64(7), "=1+1", true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Sheet("A & B", []string{"Page"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Row([]interface{}{"/trận-đấu<1>"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := excelize.OpenReader(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("output is not a readable workbook: %v", err)
+	}
+	defer f.Close()
+
+	if got := f.GetSheetList(); len(got) != 2 || got[0] != "Visitors" || got[1] != "A & B" {
+		t.Fatalf("sheets = %v", got)
+	}
+	rows, err := f.GetRows("Visitors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"ID", "Referrer", "New"}, {"7", "'=1+1", "TRUE"}}
+	if len(rows) != len(want) {
+		t.Fatalf("rows = %v, want %v", rows, want)
+	}
+	for i := range want {
+		for j := range want[i] {
+			if rows[i][j] != want[i][j] {
+				t.Errorf("cell %d,%d = %q, want %q", i, j, rows[i][j], want[i][j])
+			}
+		}
+	}
+	if v, _ := f.GetCellValue("A & B", "A2"); v != "/trận-đấu<1>" {
+		t.Errorf("A2 = %q", v)
+	}
+}
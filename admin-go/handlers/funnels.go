@@ -0,0 +1,455 @@
+package handlers
+
+import (
+	"admin-go/models"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxFunnelSteps             = 10
+	defaultFunnelWindowMinutes = 1440
+	maxFunnelWindowMinutes     = 30 * 1440
+	// maxFunnelEventsPerVisitor bounds the memory used by a single (bot) visitor
+	maxFunnelEventsPerVisitor = 10000
+)
+
+type FunnelRequest struct {
+	Name                    string              `json:"name" binding:"required"`
+	Description             string              `json:"description"`
+	Steps                   []models.FunnelStep `json:"steps" binding:"required"`
+	ConversionWindowMinutes int                 `json:"conversion_window_minutes"`
+}
+
+// validate normalizes the request and checks every step can be compiled
+func (req *FunnelRequest) validate() error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(req.Steps) < 2 || len(req.Steps) > maxFunnelSteps {
+		return fmt.Errorf("a funnel needs between 2 and %d steps", maxFunnelSteps)
+	}
+	if req.ConversionWindowMinutes == 0 {
+		req.ConversionWindowMinutes = defaultFunnelWindowMinutes
+	}
+	if req.ConversionWindowMinutes < 1 || req.ConversionWindowMinutes > maxFunnelWindowMinutes {
+		return fmt.Errorf("conversion_window_minutes must be between 1 and %d", maxFunnelWindowMinutes)
+	}
+	for i := range req.Steps {
+		req.Steps[i].Value = strings.TrimSpace(req.Steps[i].Value)
+		if _, err := compileFunnelStep(req.Steps[i]); err != nil {
+			return fmt.Errorf("step %d: %v", i+1, err)
+		}
+	}
+	return nil
+}
+
+// compiledFunnelStep is a step ready to be matched against tracked events
+type compiledFunnelStep struct {
+	models.FunnelStep
+	pattern *regexp.Regexp
+}
+
+func compileFunnelStep(step models.FunnelStep) (compiledFunnelStep, error) {
+	if step.Value == "" {
+		return compiledFunnelStep{}, errors.New("value is required")
+	}
+	compiled := compiledFunnelStep{FunnelStep: step}
+	switch step.Type {
+	case "path":
+		// Glob style: * matches any sequence of characters
+		expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(step.Value), `\*`, ".*") + "$"
+		pattern, err := regexp.Compile(expr)
+		if err != nil {
+			return compiledFunnelStep{}, err
+		}
+		compiled.pattern = pattern
+	case "page_type", "event":
+	default:
+		return compiledFunnelStep{}, errors.New("type must be path, page_type or event")
+	}
+	return compiled, nil
+}
+
+// funnelEvent is a page view or custom event of a visitor
+type funnelEvent struct {
+	at          time.Time
+	kind        string // pageview or event
+	value       string // page path or event name
+	pageType    string
+	referenceId string
+	device      string
+	source      string
+}
+
+func (s compiledFunnelStep) match(e funnelEvent) bool {
+	switch s.Type {
+	case "path":
+		return e.kind == "pageview" && s.pattern.MatchString(e.value)
+	case "page_type":
+		return e.kind == "pageview" && e.pageType == s.Value &&
+			(s.ReferenceId == "" || e.referenceId == s.ReferenceId)
+	case "event":
+		return e.kind == "event" && e.value == s.Value
+	}
+	return false
+}
+
+// funnelDepth returns how many consecutive steps a visitor completed, trying
+// every step 1 occurrence before end, and the event that started the best attempt
+func funnelDepth(events []funnelEvent, steps []compiledFunnelStep, window time.Duration, end time.Time) (int, *funnelEvent) {
+	best := 0
+	var start *funnelEvent
+	for i := range events {
+		if !events[i].at.Before(end) {
+			break
+		}
+		if !steps[0].match(events[i]) {
+			continue
+		}
+
+		depth := 1
+		deadline := events[i].at.Add(window)
+		for j := i + 1; j < len(events) && depth < len(steps); j++ {
+			if events[j].at.After(deadline) {
+				break
+			}
+			if steps[depth].match(events[j]) {
+				depth++
+			}
+		}
+
+		if depth > best {
+			best = depth
+			start = &events[i]
+		}
+		if best == len(steps) {
+			break
+		}
+	}
+	return best, start
+}
+
+// funnelBreakdownRow counts visitors per step for one device or source
+type funnelBreakdownRow struct {
+	Value          string  `json:"value"`
+	Steps          []int   `json:"steps"`
+	ConversionRate float64 `json:"conversion_rate"`
+}
+
+// computeFunnel walks the page views and custom events of every visitor in
+// the range and counts how far each got through the funnel
+func computeFunnel(f models.Funnel, r statsRange) (gin.H, error) {
+	steps := make([]compiledFunnelStep, len(f.Steps))
+	for i, step := range f.Steps {
+		compiled, err := compileFunnelStep(step)
+		if err != nil {
+			return nil, err
+		}
+		steps[i] = compiled
+	}
+	window := time.Duration(f.ConversionWindowMinutes) * time.Minute
+
+	// Funnels started in the range may complete within the window after it
+	eventsEnd := r.To.Add(window)
+
+	rows, err := models.DB.Query(`
+		SELECT pv.visitor_id, pv.viewed_at, 'pageview', pv.page_path,
+		       COALESCE(pv.page_type, ''), COALESCE(pv.reference_id, ''),
+		       COALESCE(vl.device, 'Unknown'), COALESCE(vl.source, 'Unknown')
+		FROM page_views pv
+		LEFT JOIN (
+			SELECT id, COALESCE(NULLIF(device_type, ''), 'Unknown') as device, `+referrerSourceExpr+` as source
+			FROM visitor_logs
+			WHERE visit_time >= ? AND visit_time < ?
+		) vl ON vl.id = pv.log_id
+		WHERE pv.viewed_at >= ? AND pv.viewed_at < ? AND pv.visitor_id <> ''
+		UNION ALL
+		SELECT visitor_id, created_at, 'event', event_name,
+		       COALESCE(page_type, ''), COALESCE(reference_id, ''), '', ''
+		FROM visitor_events
+		WHERE created_at >= ? AND created_at < ? AND visitor_id <> ''
+		ORDER BY 1, 2`,
+		r.From.AddDate(0, 0, -1), eventsEnd, r.From, eventsEnd, r.From, eventsEnd)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make([]int, len(steps))
+	byDevice := map[string][]int{}
+	bySource := map[string][]int{}
+
+	tally := func(events []funnelEvent) {
+		depth, start := funnelDepth(events, steps, window, r.To)
+		if depth == 0 {
+			return
+		}
+		if byDevice[start.device] == nil {
+			byDevice[start.device] = make([]int, len(steps))
+		}
+		if bySource[start.source] == nil {
+			bySource[start.source] = make([]int, len(steps))
+		}
+		for k := 0; k < depth; k++ {
+			counts[k]++
+			byDevice[start.device][k]++
+			bySource[start.source][k]++
+		}
+	}
+
+	currentVisitor := ""
+	events := []funnelEvent{}
+	for rows.Next() {
+		var visitorId string
+		var e funnelEvent
+		if err := rows.Scan(&visitorId, &e.at, &e.kind, &e.value, &e.pageType, &e.referenceId, &e.device, &e.source); err != nil {
+			continue
+		}
+
+		if visitorId != currentVisitor {
+			tally(events)
+			currentVisitor = visitorId
+			events = events[:0]
+		}
+		if len(events) >= maxFunnelEventsPerVisitor {
+			continue
+		}
+
+		// Custom events are attributed to the visit of the last page view
+		if e.kind == "event" {
+			e.device, e.source = "Unknown", "Unknown"
+			if n := len(events); n > 0 {
+				e.device, e.source = events[n-1].device, events[n-1].source
+			}
+		}
+		events = append(events, e)
+	}
+	tally(events)
+
+	stepResults := []gin.H{}
+	for k, step := range f.Steps {
+		result := gin.H{
+			"step":                 k + 1,
+			"name":                 step.Name,
+			"type":                 step.Type,
+			"value":                step.Value,
+			"visitors":             counts[k],
+			"conversion_rate":      funnelRate(counts[k], counts[0]),
+			"step_conversion_rate": 100.0,
+			"drop_off":             0,
+			"drop_off_rate":        0.0,
+		}
+		if k > 0 {
+			result["step_conversion_rate"] = funnelRate(counts[k], counts[k-1])
+			result["drop_off"] = counts[k-1] - counts[k]
+			result["drop_off_rate"] = funnelRate(counts[k-1]-counts[k], counts[k-1])
+		}
+		stepResults = append(stepResults, result)
+	}
+
+	return gin.H{
+		"funnel":          f,
+		"range":           r.JSON(),
+		"steps":           stepResults,
+		"conversion_rate": funnelRate(counts[len(counts)-1], counts[0]),
+		"breakdowns": gin.H{
+			"device": funnelBreakdown(byDevice),
+			"source": funnelBreakdown(bySource),
+		},
+	}, nil
+}
+
+// funnelRate returns part/total as a percentage with two decimals
+func funnelRate(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(total)*10000) / 100
+}
+
+func funnelBreakdown(counts map[string][]int) []funnelBreakdownRow {
+	result := []funnelBreakdownRow{}
+	for value, steps := range counts {
+		result = append(result, funnelBreakdownRow{
+			Value:          value,
+			Steps:          steps,
+			ConversionRate: funnelRate(steps[len(steps)-1], steps[0]),
+		})
+	}
+	// Largest entry segments first
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Steps[0] != result[j].Steps[0] {
+			return result[i].Steps[0] > result[j].Steps[0]
+		}
+		return result[i].Value < result[j].Value
+	})
+	return result
+}
+
+// loadFunnel reads a saved funnel by id
+func loadFunnel(id string) (models.Funnel, error) {
+	var f models.Funnel
+	var steps string
+	err := models.DB.QueryRow(`
+		SELECT id, name, COALESCE(description, ''), steps, conversion_window_minutes, created_at, updated_at
+		FROM funnels WHERE id = ?`, id).
+		Scan(&f.ID, &f.Name, &f.Description, &steps, &f.ConversionWindowMinutes, &f.CreatedAt, &f.UpdatedAt)
+	if err != nil {
+		return f, err
+	}
+	err = json.Unmarshal([]byte(steps), &f.Steps)
+	return f, err
+}
+
+func GetFunnels(c *gin.Context) {
+	rows, err := models.DB.Query(`
+		SELECT id, name, COALESCE(description, ''), steps, conversion_window_minutes, created_at, updated_at
+		FROM funnels
+		ORDER BY name`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	funnels := []models.Funnel{}
+	for rows.Next() {
+		var f models.Funnel
+		var steps string
+		rows.Scan(&f.ID, &f.Name, &f.Description, &steps, &f.ConversionWindowMinutes, &f.CreatedAt, &f.UpdatedAt)
+		json.Unmarshal([]byte(steps), &f.Steps)
+		funnels = append(funnels, f)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": funnels})
+}
+
+func GetFunnel(c *gin.Context) {
+	f, err := loadFunnel(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Funnel not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": f})
+}
+
+func CreateFunnel(c *gin.Context) {
+	var req FunnelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	steps, _ := json.Marshal(req.Steps)
+	result, err := models.DB.Exec(`
+		INSERT INTO funnels (name, description, steps, conversion_window_minutes)
+		VALUES (?, ?, ?, ?)`,
+		req.Name, req.Description, string(steps), req.ConversionWindowMinutes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create funnel"})
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	c.JSON(http.StatusOK, gin.H{"success": true, "id": id, "message": "Funnel created successfully"})
+}
+
+func UpdateFunnel(c *gin.Context) {
+	id := c.Param("id")
+
+	var req FunnelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	steps, _ := json.Marshal(req.Steps)
+	result, err := models.DB.Exec(`
+		UPDATE funnels
+		SET name = ?, description = ?, steps = ?, conversion_window_minutes = ?
+		WHERE id = ?`,
+		req.Name, req.Description, string(steps), req.ConversionWindowMinutes, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update funnel"})
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		if _, err := loadFunnel(id); err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Funnel not found"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Funnel updated successfully"})
+}
+
+func DeleteFunnel(c *gin.Context) {
+	_, err := models.DB.Exec("DELETE FROM funnels WHERE id = ?", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete funnel"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Funnel deleted successfully"})
+}
+
+// GetFunnelReport computes a saved funnel over from/to (default: last 7 days)
+func GetFunnelReport(c *gin.Context) {
+	f, err := loadFunnel(c.Param("id"))
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Funnel not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	loc, err := reportLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	now := time.Now().In(loc)
+	r := dayRange(now, 7)
+	if c.Query("from") != "" || c.Query("to") != "" {
+		r, err = parseDateRange(c.Query("from"), c.Query("to"), now)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+	}
+
+	report, err := computeFunnel(f, r)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": report})
+}
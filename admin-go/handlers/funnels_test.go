@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"admin-go/models"
+	"testing"
+	"time"
+)
+
+func TestCompileFunnelStep(t *testing.T) {
+	tests := []struct {
+		step    models.FunnelStep
+		wantErr bool
+	}{
+		{models.FunnelStep{Type: "path", Value: "/tin-tuc/*"}, false},
+		{models.FunnelStep{Type: "page_type", Value: "article"}, false},
+		{models.FunnelStep{Type: "event", Value: "signup"}, false},
+		{models.FunnelStep{Type: "path", Value: ""}, true},
+		{models.FunnelStep{Type: "referrer", Value: "google"}, true},
+	}
+	for _, tt := range tests {
+		_, err := compileFunnelStep(tt.step)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("compileFunnelStep(%+v) error = %v, wantErr %v", tt.step, err, tt.wantErr)
+		}
+	}
+}
+
+func mustCompileFunnelStep(t *testing.T, typ, value, referenceId string) compiledFunnelStep {
+	t.Helper()
+	step, err := compileFunnelStep(models.FunnelStep{Type: typ, Value: value, ReferenceId: referenceId})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return step
+}
+
+func TestFunnelStepMatch(t *testing.T) {
+	pageview := func(path, pageType, referenceId string) funnelEvent {
+		return funnelEvent{kind: "pageview", value: path, pageType: pageType, referenceId: referenceId}
+	}
+	tests := []struct {
+		name  string
+		step  compiledFunnelStep
+		event funnelEvent
+		want  bool
+	}{
+		{"exact path", mustCompileFunnelStep(t, "path", "/gio-hang", ""), pageview("/gio-hang", "", ""), true},
+		{"path is anchored", mustCompileFunnelStep(t, "path", "/gio-hang", ""), pageview("/gio-hang/1", "", ""), false},
+		{"glob path", mustCompileFunnelStep(t, "path", "/tin-tuc/*", ""), pageview("/tin-tuc/bong-da", "", ""), true},
+		{"glob in the middle", mustCompileFunnelStep(t, "path", "/a/*/b", ""), pageview("/a/x/y/b", "", ""), true},
+		{"regexp characters are literal", mustCompileFunnelStep(t, "path", "/a.b", ""), pageview("/axb", "", ""), false},
+		{"path does not match events", mustCompileFunnelStep(t, "path", "/x", ""), funnelEvent{kind: "event", value: "/x"}, false},
+		{"page type", mustCompileFunnelStep(t, "page_type", "article", ""), pageview("/a", "article", "7"), true},
+		{"page type and reference", mustCompileFunnelStep(t, "page_type", "article", "7"), pageview("/a", "article", "7"), true},
+		{"other reference", mustCompileFunnelStep(t, "page_type", "article", "7"), pageview("/a", "article", "8"), false},
+		{"other page type", mustCompileFunnelStep(t, "page_type", "article", ""), pageview("/a", "category", ""), false},
+		{"event", mustCompileFunnelStep(t, "event", "signup", ""), funnelEvent{kind: "event", value: "signup"}, true},
+		{"event does not match page views", mustCompileFunnelStep(t, "event", "/signup", ""), pageview("/signup", "", ""), false},
+	}
+	for _, tt := range tests {
+		if got := tt.step.match(tt.event); got != tt.want {
+			t.Errorf("%s: match = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFunnelDepth(t *testing.T) {
+	base := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int, path string) funnelEvent {
+		return funnelEvent{at: base.Add(time.Duration(minutes) * time.Minute), kind: "pageview", value: path}
+	}
+	steps := []compiledFunnelStep{
+		mustCompileFunnelStep(t, "path", "/a", ""),
+		mustCompileFunnelStep(t, "path", "/b", ""),
+		mustCompileFunnelStep(t, "path", "/c", ""),
+	}
+	end := base.Add(24 * time.Hour)
+
+	tests := []struct {
+		name      string
+		events    []funnelEvent
+		end       time.Time
+		want      int
+		wantStart int // minute of the starting event, -1 for none
+	}{
+		{"no start", []funnelEvent{at(0, "/b"), at(1, "/c")}, end, 0, -1},
+		{"complete", []funnelEvent{at(0, "/a"), at(1, "/b"), at(2, "/c")}, end, 3, 0},
+		{"other pages in between", []funnelEvent{at(0, "/a"), at(1, "/x"), at(2, "/b"), at(3, "/x"), at(4, "/c")}, end, 3, 0},
+		{"out of order", []funnelEvent{at(0, "/a"), at(1, "/c"), at(2, "/b")}, end, 2, 0},
+		{"window deadline", []funnelEvent{at(0, "/a"), at(30, "/b"), at(61, "/c")}, end, 2, 0},
+		{"step at the deadline counts", []funnelEvent{at(0, "/a"), at(30, "/b"), at(60, "/c")}, end, 3, 0},
+		{"later attempt is better", []funnelEvent{at(0, "/a"), at(90, "/a"), at(91, "/b"), at(92, "/c")}, end, 3, 90},
+		{"first best attempt is kept", []funnelEvent{at(0, "/a"), at(1, "/b"), at(90, "/a"), at(91, "/b")}, end, 2, 0},
+		{"start at the end is ignored", []funnelEvent{at(0, "/x"), at(10, "/a"), at(11, "/b")}, base.Add(10 * time.Minute), 0, -1},
+		{"later steps may follow the end", []funnelEvent{at(0, "/a"), at(20, "/b"), at(30, "/c")}, base.Add(10 * time.Minute), 3, 0},
+	}
+	for _, tt := range tests {
+		got, start := funnelDepth(tt.events, steps, time.Hour, tt.end)
+		if got != tt.want {
+			t.Errorf("%s: depth = %d, want %d", tt.name, got, tt.want)
+		}
+		switch {
+		case tt.wantStart < 0 && start != nil:
+			t.Errorf("%s: start = %v, want none", tt.name, start.at)
+		case tt.wantStart >= 0 && (start == nil || !start.at.Equal(base.Add(time.Duration(tt.wantStart)*time.Minute))):
+			t.Errorf("%s: start = %v, want minute %d", tt.name, start, tt.wantStart)
+		}
+	}
+}
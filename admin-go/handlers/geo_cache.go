@@ -0,0 +1,404 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"admin-go/models"
+	"container/list"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// geoLRU is an in-memory LRU cache of resolved locations, keyed by geoCacheKey
+type geoLRU struct {
+	mu       sync.Mutex
+	capacity int
+	ttl      time.Duration
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type geoLRUEntry struct {
+	ip        string
+	info      GeoLocationInfo
+	expiresAt time.Time
+}
+
+func newGeoLRU(capacity int, ttl time.Duration) *geoLRU {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &geoLRU{
+		capacity: capacity,
+		ttl:      ttl,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *geoLRU) Get(ip string) (*GeoLocationInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[ip]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*geoLRUEntry)
+	if time.Now().After(entry.expiresAt) {
+		c.ll.Remove(el)
+		delete(c.items, ip)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	info := entry.info
+	return &info, true
+}
+
+func (c *geoLRU) Remove(ip string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[ip]; ok {
+		c.ll.Remove(el)
+		delete(c.items, ip)
+	}
+}
+
+func (c *geoLRU) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.ll.Init()
+	c.items = make(map[string]*list.Element)
+}
+
+func (c *geoLRU) Add(ip string, info GeoLocationInfo, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[ip]; ok {
+		entry := el.Value.(*geoLRUEntry)
+		entry.info = info
+		entry.expiresAt = expiresAt
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[ip] = c.ll.PushFront(&geoLRUEntry{ip: ip, info: info, expiresAt: expiresAt})
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*geoLRUEntry).ip)
+	}
+}
+
+var geoCache = newGeoLRU(10000, 720*time.Hour)
+
+// geoJob asks the worker to fill in the location of a visitor_logs row.
+// The raw IP only lives in memory; the geo_cache table is keyed by the
+// stored (anonymized) form, see geoCacheKey.
+type geoJob struct {
+	logID int64
+	ip    string
+}
+
+var geoQueue chan geoJob
+
+// StartGeoWorker starts the background goroutine that enriches new
+// visitor_logs rows with geolocation data
+func StartGeoWorker() {
+	cfg := config.GetGeoConfig()
+	geoCache = newGeoLRU(cfg.GeoCacheSize, time.Duration(cfg.GeoCacheTTLHours)*time.Hour)
+	geoQueue = make(chan geoJob, cfg.GeoQueueSize)
+
+	go func() {
+		for job := range geoQueue {
+			if models.DB == nil {
+				continue
+			}
+			info, err := resolveGeo(job.ip)
+			if err != nil || info == nil {
+				// Left empty, the backfill job retries it later
+				continue
+			}
+			models.DB.Exec(`
+				UPDATE visitor_logs
+				SET country_code = ?, country_name = ?, city = ?, region = ?, asn = ?, org = ?, is_hosting = ?
+				WHERE id = ?`, info.CountryCode, info.CountryName, info.City,
+				info.Region, info.ASN, info.Org, info.IsHosting, job.logID)
+		}
+	}()
+
+	purgeRawGeoCache()
+
+	// Purge expired persistent cache entries once a day
+	go func() {
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			if models.DB != nil {
+				models.DB.Exec("DELETE FROM geo_cache WHERE expires_at < ?", time.Now())
+			}
+		}
+	}()
+}
+
+// cachedGeoLocation returns a location without blocking on a provider.
+// ok is false when the lookup has to be done by the background worker.
+func cachedGeoLocation(ip string) (info *GeoLocationInfo, ok bool) {
+	if isLocalOrPrivateIP(ip) {
+		return &GeoLocationInfo{CountryCode: "LOCAL", CountryName: "Local Network", City: "Local"}, true
+	}
+	return geoCache.Get(geoCacheKey(ip))
+}
+
+// enqueueGeoEnrichment schedules a lookup for a visitor_logs row.
+// When the queue is full the row is left for the backfill job.
+func enqueueGeoEnrichment(logID int64, ip string) {
+	if geoQueue == nil || logID == 0 {
+		return
+	}
+	select {
+	case geoQueue <- geoJob{logID: logID, ip: ip}:
+	default:
+	}
+}
+
+// geoCacheKey is the key of an IP in geo_cache and the memory cache: the
+// form stored in visitor_logs, so neither holds raw IPs when anonymization
+// is on.
+// With truncation the location of the /24 (/48) is shared by its visitors.
+func geoCacheKey(ip string) string {
+	return anonymizeIP(ip)
+}
+
+// purgeRawGeoCache drops geo_cache rows keyed by raw IPs, written before IP
+// anonymization was enabled
+func purgeRawGeoCache() {
+	if models.DB == nil {
+		return
+	}
+	switch config.GetPrivacyConfig().PrivacyIPMode {
+	case "truncate":
+		models.DB.Exec("DELETE FROM geo_cache WHERE ip_address NOT LIKE '%.0' AND ip_address NOT LIKE '%::'")
+	case "hash":
+		models.DB.Exec("DELETE FROM geo_cache WHERE ip_address NOT LIKE 'h:%'")
+	}
+}
+
+// forgetGeoLocations drops the cached locations of IPs given in raw or
+// stored form, from memory and from geo_cache
+func forgetGeoLocations(ips []string) error {
+	if len(ips) == 0 {
+		return nil
+	}
+	keys := []interface{}{}
+	for _, ip := range ips {
+		geoCache.Remove(ip)
+		geoCache.Remove(geoCacheKey(ip))
+		keys = append(keys, ip, geoCacheKey(ip))
+	}
+	if models.DB == nil {
+		return nil
+	}
+	_, err := models.DB.Exec("DELETE FROM geo_cache WHERE ip_address IN ("+
+		strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ")+")", keys...)
+	return err
+}
+
+// forgetAllGeoLocations empties both geolocation caches
+func forgetAllGeoLocations() error {
+	geoCache.Purge()
+	_, err := models.DB.Exec("DELETE FROM geo_cache")
+	return err
+}
+
+// resolveGeo looks up an IP in the memory cache, then the geo_cache table,
+// then the configured providers, storing fresh results in both caches
+func resolveGeo(ip string) (*GeoLocationInfo, error) {
+	if info, ok := cachedGeoLocation(ip); ok {
+		return info, nil
+	}
+
+	key := geoCacheKey(ip)
+	if models.DB != nil {
+		var info GeoLocationInfo
+		var expiresAt time.Time
+		err := models.DB.QueryRow(`
+			SELECT COALESCE(country_code, ''), COALESCE(country_name, ''), COALESCE(city, ''),
+			       COALESCE(region, ''), COALESCE(asn, 0), COALESCE(org, ''), COALESCE(is_hosting, 0), expires_at
+			FROM geo_cache
+			WHERE ip_address = ? AND expires_at > ?`, key, time.Now()).
+			Scan(&info.CountryCode, &info.CountryName, &info.City,
+				&info.Region, &info.ASN, &info.Org, &info.IsHosting, &expiresAt)
+		if err == nil {
+			geoCache.Add(key, info, expiresAt)
+			return &info, nil
+		}
+	}
+
+	info, err := geoProvider.Lookup(ip)
+	if err != nil || info == nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(geoCache.ttl)
+	geoCache.Add(key, *info, expiresAt)
+	if models.DB != nil {
+		models.DB.Exec(`
+			INSERT INTO geo_cache (ip_address, country_code, country_name, city, region, asn, org, is_hosting, provider, expires_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			ON DUPLICATE KEY UPDATE
+			    country_code = VALUES(country_code),
+			    country_name = VALUES(country_name),
+			    city = VALUES(city),
+			    region = VALUES(region),
+			    asn = VALUES(asn),
+			    org = VALUES(org),
+			    is_hosting = VALUES(is_hosting),
+			    provider = VALUES(provider),
+			    expires_at = VALUES(expires_at)`,
+			key, info.CountryCode, info.CountryName, info.City,
+			info.Region, info.ASN, info.Org, info.IsHosting, info.Provider, expiresAt)
+	}
+	return info, nil
+}
+
+// GeoBackfillStatus reports the progress of a backfill run
+type GeoBackfillStatus struct {
+	Running     bool       `json:"running"`
+	From        string     `json:"from"`
+	To          string     `json:"to"`
+	TotalIPs    int        `json:"total_ips"`
+	ResolvedIPs int        `json:"resolved_ips"`
+	FailedIPs   int        `json:"failed_ips"`
+	SkippedIPs  int        `json:"skipped_ips"`
+	UpdatedRows int64      `json:"updated_rows"`
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
+
+var (
+	geoBackfillMu     sync.Mutex
+	geoBackfillStatus GeoBackfillStatus
+)
+
+// RunGeoBackfill re-resolves visitor_logs rows whose country is empty or
+// Unknown. from/to are optional YYYY-MM-DD bounds, limit caps distinct IPs.
+func RunGeoBackfill(from, to string, limit int) GeoBackfillStatus {
+	startedAt := time.Now()
+	updateBackfill(func(s *GeoBackfillStatus) {
+		*s = GeoBackfillStatus{Running: true, From: from, To: to, StartedAt: &startedAt}
+	})
+
+	whereClause := "(country_name IS NULL OR country_name = '' OR country_name = 'Unknown')"
+	args := []interface{}{}
+	if day, err := dateRange(from, config.GetReportLocation()); err == nil {
+		whereClause += " AND visit_time >= ?"
+		args = append(args, day.From)
+	}
+	if day, err := dateRange(to, config.GetReportLocation()); err == nil {
+		whereClause += " AND visit_time < ?"
+		args = append(args, day.To)
+	}
+	if limit < 1 {
+		limit = 5000
+	}
+
+	ips := []string{}
+	rows, err := models.DB.Query("SELECT DISTINCT ip_address FROM visitor_logs WHERE "+whereClause+" LIMIT ?", append(args, limit)...)
+	if err == nil {
+		for rows.Next() {
+			var ip string
+			if rows.Scan(&ip) == nil {
+				ips = append(ips, ip)
+			}
+		}
+		rows.Close()
+	} else {
+		log.Printf("Geo backfill query error: %v", err)
+	}
+	updateBackfill(func(s *GeoBackfillStatus) { s.TotalIPs = len(ips) })
+
+	for _, ip := range ips {
+		// Hashed IPs cannot be resolved any more
+		if strings.HasPrefix(ip, "h:") {
+			updateBackfill(func(s *GeoBackfillStatus) { s.SkippedIPs++ })
+			continue
+		}
+
+		info, err := resolveGeo(ip)
+		for retries := 0; err == errGeoRateLimited && retries < 30; retries++ {
+			time.Sleep(2 * time.Second)
+			info, err = resolveGeo(ip)
+		}
+		if err != nil || info == nil {
+			updateBackfill(func(s *GeoBackfillStatus) { s.FailedIPs++ })
+			continue
+		}
+
+		result, err := models.DB.Exec(`
+			UPDATE visitor_logs
+			SET country_code = ?, country_name = ?, city = ?, region = ?, asn = ?, org = ?, is_hosting = ?
+			WHERE ip_address = ? AND `+whereClause,
+			append([]interface{}{info.CountryCode, info.CountryName, info.City,
+				info.Region, info.ASN, info.Org, info.IsHosting, ip}, args...)...)
+		var affected int64
+		if err == nil {
+			affected, _ = result.RowsAffected()
+		}
+		updateBackfill(func(s *GeoBackfillStatus) {
+			s.ResolvedIPs++
+			s.UpdatedRows += affected
+		})
+	}
+
+	finishedAt := time.Now()
+	return updateBackfill(func(s *GeoBackfillStatus) {
+		s.Running = false
+		s.FinishedAt = &finishedAt
+	})
+}
+
+func updateBackfill(fn func(s *GeoBackfillStatus)) GeoBackfillStatus {
+	geoBackfillMu.Lock()
+	defer geoBackfillMu.Unlock()
+	fn(&geoBackfillStatus)
+	return geoBackfillStatus
+}
+
+// StartGeoBackfill runs a backfill in the background
+func StartGeoBackfill(c *gin.Context) {
+	var req struct {
+		From  string `json:"from"`
+		To    string `json:"to"`
+		Limit int    `json:"limit"`
+	}
+	c.ShouldBindJSON(&req)
+
+	started := false
+	updateBackfill(func(s *GeoBackfillStatus) {
+		if !s.Running {
+			s.Running = true
+			started = true
+		}
+	})
+	if !started {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Backfill already running"})
+		return
+	}
+
+	go RunGeoBackfill(req.From, req.To, req.Limit)
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Geo backfill started"})
+}
+
+// GetGeoBackfillStatus returns the progress of the current or last backfill
+func GetGeoBackfillStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": updateBackfill(func(s *GeoBackfillStatus) {})})
+}
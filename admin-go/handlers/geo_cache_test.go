@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// geoLRUKeys lists the cached IPs, most recently used first
+func geoLRUKeys(c *geoLRU) []string {
+	keys := []string{}
+	for el := c.ll.Front(); el != nil; el = el.Next() {
+		keys = append(keys, el.Value.(*geoLRUEntry).ip)
+	}
+	return keys
+}
+
+func TestGeoLRU(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	tests := []struct {
+		name     string
+		capacity int
+		run      func(c *geoLRU)
+		want     []string
+	}{
+		{
+			name:     "evicts the least recently added",
+			capacity: 2,
+			run: func(c *geoLRU) {
+				c.Add("a", GeoLocationInfo{}, later)
+				c.Add("b", GeoLocationInfo{}, later)
+				c.Add("c", GeoLocationInfo{}, later)
+			},
+			want: []string{"c", "b"},
+		},
+		{
+			name:     "get marks an entry as used",
+			capacity: 2,
+			run: func(c *geoLRU) {
+				c.Add("a", GeoLocationInfo{}, later)
+				c.Add("b", GeoLocationInfo{}, later)
+				c.Get("a")
+				c.Add("c", GeoLocationInfo{}, later)
+			},
+			want: []string{"c", "a"},
+		},
+		{
+			name:     "adding an existing entry does not evict",
+			capacity: 2,
+			run: func(c *geoLRU) {
+				c.Add("a", GeoLocationInfo{}, later)
+				c.Add("b", GeoLocationInfo{}, later)
+				c.Add("a", GeoLocationInfo{}, later)
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name:     "expired entries are dropped on get",
+			capacity: 2,
+			run: func(c *geoLRU) {
+				c.Add("a", GeoLocationInfo{}, time.Now().Add(-time.Second))
+				c.Add("b", GeoLocationInfo{}, later)
+				c.Get("a")
+			},
+			want: []string{"b"},
+		},
+		{
+			name:     "remove",
+			capacity: 3,
+			run: func(c *geoLRU) {
+				c.Add("a", GeoLocationInfo{}, later)
+				c.Add("b", GeoLocationInfo{}, later)
+				c.Remove("a")
+				c.Remove("missing")
+			},
+			want: []string{"b"},
+		},
+		{
+			name:     "purge",
+			capacity: 3,
+			run: func(c *geoLRU) {
+				c.Add("a", GeoLocationInfo{}, later)
+				c.Add("b", GeoLocationInfo{}, later)
+				c.Purge()
+			},
+			want: []string{},
+		},
+		{
+			name:     "capacity below 1 keeps one entry",
+			capacity: 0,
+			run: func(c *geoLRU) {
+				c.Add("a", GeoLocationInfo{}, later)
+				c.Add("b", GeoLocationInfo{}, later)
+			},
+			want: []string{"b"},
+		},
+	}
+	for _, tt := range tests {
+		c := newGeoLRU(tt.capacity, time.Hour)
+		tt.run(c)
+		if got := geoLRUKeys(c); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: keys = %v, want %v", tt.name, got, tt.want)
+		}
+		if len(c.items) != c.ll.Len() {
+			t.Errorf("%s: %d items indexed for %d entries", tt.name, len(c.items), c.ll.Len())
+		}
+	}
+}
+
+func TestGeoLRUGet(t *testing.T) {
+	c := newGeoLRU(2, time.Hour)
+	later := time.Now().Add(time.Hour)
+	c.Add("1.2.3.0", GeoLocationInfo{CountryCode: "US"}, later)
+	c.Add("1.2.3.0", GeoLocationInfo{CountryCode: "VN"}, later)
+
+	info, ok := c.Get("1.2.3.0")
+	if !ok || info.CountryCode != "VN" {
+		t.Fatalf("Get = %+v, %v; want the updated entry", info, ok)
+	}
+	// The result is a copy
+	info.CountryCode = "JP"
+	if info, _ := c.Get("1.2.3.0"); info.CountryCode != "VN" {
+		t.Errorf("cached entry changed to %q", info.CountryCode)
+	}
+	if _, ok := c.Get("5.6.7.0"); ok {
+		t.Error("Get of a missing IP succeeded")
+	}
+}
+
+func TestForgetGeoLocationsEvicts(t *testing.T) {
+	defer func(cfg *config.Config, cache *geoLRU) {
+		config.AppConfig, geoCache = cfg, cache
+	}(config.AppConfig, geoCache)
+
+	tests := []struct {
+		mode   string
+		cached string // raw IP whose location was resolved
+		forget string // IP named by the erase request, raw or stored
+	}{
+		{"none", "203.0.113.7", "203.0.113.7"},
+		{"truncate", "203.0.113.7", "203.0.113.7"},
+		{"truncate", "203.0.113.7", "203.0.113.0"},
+		{"hash", "203.0.113.7", "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		config.AppConfig = &config.Config{PrivacyIPMode: tt.mode, PrivacyIPSalt: "salt"}
+		geoCache = newGeoLRU(10, time.Hour)
+		geoCache.Add(geoCacheKey(tt.cached), GeoLocationInfo{CountryCode: "VN"}, time.Now().Add(time.Hour))
+		if _, ok := cachedGeoLocation(tt.cached); !ok {
+			t.Errorf("%s: location of %s not cached", tt.mode, tt.cached)
+			continue
+		}
+		if tt.mode != "none" {
+			if _, ok := geoCache.items[tt.cached]; ok {
+				t.Errorf("%s: raw IP %s kept in memory", tt.mode, tt.cached)
+			}
+		}
+
+		if err := forgetGeoLocations([]string{tt.forget}); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := cachedGeoLocation(tt.cached); ok {
+			t.Errorf("%s: location of %s still cached after erasing %s", tt.mode, tt.cached, tt.forget)
+		}
+	}
+}
@@ -1,13 +1,20 @@
 package handlers
 
 import (
+	"admin-go/config"
+	"admin-go/geoip"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
 // GeoLocationInfo represents geographical location data
@@ -15,24 +22,130 @@ type GeoLocationInfo struct {
 	CountryCode string `json:"country_code"`
 	CountryName string `json:"country_name"`
 	City        string `json:"city"`
+	Region      string `json:"region"`
+	ASN         uint32 `json:"asn"`
+	Org         string `json:"org"`
+	IsHosting   bool   `json:"is_hosting"`
+	Provider    string `json:"-"` // provider that answered, recorded in geo_cache
 }
 
-// getGeoLocation retrieves geographical location information for an IP address
-// Returns countryCode, countryName, city
-func getGeoLocation(ip string) (string, string, string) {
-	// Handle localhost and private IPs
-	if isLocalOrPrivateIP(ip) {
-		return "LOCAL", "Local Network", "Local"
+// hostingASNs are the networks of the large cloud and hosting providers
+var hostingASNs = map[uint32]bool{
+	16509:  true, // Amazon AWS
+	14618:  true, // Amazon AWS
+	15169:  true, // Google
+	396982: true, // Google Cloud
+	8075:   true, // Microsoft Azure
+	14061:  true, // DigitalOcean
+	63949:  true, // Linode
+	20940:  true, // Akamai
+	20473:  true, // Vultr (Choopa)
+	16276:  true, // OVH
+	24940:  true, // Hetzner
+	51167:  true, // Contabo
+	45102:  true, // Alibaba Cloud
+	37963:  true, // Alibaba Cloud (Aliyun)
+	132203: true, // Tencent Cloud
+	31898:  true, // Oracle Cloud
+	13335:  true, // Cloudflare
+	60781:  true, // Leaseweb
+	28753:  true, // Leaseweb
+	12876:  true, // Scaleway
+	9009:   true, // M247
+	212238: true, // Datacamp
+	47583:  true, // Hostinger
+}
+
+// hostingOrgPatterns identify datacenter / cloud networks missing from
+// hostingASNs by whole words of their ASN organization, so "colo" does not
+// match "Telefonica Colombia"
+var hostingOrgPatterns = []string{
+	"amazon web services", "amazon technologies", "aws", "google cloud", "azure",
+	"digitalocean", "linode", "akamai", "vultr", "choopa", "ovh", "hetzner",
+	"contabo", "alibaba cloud", "aliyun", "tencent cloud", "oracle cloud", "cloudflare",
+	"leaseweb", "scaleway", "online s a s", "m247", "datacamp", "hostinger",
+	"bizfly", "vng cloud", "viettel idc", "fpt cloud", "hosting", "datacenter",
+	"data center", "vps", "colocation",
+}
+
+// isHostingNetwork guesses whether a network belongs to a hosting provider
+// from its ASN, then from whole words of its organization
+func isHostingNetwork(asn uint32, org string) bool {
+	if hostingASNs[asn] {
+		return true
+	}
+	words := strings.FieldsFunc(strings.ToLower(org), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return false
+	}
+	padded := " " + strings.Join(words, " ") + " "
+	for _, pattern := range hostingOrgPatterns {
+		if strings.Contains(padded, " "+pattern+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseASN converts "AS7552" or "AS7552 Viettel Group" into 7552
+func parseASN(s string) uint32 {
+	s = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "AS")
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		s = s[:i]
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint32(n)
+}
+
+// GeoProvider resolves an IP address to a location.
+// Lookup returns (nil, nil) when the provider simply has no data for the IP.
+type GeoProvider interface {
+	Name() string
+	Lookup(ip string) (*GeoLocationInfo, error)
+}
+
+var errGeoRateLimited = errors.New("geo provider rate limit exceeded")
+
+// geoProvider is the configured provider chain, set up by InitGeoProviders
+var geoProvider GeoProvider = &chainGeoProvider{}
+
+// InitGeoProviders builds the provider chain from GEO_PROVIDERS.
+// Providers are tried in order; a provider that fails to initialize is skipped.
+func InitGeoProviders() {
+	cfg := config.GetGeoConfig()
+	limiter := newRateLimiter(cfg.GeoHTTPRateLimit, time.Minute)
+
+	chain := &chainGeoProvider{}
+	for _, name := range cfg.GeoProviders {
+		switch name {
+		case "mmdb":
+			p, err := newMMDBGeoProvider(cfg.GeoMMDBPath, cfg.GeoASNMMDBPath)
+			if err != nil {
+				log.Printf("Warning: geo provider mmdb disabled: %v", err)
+				continue
+			}
+			chain.providers = append(chain.providers, p)
+		case "ip-api":
+			chain.providers = append(chain.providers, &rateLimitedGeoProvider{provider: &ipAPIGeoProvider{}, limiter: limiter})
+		case "ipapi.co", "ipapi":
+			chain.providers = append(chain.providers, &rateLimitedGeoProvider{provider: &ipapiCoGeoProvider{}, limiter: limiter})
+		default:
+			log.Printf("Warning: unknown geo provider %q ignored", name)
+		}
 	}
 
-	// Try to get geo info from cache or API
-	info := getGeoFromAPI(ip)
-	if info != nil {
-		return info.CountryCode, info.CountryName, info.City
+	names := []string{}
+	for _, p := range chain.providers {
+		names = append(names, p.Name())
 	}
+	log.Printf("Geolocation providers: [%s]", strings.Join(names, ", "))
 
-	// Return unknown if all methods fail
-	return "", "Unknown", ""
+	geoProvider = chain
 }
 
 // isLocalOrPrivateIP checks if an IP is localhost or in a private range
@@ -72,142 +185,249 @@ func isLocalOrPrivateIP(ip string) bool {
 	return parsedIP.IsLoopback() || parsedIP.IsPrivate()
 }
 
-// getGeoFromAPI fetches geo-location data from a free API service
-// Using ip-api.com (free, no API key required, 45 requests/minute limit)
-func getGeoFromAPI(ip string) *GeoLocationInfo {
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: 3 * time.Second,
+// chainGeoProvider tries each provider in order until one returns data
+type chainGeoProvider struct {
+	providers []GeoProvider
+}
+
+func (p *chainGeoProvider) Name() string {
+	return "chain"
+}
+
+func (p *chainGeoProvider) Lookup(ip string) (*GeoLocationInfo, error) {
+	var lastErr error
+	for _, provider := range p.providers {
+		info, err := provider.Lookup(ip)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if info != nil {
+			info.Provider = provider.Name()
+			return info, nil
+		}
 	}
+	return nil, lastErr
+}
 
-	// Use ip-api.com free service
-	url := fmt.Sprintf("http://ip-api.com/json/%s?fields=status,country,countryCode,city", ip)
+// mmdbGeoProvider looks up IPs in local MaxMind/DB-IP .mmdb files:
+// a City database and an optional ASN database
+type mmdbGeoProvider struct {
+	reader    *geoip.Reader
+	asnReader *geoip.Reader
+}
 
-	resp, err := client.Get(url)
+func newMMDBGeoProvider(path, asnPath string) (*mmdbGeoProvider, error) {
+	reader, err := geoip.Open(path)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	defer resp.Body.Close()
+	log.Printf("Loaded geo database %s (%s)", path, reader.Metadata.DatabaseType)
+
+	p := &mmdbGeoProvider{reader: reader}
+	if asnPath != "" {
+		asnReader, err := geoip.Open(asnPath)
+		if err != nil {
+			log.Printf("Warning: ASN database not loaded: %v", err)
+		} else {
+			log.Printf("Loaded ASN database %s (%s)", asnPath, asnReader.Metadata.DatabaseType)
+			p.asnReader = asnReader
+		}
+	}
+	return p, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil
+func (p *mmdbGeoProvider) Name() string {
+	return "mmdb"
+}
+
+func (p *mmdbGeoProvider) Lookup(ip string) (*GeoLocationInfo, error) {
+	parsedIP := net.ParseIP(ip)
+	record, err := p.reader.Lookup(parsedIP)
+	if err != nil || record == nil {
+		return nil, err
 	}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil
+	info := &GeoLocationInfo{
+		CountryCode: geoip.String(record, "country", "iso_code"),
+		CountryName: geoip.String(record, "country", "names", "en"),
+		City:        geoip.String(record, "city", "names", "en"),
+		Region:      geoip.String(record, "subdivisions", "names", "en"),
+	}
+	if info.CountryCode == "" && info.CountryName == "" {
+		return nil, nil
+	}
+
+	if p.asnReader != nil {
+		if asn, err := p.asnReader.Lookup(parsedIP); err == nil && asn != nil {
+			info.ASN = uint32(geoip.Uint(asn, "autonomous_system_number"))
+			info.Org = geoip.String(asn, "autonomous_system_organization")
+			info.IsHosting = isHostingNetwork(info.ASN, info.Org)
+		}
+	}
+	return info, nil
+}
+
+// rateLimitedGeoProvider guards a remote provider with a shared token bucket
+type rateLimitedGeoProvider struct {
+	provider GeoProvider
+	limiter  *rateLimiter
+}
+
+func (p *rateLimitedGeoProvider) Name() string {
+	return p.provider.Name()
+}
+
+func (p *rateLimitedGeoProvider) Lookup(ip string) (*GeoLocationInfo, error) {
+	if !p.limiter.Allow() {
+		return nil, errGeoRateLimited
+	}
+	return p.provider.Lookup(ip)
+}
+
+// rateLimiter is a simple token bucket allowing limit events per interval
+type rateLimiter struct {
+	mu       sync.Mutex
+	tokens   float64
+	capacity float64
+	rate     float64 // tokens per second
+	last     time.Time
+}
+
+func newRateLimiter(limit int, interval time.Duration) *rateLimiter {
+	if limit < 1 {
+		limit = 1
+	}
+	return &rateLimiter{
+		tokens:   float64(limit),
+		capacity: float64(limit),
+		rate:     float64(limit) / interval.Seconds(),
+		last:     time.Now(),
 	}
+}
+
+func (l *rateLimiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.capacity {
+		l.tokens = l.capacity
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// ipAPIGeoProvider uses ip-api.com (free, no API key required, 45 requests/minute limit)
+type ipAPIGeoProvider struct{}
+
+func (p *ipAPIGeoProvider) Name() string {
+	return "ip-api"
+}
+
+func (p *ipAPIGeoProvider) Lookup(ip string) (*GeoLocationInfo, error) {
+	url := fmt.Sprintf("http://ip-api.com/json/%s?fields=status,country,countryCode,regionName,city,isp,org,as,hosting", ip)
 
 	var result struct {
 		Status      string `json:"status"`
 		Country     string `json:"country"`
 		CountryCode string `json:"countryCode"`
+		RegionName  string `json:"regionName"`
 		City        string `json:"city"`
+		ISP         string `json:"isp"`
+		Org         string `json:"org"`
+		AS          string `json:"as"`
+		Hosting     bool   `json:"hosting"`
 	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil
+	if err := fetchGeoJSON(url, &result); err != nil {
+		return nil, err
 	}
 
 	if result.Status != "success" {
-		return nil
+		return nil, nil
+	}
+
+	org := result.ISP
+	if org == "" {
+		org = result.Org
 	}
 
 	return &GeoLocationInfo{
 		CountryCode: result.CountryCode,
 		CountryName: result.Country,
 		City:        result.City,
-	}
+		Region:      result.RegionName,
+		ASN:         parseASN(result.AS),
+		Org:         org,
+		IsHosting:   result.Hosting, // ip-api classifies hosting networks itself
+	}, nil
 }
 
-// Alternative: getGeoFromIPAPI uses ipapi.co (requires API key for production)
-func getGeoFromIPAPI(ip string) *GeoLocationInfo {
-	client := &http.Client{
-		Timeout: 3 * time.Second,
-	}
-
-	// Free tier: 1000 requests/day, no API key required
-	// For production, get API key from https://ipapi.co/
-	url := fmt.Sprintf("https://ipapi.co/%s/json/", ip)
+// ipapiCoGeoProvider uses ipapi.co (free tier: 1000 requests/day, API key for production)
+type ipapiCoGeoProvider struct{}
 
-	resp, err := client.Get(url)
-	if err != nil {
-		return nil
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return nil
-	}
+func (p *ipapiCoGeoProvider) Name() string {
+	return "ipapi.co"
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil
-	}
+func (p *ipapiCoGeoProvider) Lookup(ip string) (*GeoLocationInfo, error) {
+	url := fmt.Sprintf("https://ipapi.co/%s/json/", ip)
 
 	var result struct {
 		CountryCode string `json:"country_code"`
 		CountryName string `json:"country_name"`
 		City        string `json:"city"`
+		Region      string `json:"region"`
+		ASN         string `json:"asn"`
+		Org         string `json:"org"`
 		Error       bool   `json:"error"`
 	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil
+	if err := fetchGeoJSON(url, &result); err != nil {
+		return nil, err
 	}
 
 	if result.Error {
-		return nil
+		return nil, nil
 	}
 
+	asn := parseASN(result.ASN)
 	return &GeoLocationInfo{
 		CountryCode: result.CountryCode,
 		CountryName: result.CountryName,
 		City:        result.City,
-	}
+		Region:      result.Region,
+		ASN:         asn,
+		Org:         result.Org,
+		IsHosting:   isHostingNetwork(asn, result.Org),
+	}, nil
 }
 
-/*
-PRODUCTION RECOMMENDATIONS:
-
-1. Use MaxMind GeoIP2 Database (Recommended for production):
-   - Download GeoLite2-City.mmdb from https://dev.maxmind.com/geoip/geolite2-free-geolocation-data
-   - Install: go get github.com/oschwald/geoip2-golang
-   - Example:
-
-     import "github.com/oschwald/geoip2-golang"
-
-     db, _ := geoip2.Open("GeoLite2-City.mmdb")
-     defer db.Close()
-     record, _ := db.City(net.ParseIP(ip))
-     countryCode := record.Country.IsoCode
-     countryName := record.Country.Names["en"]
-     city := record.City.Names["en"]
-
-2. Use IP2Location Database:
-   - Download from https://lite.ip2location.com/
-   - Install: go get github.com/ip2location/ip2location-go
-   - Provides offline lookup with regular updates
+// fetchGeoJSON performs a GET request with a short timeout and decodes the JSON body
+func fetchGeoJSON(url string, out interface{}) error {
+	client := &http.Client{
+		Timeout: 3 * time.Second,
+	}
 
-3. Use Redis Cache:
-   - Cache geo-location results to reduce API calls
-   - Set TTL to 24 hours or longer
-   - Example:
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
 
-     key := "geo:" + ip
-     if cached, err := redis.Get(key); err == nil {
-         return parseCachedGeo(cached)
-     }
-     info := getGeoFromAPI(ip)
-     redis.Set(key, serializeGeo(info), 24*time.Hour)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("geo provider returned status %d", resp.StatusCode)
+	}
 
-4. Rate Limiting:
-   - Implement rate limiting for API calls
-   - Use token bucket or leaky bucket algorithm
-   - Fallback to "Unknown" when rate limit exceeded
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
 
-5. Batch Processing:
-   - Process geo-location in background job
-   - Update visitor_logs asynchronously
-   - Reduces impact on tracking performance
-*/
+	return json.Unmarshal(body, out)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestIsHostingNetwork(t *testing.T) {
+	tests := []struct {
+		asn  uint32
+		org  string
+		want bool
+	}{
+		{16509, "", true},
+		{0, "DigitalOcean, LLC", true},
+		{0, "Hetzner Online GmbH", true},
+		{0, "Viettel IDC", true},
+		{0, "ACME Hosting Ltd", true},
+		{0, "Online S.A.S.", true},
+		{0, "Telefonica Colombia", false},
+		{0, "Colombia Movil", false},
+		{0, "Viettel Group", false},
+		{0, "Google Fiber Inc.", false},
+		{0, "Microsoft Corporation", false},
+		{0, "Serverius", false},
+		{0, "", false},
+	}
+	for _, tt := range tests {
+		if got := isHostingNetwork(tt.asn, tt.org); got != tt.want {
+			t.Errorf("isHostingNetwork(%d, %q) = %v, want %v", tt.asn, tt.org, got, tt.want)
+		}
+	}
+}
+
+func TestParseASN(t *testing.T) {
+	tests := []struct {
+		in   string
+		want uint32
+	}{
+		{"AS7552", 7552},
+		{"AS7552 Viettel Group", 7552},
+		{" as18403 FPT ", 18403},
+		{"7552", 7552},
+		{"", 0},
+		{"ASX", 0},
+	}
+	for _, tt := range tests {
+		if got := parseASN(tt.in); got != tt.want {
+			t.Errorf("parseASN(%q) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	tests := []struct {
+		limit   int
+		elapsed time.Duration // since the bucket was emptied
+		want    int           // requests then allowed in a row
+	}{
+		{3, 0, 0},
+		{3, 20 * time.Minute, 1},
+		{3, 40 * time.Minute, 2},
+		{3, time.Hour, 3},
+		{3, 5 * time.Hour, 3}, // refills up to capacity only
+		{0, time.Hour, 1},     // a limit below 1 allows one request
+	}
+	for _, tt := range tests {
+		l := newRateLimiter(tt.limit, time.Hour)
+		for l.Allow() {
+		}
+		l.last = l.last.Add(-tt.elapsed)
+
+		got := 0
+		for l.Allow() {
+			got++
+		}
+		if got != tt.want {
+			t.Errorf("limit %d after %v: allowed %d, want %d", tt.limit, tt.elapsed, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiterStartsFull(t *testing.T) {
+	l := newRateLimiter(3, time.Hour)
+	for i := 0; i < 3; i++ {
+		if !l.Allow() {
+			t.Fatalf("request %d denied", i+1)
+		}
+	}
+	if l.Allow() {
+		t.Error("request 4 allowed")
+	}
+}
@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"admin-go/models"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxJourneyPageViews caps the page views returned for a single visitor
+const maxJourneyPageViews = 5000
+
+// GetVisitorJourney returns every session of a visitor across days with the
+// ordered page sequence, durations, referrer, device and geo
+func GetVisitorJourney(c *gin.Context) {
+	visitorId := strings.TrimSpace(c.Param("visitor_id"))
+	if visitorId == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "visitor_id is required"})
+		return
+	}
+
+	// Visits of the visitor plus visits (shared IP on the same day) that
+	// recorded some of its page views
+	rows, err := models.DB.Query(`
+		SELECT id, ip_address, COALESCE(visitor_id, ''), COALESCE(session_id, ''), COALESCE(page_path, '/'),
+		       COALESCE(referrer, ''), COALESCE(device_type, ''), COALESCE(os, ''), COALESCE(browser, ''),
+		       COALESCE(screen_resolution, ''), COALESCE(country_code, ''), COALESCE(country_name, ''),
+		       COALESCE(city, ''), COALESCE(region, ''), COALESCE(asn, 0), COALESCE(org, ''),
+		       COALESCE(is_hosting, 0), visit_time, COALESCE(duration, 0), is_new_visitor, page_view_count,
+		       COALESCE(visited_pages, '[]'), last_visit_time
+		FROM visitor_logs
+		WHERE visitor_id = ? OR id IN (SELECT DISTINCT log_id FROM page_views WHERE visitor_id = ?)
+		ORDER BY visit_time`, visitorId, visitorId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	visits := map[int64]models.VisitorLog{}
+	visitOrder := []int64{}
+	for rows.Next() {
+		var v models.VisitorLog
+		if rows.Scan(&v.ID, &v.IpAddress, &v.VisitorId, &v.SessionId, &v.PagePath,
+			&v.Referrer, &v.DeviceType, &v.OS, &v.Browser,
+			&v.ScreenResolution, &v.CountryCode, &v.CountryName,
+			&v.City, &v.Region, &v.ASN, &v.Org,
+			&v.IsHosting, &v.VisitTime, &v.Duration, &v.IsNewVisitor, &v.PageViewCount,
+			&v.VisitedPages, &v.LastVisitTime) != nil {
+			continue
+		}
+		visits[v.ID] = v
+		visitOrder = append(visitOrder, v.ID)
+	}
+
+	if len(visits) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Visitor not found"})
+		return
+	}
+
+	pageRows, err := models.DB.Query(`
+		SELECT id, log_id, COALESCE(session_id, ''), page_path, COALESCE(page_type, ''),
+		       COALESCE(reference_id, ''), COALESCE(referrer, ''), viewed_at, COALESCE(duration, 0),
+		       article_id, COALESCE(scroll_depth, 0)
+		FROM page_views
+		WHERE visitor_id = ?
+		ORDER BY viewed_at, id
+		LIMIT ?`, visitorId, maxJourneyPageViews+1)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer pageRows.Close()
+
+	pageViews := []models.PageView{}
+	for pageRows.Next() {
+		var p models.PageView
+		if pageRows.Scan(&p.ID, &p.LogID, &p.SessionId, &p.PagePath, &p.PageType,
+			&p.ReferenceId, &p.Referrer, &p.ViewedAt, &p.Duration, &p.ArticleId, &p.ScrollDepth) == nil {
+			pageViews = append(pageViews, p)
+		}
+	}
+	truncated := len(pageViews) > maxJourneyPageViews
+	if truncated {
+		pageViews = pageViews[:maxJourneyPageViews]
+	}
+
+	// Group page views into sessions, keeping the order they started in
+	sessions := []map[string]interface{}{}
+	sessionIndex := map[string]int{}
+	coveredVisits := map[int64]bool{}
+	for _, p := range pageViews {
+		key := p.SessionId
+		if key == "" {
+			key = "visit-" + strconv.FormatInt(p.LogID, 10)
+		}
+
+		i, ok := sessionIndex[key]
+		if !ok {
+			visit := visits[p.LogID]
+			session := journeySession(visit, p.SessionId)
+			session["started_at"] = p.ViewedAt
+			session["referrer"] = p.Referrer
+			i = len(sessions)
+			sessionIndex[key] = i
+			sessions = append(sessions, session)
+		}
+		coveredVisits[p.LogID] = true
+
+		session := sessions[i]
+		session["pages"] = append(session["pages"].([]map[string]interface{}), map[string]interface{}{
+			"page":         p.PagePath,
+			"page_type":    p.PageType,
+			"reference_id": p.ReferenceId,
+			"viewed_at":    p.ViewedAt,
+			"duration":     p.Duration,
+			"article_id":   p.ArticleId,
+			"scroll_depth": p.ScrollDepth,
+		})
+		session["ended_at"] = p.ViewedAt
+		session["page_views"] = session["page_views"].(int) + 1
+		session["duration"] = session["duration"].(int) + p.Duration
+	}
+
+	// Visits tracked before page views were recorded only know their
+	// distinct pages, in first-visit order
+	for _, id := range visitOrder {
+		visit := visits[id]
+		if coveredVisits[id] || visit.VisitorId != visitorId {
+			continue
+		}
+
+		var paths []string
+		json.Unmarshal([]byte(visit.VisitedPages), &paths)
+		if len(paths) == 0 {
+			paths = []string{visit.PagePath}
+		}
+
+		session := journeySession(visit, visit.SessionId)
+		for _, path := range paths {
+			session["pages"] = append(session["pages"].([]map[string]interface{}), map[string]interface{}{
+				"page":      path,
+				"viewed_at": nil,
+			})
+		}
+		session["started_at"] = visit.VisitTime
+		session["ended_at"] = visit.LastVisitTime
+		session["referrer"] = visit.Referrer
+		session["page_views"] = visit.PageViewCount
+		session["duration"] = visit.Duration
+		session["legacy"] = true
+		sessions = append(sessions, session)
+	}
+
+	// Chronological order across both sources
+	sort.SliceStable(sessions, func(i, j int) bool {
+		return sessions[i]["started_at"].(time.Time).Before(sessions[j]["started_at"].(time.Time))
+	})
+
+	var totalPageViews, totalDuration int
+	var firstSeen, lastSeen time.Time
+	for i, session := range sessions {
+		totalPageViews += session["page_views"].(int)
+		totalDuration += session["duration"].(int)
+		if i == 0 {
+			firstSeen = session["started_at"].(time.Time)
+		}
+		if ended := session["ended_at"].(time.Time); ended.After(lastSeen) {
+			lastSeen = ended
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"visitor_id":       visitorId,
+			"first_seen":       firstSeen,
+			"last_seen":        lastSeen,
+			"total_sessions":   len(sessions),
+			"total_page_views": totalPageViews,
+			"total_duration":   totalDuration,
+			"truncated":        truncated,
+			"sessions":         sessions,
+		},
+	})
+}
+
+// journeySession builds an empty session carrying the device and geo of a visit
+func journeySession(visit models.VisitorLog, sessionId string) map[string]interface{} {
+	return map[string]interface{}{
+		"session_id":     sessionId,
+		"visit_id":       visit.ID,
+		"ip":             visit.IpAddress,
+		"device":         visit.DeviceType,
+		"os":             visit.OS,
+		"browser":        visit.Browser,
+		"screen":         visit.ScreenResolution,
+		"country_code":   visit.CountryCode,
+		"country":        visit.CountryName,
+		"city":           visit.City,
+		"region":         visit.Region,
+		"asn":            visit.ASN,
+		"org":            visit.Org,
+		"is_hosting":     visit.IsHosting,
+		"is_new_visitor": visit.IsNewVisitor,
+		"page_views":     0,
+		"duration":       0,
+		"pages":          []map[string]interface{}{},
+	}
+}
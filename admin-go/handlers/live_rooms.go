@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"admin-go/models"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// liveViewerTimeout is how long after their last heartbeat (sent every 30s)
+// a viewer still counts as watching; longer gaps add no watch time
+const liveViewerTimeout = 90 * time.Second
+
+// liveMatchSorts maps the sort parameter of the live match report to its column
+var liveMatchSorts = map[string]string{
+	"viewers":    "viewers",
+	"peak":       "peak",
+	"watch_time": "avg_watch_seconds",
+	"started":    "started_at",
+}
+
+// recordLiveViewer tracks a visitor of a live match room. The time since the
+// viewer's previous pageview/heartbeat counts as watch time when they were
+// watching (tab visible) and the gap is within liveViewerTimeout.
+func recordLiveViewer(req models.TrackingRequest, now time.Time) {
+	if req.PageType != "live" || req.ReferenceId == "" || req.VisitorId == "" {
+		return
+	}
+	watching := req.Status != "leave" && req.Status != "inactive" && req.Event != "visibility_hidden"
+
+	trackExec(`
+		INSERT INTO live_viewers (match_id, visitor_id, first_seen_at, last_seen_at, watch_seconds, is_watching)
+		VALUES (?, ?, ?, ?, 0, ?)
+		ON DUPLICATE KEY UPDATE
+		    watch_seconds = watch_seconds + IF(
+		        is_watching = 1 AND TIMESTAMPDIFF(SECOND, last_seen_at, VALUES(last_seen_at)) BETWEEN 0 AND ?,
+		        TIMESTAMPDIFF(SECOND, last_seen_at, VALUES(last_seen_at)), 0),
+		    last_seen_at = VALUES(last_seen_at),
+		    is_watching = VALUES(is_watching)`,
+		req.ReferenceId, req.VisitorId, now, now, watching, int(liveViewerTimeout.Seconds()))
+}
+
+// StartLiveSampler records the number of concurrent viewers of every live
+// match room every LIVE_SAMPLE_INTERVAL seconds
+func StartLiveSampler() {
+	interval := time.Duration(config.GetStatsConfig().LiveSampleInterval) * time.Second
+	if interval <= 0 {
+		log.Println("Live match sampler disabled")
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if models.DB == nil {
+				continue
+			}
+			if err := sampleLiveRooms(now.Truncate(interval)); err != nil {
+				log.Printf("Live match sampler error: %v", err)
+			}
+		}
+	}()
+}
+
+// sampleLiveRooms stores the current viewers of each room with anyone watching
+func sampleLiveRooms(sampleTime time.Time) error {
+	_, err := models.DB.Exec(`
+		INSERT INTO live_samples (match_id, sample_time, viewers)
+		SELECT match_id, ?, COUNT(*)
+		FROM live_viewers
+		WHERE is_watching = 1 AND last_seen_at >= ?
+		GROUP BY match_id
+		ON DUPLICATE KEY UPDATE viewers = VALUES(viewers)`,
+		sampleTime, sampleTime.Add(-liveViewerTimeout))
+	return err
+}
+
+// GetLiveMatches lists the matches watched in a date range by audience:
+// unique viewers, peak concurrency, average and total watch time
+// (sort=viewers|peak|watch_time|started)
+func GetLiveMatches(c *gin.Context) {
+	sortColumn, ok := liveMatchSorts[c.DefaultQuery("sort", "viewers")]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid sort column"})
+		return
+	}
+
+	r, err := queryRange(c, 30)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	rows, err := models.DB.Query(`
+		SELECT v.match_id, COUNT(*) as viewers,
+		       COALESCE(AVG(v.watch_seconds), 0) as avg_watch_seconds, COALESCE(SUM(v.watch_seconds), 0),
+		       MIN(v.first_seen_at) as started_at, MAX(v.last_seen_at),
+		       COALESCE(MAX(s.peak), 0) as peak
+		FROM live_viewers v
+		LEFT JOIN (
+			SELECT match_id, MAX(viewers) as peak FROM live_samples
+			WHERE sample_time >= ? AND sample_time < ?
+			GROUP BY match_id
+		) s ON s.match_id = v.match_id
+		WHERE v.first_seen_at < ? AND v.last_seen_at >= ?
+		GROUP BY v.match_id
+		ORDER BY `+sortColumn+` DESC, viewers DESC
+		LIMIT ?`, r.From, r.To, r.To, r.From, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	matches := []map[string]interface{}{}
+	for rows.Next() {
+		var matchId string
+		var viewers, totalWatchSeconds, peak int
+		var avgWatchSeconds float64
+		var startedAt, endedAt time.Time
+		if rows.Scan(&matchId, &viewers, &avgWatchSeconds, &totalWatchSeconds, &startedAt, &endedAt, &peak) != nil {
+			continue
+		}
+		matches = append(matches, map[string]interface{}{
+			"match_id":            matchId,
+			"unique_viewers":      viewers,
+			"peak_viewers":        peak,
+			"avg_watch_seconds":   int(avgWatchSeconds + 0.5),
+			"total_watch_seconds": totalWatchSeconds,
+			"started_at":          startedAt.In(r.From.Location()),
+			"ended_at":            endedAt.In(r.From.Location()),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"range":   r.JSON(),
+			"matches": matches,
+		},
+	})
+}
+
+// GetLiveMatchStats returns the audience of one match room with its
+// concurrent viewer time series
+func GetLiveMatchStats(c *gin.Context) {
+	matchId := c.Param("match_id")
+
+	loc, err := reportLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	var viewers, totalWatchSeconds, watchingNow int
+	var avgWatchSeconds float64
+	var startedAt, endedAt *time.Time
+	err = models.DB.QueryRow(`
+		SELECT COUNT(*), COALESCE(AVG(watch_seconds), 0), COALESCE(SUM(watch_seconds), 0),
+		       MIN(first_seen_at), MAX(last_seen_at),
+		       COALESCE(SUM(is_watching = 1 AND last_seen_at >= ?), 0)
+		FROM live_viewers
+		WHERE match_id = ?`, time.Now().Add(-liveViewerTimeout), matchId).
+		Scan(&viewers, &avgWatchSeconds, &totalWatchSeconds, &startedAt, &endedAt, &watchingNow)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if viewers == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "No viewers recorded for this match"})
+		return
+	}
+
+	rows, err := models.DB.Query(`
+		SELECT sample_time, viewers FROM live_samples
+		WHERE match_id = ?
+		ORDER BY sample_time`, matchId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	samples := []map[string]interface{}{}
+	peak := 0
+	var peakAt *time.Time
+	for rows.Next() {
+		var sampleTime time.Time
+		var count int
+		if rows.Scan(&sampleTime, &count) != nil {
+			continue
+		}
+		sampleTime = sampleTime.In(loc)
+		if count > peak {
+			peak = count
+			peakAt = &sampleTime
+		}
+		samples = append(samples, map[string]interface{}{"time": sampleTime, "viewers": count})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"match_id":            matchId,
+			"unique_viewers":      viewers,
+			"current_viewers":     watchingNow,
+			"peak_viewers":        peak,
+			"peak_at":             peakAt,
+			"avg_watch_seconds":   int(avgWatchSeconds + 0.5),
+			"total_watch_seconds": totalWatchSeconds,
+			"started_at":          startedAt,
+			"ended_at":            endedAt,
+			"samples":             samples,
+		},
+	})
+}
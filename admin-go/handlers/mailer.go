@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mailMessage is an email sent through the configured SMTP server
+type mailMessage struct {
+	To      []string
+	Subject string
+	Body    string
+	HTML    bool
+}
+
+// sendMail delivers a message with SMTP_HOST. STARTTLS is used when the server
+// offers it; authentication only when SMTP_USERNAME is set.
+func sendMail(msg mailMessage) error {
+	cfg := config.GetMailConfig()
+	if cfg.SMTPHost == "" {
+		return errors.New("SMTP_HOST is not configured")
+	}
+	if len(msg.To) == 0 {
+		return errors.New("no recipients")
+	}
+
+	from := cfg.SMTPFrom
+	if from == "" {
+		from = cfg.SMTPUsername
+	}
+
+	contentType := "text/plain; charset=UTF-8"
+	if msg.HTML {
+		contentType = "text/html; charset=UTF-8"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(msg.To, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: %s\r\n", contentType)
+	b.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	body, err := encodeMailBody(msg.Body)
+	if err != nil {
+		return err
+	}
+	b.WriteString(body)
+
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+	addr := cfg.SMTPHost + ":" + strconv.Itoa(cfg.SMTPPort)
+	return smtp.SendMail(addr, auth, from, msg.To, []byte(b.String()))
+}
+
+// encodeMailBody encodes a body as quoted-printable with CRLF line breaks.
+// Lines are wrapped at 76 characters, well within the 998 SMTP servers
+// accept, however long the rows of a report are.
+func encodeMailBody(body string) (string, error) {
+	var b strings.Builder
+	w := quotedprintable.NewWriter(&b)
+	if _, err := w.Write([]byte(body)); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
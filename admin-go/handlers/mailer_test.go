@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"io"
+	"mime/quotedprintable"
+	"strings"
+	"testing"
+)
+
+func TestEncodeMailBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string // decoded, with CRLF line breaks
+	}{
+		{"plain", "Hello", "Hello"},
+		{"line breaks", "a\nb\r\nc", "a\r\nb\r\nc"},
+		{"utf-8", "Báo cáo tuần: bóng đá", "Báo cáo tuần: bóng đá"},
+		{"equals sign", `<td style="width:50%">=</td>`, `<td style="width:50%">=</td>`},
+		{"long line", "<tr><td>" + strings.Repeat("Tiêu đề rất dài ", 200) + "</td></tr>", "<tr><td>" + strings.Repeat("Tiêu đề rất dài ", 200) + "</td></tr>"},
+	}
+	for _, tt := range tests {
+		encoded, err := encodeMailBody(tt.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range strings.Split(encoded, "\r\n") {
+			if len(line) > 76 {
+				t.Errorf("%s: encoded line of %d characters", tt.name, len(line))
+				break
+			}
+		}
+		if strings.Contains(strings.ReplaceAll(encoded, "\r\n", ""), "\n") {
+			t.Errorf("%s: bare LF in %q", tt.name, encoded)
+		}
+		decoded, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(encoded)))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if string(decoded) != tt.want {
+			t.Errorf("%s: decoded %q, want %q", tt.name, decoded, tt.want)
+		}
+	}
+}
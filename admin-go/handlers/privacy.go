@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"admin-go/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PrivacyRequest struct {
+	VisitorId string `json:"visitor_id"`
+	IpAddress string `json:"ip_address"`
+}
+
+// isTrackingOptOut reports whether the browser asked not to be tracked
+// via Do-Not-Track or Global Privacy Control
+func isTrackingOptOut(c *gin.Context) bool {
+	if !config.GetPrivacyConfig().PrivacyHonorDNT {
+		return false
+	}
+	return c.GetHeader("DNT") == "1" || c.GetHeader("Sec-GPC") == "1"
+}
+
+// anonymizeIP converts a raw IP into the form stored in visitor_logs.
+// Geo lookups must be done on the raw IP before calling this. Hash mode
+// requires PRIVACY_IP_SALT, which config.Load enforces.
+func anonymizeIP(ip string) string {
+	cfg := config.GetPrivacyConfig()
+
+	switch cfg.PrivacyIPMode {
+	case "truncate":
+		return truncateIP(ip)
+	case "hash":
+		mac := hmac.New(sha256.New, []byte(cfg.PrivacyIPSalt))
+		mac.Write([]byte(ip))
+		return "h:" + hex.EncodeToString(mac.Sum(nil))[:32]
+	default:
+		return ip
+	}
+}
+
+// truncateIP zeroes the host part of an address (/24 for IPv4, /48 for IPv6)
+func truncateIP(ip string) string {
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return ip
+	}
+	if v4 := parsedIP.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return parsedIP.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// ipIdentifiesVisitor reports whether a stored IP can single out one
+// visitor. Truncated IPs are shared by a whole /24 (/48 for IPv6), so with
+// PRIVACY_IP_MODE=truncate requests must name a visitor_id.
+func ipIdentifiesVisitor() bool {
+	return config.GetPrivacyConfig().PrivacyIPMode != "truncate"
+}
+
+// visitorColumn is the visitor_logs column that tells visitors apart for
+// daily visits and unique visitor counts: the stored IP, or visitor_id when
+// truncated IPs are shared by many visitors
+func visitorColumn() string {
+	if ipIdentifiesVisitor() {
+		return "ip_address"
+	}
+	return "visitor_id"
+}
+
+// validatePrivacyRequest returns an error message for a request that does
+// not identify exactly one visitor
+func validatePrivacyRequest(req PrivacyRequest) string {
+	if req.VisitorId == "" && req.IpAddress == "" {
+		return "visitor_id or ip_address is required"
+	}
+	if req.VisitorId == "" && !ipIdentifiesVisitor() {
+		return "IP addresses are stored truncated (PRIVACY_IP_MODE=truncate) and shared by other visitors; visitor_id is required"
+	}
+	return ""
+}
+
+// privacyWhereClause builds the condition matching every row that belongs
+// to the given visitor_id and/or IP, in raw or anonymized form. In truncate
+// mode only rows stored before truncation was enabled are matched by IP,
+// since the truncated form belongs to the whole network.
+func privacyWhereClause(req PrivacyRequest) (string, []interface{}) {
+	conditions := []string{}
+	args := []interface{}{}
+
+	if req.VisitorId != "" {
+		conditions = append(conditions, "visitor_id = ?")
+		args = append(args, req.VisitorId)
+	}
+
+	if req.IpAddress != "" {
+		stored := anonymizeIP(req.IpAddress)
+		if ipIdentifiesVisitor() {
+			conditions = append(conditions, "ip_address IN (?, ?)")
+			args = append(args, req.IpAddress, stored)
+		} else if stored != req.IpAddress {
+			conditions = append(conditions, "ip_address = ?")
+			args = append(args, req.IpAddress)
+		}
+	}
+
+	if len(conditions) == 0 {
+		return "(1 = 0)", args
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// pageViewsWhereClause matches the page views of the visitor_logs rows
+// selected by whereClause as well as those recorded under the visitor_id
+func pageViewsWhereClause(req PrivacyRequest, whereClause string, args []interface{}) (string, []interface{}) {
+	clause := "log_id IN (SELECT id FROM visitor_logs WHERE " + whereClause + ")"
+	pageArgs := append([]interface{}{}, args...)
+	if req.VisitorId != "" {
+		clause += " OR visitor_id = ?"
+		pageArgs = append(pageArgs, req.VisitorId)
+	}
+	return clause, pageArgs
+}
+
+// ExportVisitorData returns all stored tracking data for a visitor_id or IP
+func ExportVisitorData(c *gin.Context) {
+	req := PrivacyRequest{
+		VisitorId: strings.TrimSpace(c.Query("visitor_id")),
+		IpAddress: strings.TrimSpace(c.Query("ip")),
+	}
+	if msg := validatePrivacyRequest(req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": msg})
+		return
+	}
+
+	whereClause, args := privacyWhereClause(req)
+	rows, err := models.DB.Query(`
+		SELECT id, ip_address, COALESCE(visitor_id, ''), COALESCE(session_id, ''), page_path,
+		       COALESCE(page_type, ''), COALESCE(reference_id, ''), COALESCE(referrer, ''),
+		       COALESCE(user_agent, ''), COALESCE(device_type, ''), COALESCE(os, ''), COALESCE(browser, ''),
+		       COALESCE(screen_resolution, ''), COALESCE(country_code, ''), COALESCE(country_name, ''),
+		       COALESCE(city, ''), COALESCE(region, ''), COALESCE(asn, 0), COALESCE(org, ''),
+		       COALESCE(is_hosting, 0), visit_time, COALESCE(duration, 0), is_new_visitor, page_view_count,
+		       COALESCE(visited_pages, ''), last_visit_time
+		FROM visitor_logs
+		WHERE `+whereClause+`
+		ORDER BY visit_time`, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	records := []models.VisitorLog{}
+	for rows.Next() {
+		var v models.VisitorLog
+		if err := rows.Scan(&v.ID, &v.IpAddress, &v.VisitorId, &v.SessionId, &v.PagePath,
+			&v.PageType, &v.ReferenceId, &v.Referrer,
+			&v.UserAgent, &v.DeviceType, &v.OS, &v.Browser,
+			&v.ScreenResolution, &v.CountryCode, &v.CountryName,
+			&v.City, &v.Region, &v.ASN, &v.Org,
+			&v.IsHosting, &v.VisitTime, &v.Duration, &v.IsNewVisitor, &v.PageViewCount,
+			&v.VisitedPages, &v.LastVisitTime); err != nil {
+			exportFailed(c)
+			return
+		}
+		records = append(records, v)
+	}
+	if rows.Err() != nil {
+		exportFailed(c)
+		return
+	}
+
+	pageViews := []models.PageView{}
+	pageWhere, pageArgs := pageViewsWhereClause(req, whereClause, args)
+	pageRows, err := models.DB.Query(`
+		SELECT id, log_id, COALESCE(visitor_id, ''), COALESCE(session_id, ''), page_path, COALESCE(page_type, ''),
+		       COALESCE(reference_id, ''), COALESCE(referrer, ''), viewed_at, COALESCE(duration, 0),
+		       article_id, COALESCE(scroll_depth, 0)
+		FROM page_views
+		WHERE `+pageWhere+`
+		ORDER BY viewed_at, id`, pageArgs...)
+	if err != nil {
+		exportFailed(c)
+		return
+	}
+	defer pageRows.Close()
+	for pageRows.Next() {
+		var p models.PageView
+		if err := pageRows.Scan(&p.ID, &p.LogID, &p.VisitorId, &p.SessionId, &p.PagePath, &p.PageType,
+			&p.ReferenceId, &p.Referrer, &p.ViewedAt, &p.Duration, &p.ArticleId, &p.ScrollDepth); err != nil {
+			exportFailed(c)
+			return
+		}
+		pageViews = append(pageViews, p)
+	}
+	if pageRows.Err() != nil {
+		exportFailed(c)
+		return
+	}
+
+	events := []models.VisitorEvent{}
+	liveViewing := []gin.H{}
+	if req.VisitorId != "" {
+		eventRows, err := models.DB.Query(`
+			SELECT id, COALESCE(visitor_id, ''), COALESCE(session_id, ''), event_name, COALESCE(page_path, ''),
+			       COALESCE(page_type, ''), COALESCE(reference_id, ''), created_at
+			FROM visitor_events
+			WHERE visitor_id = ?
+			ORDER BY created_at, id`, req.VisitorId)
+		if err != nil {
+			exportFailed(c)
+			return
+		}
+		defer eventRows.Close()
+		for eventRows.Next() {
+			var e models.VisitorEvent
+			if err := eventRows.Scan(&e.ID, &e.VisitorId, &e.SessionId, &e.EventName, &e.PagePath,
+				&e.PageType, &e.ReferenceId, &e.CreatedAt); err != nil {
+				exportFailed(c)
+				return
+			}
+			events = append(events, e)
+		}
+		if eventRows.Err() != nil {
+			exportFailed(c)
+			return
+		}
+
+		liveRows, err := models.DB.Query(`
+			SELECT match_id, first_seen_at, last_seen_at, watch_seconds
+			FROM live_viewers
+			WHERE visitor_id = ?
+			ORDER BY first_seen_at`, req.VisitorId)
+		if err != nil {
+			exportFailed(c)
+			return
+		}
+		defer liveRows.Close()
+		for liveRows.Next() {
+			var matchId string
+			var firstSeen, lastSeen time.Time
+			var watchSeconds int
+			if err := liveRows.Scan(&matchId, &firstSeen, &lastSeen, &watchSeconds); err != nil {
+				exportFailed(c)
+				return
+			}
+			liveViewing = append(liveViewing, gin.H{
+				"match_id":      matchId,
+				"first_seen_at": firstSeen,
+				"last_seen_at":  lastSeen,
+				"watch_seconds": watchSeconds,
+			})
+		}
+		if liveRows.Err() != nil {
+			exportFailed(c)
+			return
+		}
+	}
+
+	if c.Query("download") == "1" {
+		c.Header("Content-Disposition", "attachment; filename=visitor-data.json")
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"visitor_id":    req.VisitorId,
+			"ip_address":    req.IpAddress,
+			"visitor_logs":  records,
+			"page_views":    pageViews,
+			"events":        events,
+			"live_viewing":  liveViewing,
+			"total_records": len(records),
+		},
+	})
+}
+
+// exportFailed answers a data export that could not be read completely; a
+// partial export would look like all the data of the visitor
+func exportFailed(c *gin.Context) {
+	c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Database error"})
+}
+
+// EraseVisitorData deletes all stored tracking data for a visitor_id or IP,
+// rebuilds the statistics of the affected days and forgets the cached
+// locations of its IPs
+func EraseVisitorData(c *gin.Context) {
+	var req PrivacyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request"})
+		return
+	}
+	req.VisitorId = strings.TrimSpace(req.VisitorId)
+	req.IpAddress = strings.TrimSpace(req.IpAddress)
+	if msg := validatePrivacyRequest(req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": msg})
+		return
+	}
+
+	whereClause, args := privacyWhereClause(req)
+
+	// Remember affected days so their aggregates can be rebuilt
+	affectedDates, err := visitorLogDates(whereClause, args)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Database error"})
+		return
+	}
+
+	// Their IPs are also dropped from the geolocation cache
+	ips, err := visitorLogIPs(whereClause, args)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Database error"})
+		return
+	}
+	if req.IpAddress != "" {
+		ips = append(ips, req.IpAddress)
+	}
+
+	deleted, err := eraseVisitor(req, whereClause, args, affectedDates)
+	if err != nil {
+		log.Printf("Erase visitor data: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to erase visitor data"})
+		return
+	}
+
+	// Only after the commit, so a failed erasure keeps the locations of the
+	// data still stored
+	if err := forgetGeoLocations(ips); err != nil {
+		log.Printf("Erase visitor data: geolocation cache not cleared: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to erase visitor data"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"deleted":        deleted,
+		"affected_dates": affectedDates,
+		"message":        "Visitor data erased",
+	})
+}
+
+// eraseVisitor deletes the tracking data matched by whereClause and the
+// visitor_id, and rebuilds the aggregates of the affected days, in one
+// transaction so an erasure is never left half done. It returns the number
+// of visitor_logs rows deleted.
+func eraseVisitor(req PrivacyRequest, whereClause string, args []interface{}, affectedDates []string) (int64, error) {
+	tx, err := models.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	pageWhere, pageArgs := pageViewsWhereClause(req, whereClause, args)
+	if _, err := tx.Exec("DELETE FROM page_views WHERE "+pageWhere, pageArgs...); err != nil {
+		return 0, fmt.Errorf("page_views: %v", err)
+	}
+	if req.VisitorId != "" {
+		for _, table := range []string{"visitor_events", "visitor_first_seen", "live_viewers"} {
+			if _, err := tx.Exec("DELETE FROM "+table+" WHERE visitor_id = ?", req.VisitorId); err != nil {
+				return 0, fmt.Errorf("%s: %v", table, err)
+			}
+		}
+	}
+	result, err := tx.Exec("DELETE FROM visitor_logs WHERE "+whereClause, args...)
+	if err != nil {
+		return 0, fmt.Errorf("visitor_logs: %v", err)
+	}
+	deleted, _ := result.RowsAffected()
+
+	loc := config.GetReportLocation()
+	for _, date := range affectedDates {
+		day, err := dateRange(date, loc)
+		if err != nil {
+			return 0, err
+		}
+		if err := recomputeStatsDayTx(tx, day); err != nil {
+			return 0, err
+		}
+	}
+	return deleted, tx.Commit()
+}
+
+// visitorLogIPs lists the distinct stored IPs of the visitor_logs rows
+// matching whereClause
+func visitorLogIPs(whereClause string, args []interface{}) ([]string, error) {
+	rows, err := models.DB.Query("SELECT DISTINCT ip_address FROM visitor_logs WHERE "+whereClause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ips := []string{}
+	for rows.Next() {
+		var ip string
+		if err := rows.Scan(&ip); err != nil {
+			return nil, err
+		}
+		ips = append(ips, ip)
+	}
+	return ips, rows.Err()
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+// withConfig replaces the loaded configuration for the rest of a test
+func withConfig(t *testing.T, cfg *config.Config) {
+	t.Helper()
+	saved := config.AppConfig
+	config.AppConfig = cfg
+	t.Cleanup(func() { config.AppConfig = saved })
+}
+
+func TestAnonymizeIP(t *testing.T) {
+	tests := []struct {
+		mode string
+		ip   string
+		want string
+	}{
+		{"none", "203.0.113.7", "203.0.113.7"},
+		{"none", "2001:db8:1234:5678::1", "2001:db8:1234:5678::1"},
+		{"truncate", "203.0.113.7", "203.0.113.0"},
+		{"truncate", "203.0.113.0", "203.0.113.0"},
+		{"truncate", "::ffff:203.0.113.7", "203.0.113.0"},
+		{"truncate", "2001:db8:1234:5678::1", "2001:db8:1234::"},
+		{"truncate", "unknown", "unknown"},
+	}
+	for _, tt := range tests {
+		withConfig(t, &config.Config{PrivacyIPMode: tt.mode})
+		if got := anonymizeIP(tt.ip); got != tt.want {
+			t.Errorf("%s: anonymizeIP(%q) = %q, want %q", tt.mode, tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestAnonymizeIPHash(t *testing.T) {
+	hashed := regexp.MustCompile(`^h:[0-9a-f]{32}$`)
+
+	withConfig(t, &config.Config{PrivacyIPMode: "hash", PrivacyIPSalt: "one"})
+	a := anonymizeIP("203.0.113.7")
+	if !hashed.MatchString(a) {
+		t.Fatalf("anonymizeIP = %q, want h: and 32 hex digits", a)
+	}
+	if again := anonymizeIP("203.0.113.7"); again != a {
+		t.Errorf("hash not stable: %q, then %q", a, again)
+	}
+	if other := anonymizeIP("203.0.113.8"); other == a {
+		t.Errorf("two IPs hash to %q", a)
+	}
+
+	withConfig(t, &config.Config{PrivacyIPMode: "hash", PrivacyIPSalt: "two"})
+	if salted := anonymizeIP("203.0.113.7"); salted == a {
+		t.Errorf("hash %q does not depend on the salt", a)
+	}
+}
+
+func TestPrivacyWhereClause(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		req       PrivacyRequest
+		wantWhere string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "visitor_id",
+			mode:      "none",
+			req:       PrivacyRequest{VisitorId: "v1"},
+			wantWhere: "(visitor_id = ?)",
+			wantArgs:  []interface{}{"v1"},
+		},
+		{
+			name:      "IP stored as is",
+			mode:      "none",
+			req:       PrivacyRequest{IpAddress: "203.0.113.7"},
+			wantWhere: "(ip_address IN (?, ?))",
+			wantArgs:  []interface{}{"203.0.113.7", "203.0.113.7"},
+		},
+		{
+			name:      "visitor_id or IP",
+			mode:      "none",
+			req:       PrivacyRequest{VisitorId: "v1", IpAddress: "203.0.113.7"},
+			wantWhere: "(visitor_id = ? OR ip_address IN (?, ?))",
+			wantArgs:  []interface{}{"v1", "203.0.113.7", "203.0.113.7"},
+		},
+		{
+			name:      "truncated IPs only match rows stored raw",
+			mode:      "truncate",
+			req:       PrivacyRequest{VisitorId: "v1", IpAddress: "203.0.113.7"},
+			wantWhere: "(visitor_id = ? OR ip_address = ?)",
+			wantArgs:  []interface{}{"v1", "203.0.113.7"},
+		},
+		{
+			name:      "a truncated IP matches nobody",
+			mode:      "truncate",
+			req:       PrivacyRequest{IpAddress: "203.0.113.0"},
+			wantWhere: "(1 = 0)",
+			wantArgs:  []interface{}{},
+		},
+	}
+	for _, tt := range tests {
+		withConfig(t, &config.Config{PrivacyIPMode: tt.mode})
+		where, args := privacyWhereClause(tt.req)
+		if where != tt.wantWhere || !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("%s: privacyWhereClause = %q %v, want %q %v", tt.name, where, args, tt.wantWhere, tt.wantArgs)
+		}
+	}
+}
+
+func TestPrivacyWhereClauseHash(t *testing.T) {
+	withConfig(t, &config.Config{PrivacyIPMode: "hash", PrivacyIPSalt: "salt"})
+	where, args := privacyWhereClause(PrivacyRequest{IpAddress: "203.0.113.7"})
+	want := []interface{}{"203.0.113.7", anonymizeIP("203.0.113.7")}
+	if where != "(ip_address IN (?, ?))" || !reflect.DeepEqual(args, want) {
+		t.Errorf("privacyWhereClause = %q %v, want the raw and hashed IP", where, args)
+	}
+}
+
+func TestVisitorColumn(t *testing.T) {
+	tests := []struct {
+		mode string
+		want string
+	}{
+		{"none", "ip_address"},
+		{"hash", "ip_address"},
+		{"truncate", "visitor_id"},
+	}
+	for _, tt := range tests {
+		withConfig(t, &config.Config{PrivacyIPMode: tt.mode})
+		if got := visitorColumn(); got != tt.want {
+			t.Errorf("%s: visitorColumn = %q, want %q", tt.mode, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"admin-go/models"
+	"database/sql"
 	"net/http"
 	"strconv"
 	"time"
@@ -19,7 +20,7 @@ func GetPublicCategories(c *gin.Context) {
 	rows, err := models.DB.Query(`
 		SELECT c.id, c.name, c.slug, COUNT(a.id) as article_count
 		FROM categories c
-		LEFT JOIN articles a ON a.category_id = c.id AND a.is_published = 1
+		LEFT JOIN articles a ON a.category_id = c.id AND ` + publicArticleCondition + `
 		WHERE c.is_enabled = 1
 		GROUP BY c.id
 		HAVING article_count > 0
@@ -64,6 +65,8 @@ func GetPublicArticles(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
 	categorySlug := c.Query("category")
+	tagSlug := c.Query("tag")
+	authorSlug := c.Query("author")
 
 	if page < 1 {
 		page = 1
@@ -73,7 +76,7 @@ func GetPublicArticles(c *gin.Context) {
 	}
 	offset := (page - 1) * pageSize
 
-	whereClause := "a.is_published = 1"
+	whereClause := publicArticleCondition
 	args := []interface{}{}
 
 	if categorySlug != "" {
@@ -81,6 +84,16 @@ func GetPublicArticles(c *gin.Context) {
 		args = append(args, categorySlug)
 	}
 
+	if authorSlug != "" {
+		whereClause += " AND a.author_id = (SELECT id FROM authors WHERE slug = ?)"
+		args = append(args, authorSlug)
+	}
+
+	if tagSlug != "" {
+		whereClause += " AND a.id IN (SELECT at.article_id FROM article_tags at JOIN tags t ON t.id = at.tag_id WHERE t.slug = ?)"
+		args = append(args, tagSlug)
+	}
+
 	// Get total count
 	var total int
 	countQuery := `SELECT COUNT(*) FROM articles a LEFT JOIN categories c ON a.category_id = c.id WHERE ` + whereClause
@@ -90,9 +103,10 @@ func GetPublicArticles(c *gin.Context) {
 	query := `
 		SELECT a.id, a.title, a.slug, a.summary, a.cover_image, 
 		       COALESCE(c.name, '') as category_name, COALESCE(c.slug, '') as category_slug,
-		       a.view_count, a.created_at
+		       COALESCE(au.display_name, ''), COALESCE(au.slug, ''), a.view_count, a.created_at
 		FROM articles a
 		LEFT JOIN categories c ON a.category_id = c.id
+		LEFT JOIN authors au ON a.author_id = au.id
 		WHERE ` + whereClause + `
 		ORDER BY a.created_at DESC
 		LIMIT ? OFFSET ?`
@@ -106,13 +120,15 @@ func GetPublicArticles(c *gin.Context) {
 	defer rows.Close()
 
 	articles := []map[string]interface{}{}
+	ids := []int64{}
 	for rows.Next() {
 		var id int64
-		var title, slug, summary, coverImage, categoryName, categorySlug string
+		var title, slug, summary, coverImage, categoryName, categorySlug, authorName, authorSlug string
 		var viewCount int
 		var createdAt time.Time
 
-		rows.Scan(&id, &title, &slug, &summary, &coverImage, &categoryName, &categorySlug, &viewCount, &createdAt)
+		rows.Scan(&id, &title, &slug, &summary, &coverImage, &categoryName, &categorySlug,
+			&authorName, &authorSlug, &viewCount, &createdAt)
 
 		articles = append(articles, map[string]interface{}{
 			"id":            id,
@@ -122,9 +138,17 @@ func GetPublicArticles(c *gin.Context) {
 			"cover_image":   coverImage,
 			"category_name": categoryName,
 			"category_slug": categorySlug,
+			"author_name":   authorName,
+			"author_slug":   authorSlug,
 			"view_count":    viewCount,
 			"created_at":    createdAt,
 		})
+		ids = append(ids, id)
+	}
+
+	tags := loadArticleTags(ids)
+	for i, id := range ids {
+		articles[i]["tags"] = articleTagList(tags, id)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -149,18 +173,28 @@ func GetPublicArticleBySlug(c *gin.Context) {
 	var title, articleSlug, content, summary, coverImage string
 	var categoryId *int64
 	var categoryName, categorySlug string
+	var authorName, authorSlug, authorAvatar, authorBio sql.NullString
+	var metaTitle, metaDescription, canonicalUrl, ogImage string
+	var noindex bool
 	var viewCount int
-	var createdAt, updatedAt time.Time
+	var createdAt, updatedAt, publishedAt time.Time
 
+	// An article past its publish_at is public before the scheduler sets
+	// published_at
 	err := models.DB.QueryRow(`
 		SELECT a.id, a.title, a.slug, a.content, a.summary, a.cover_image,
 		       a.category_id, COALESCE(c.name, '') as category_name, COALESCE(c.slug, '') as category_slug,
-		       a.view_count, a.created_at, a.updated_at
+		       au.display_name, au.slug, au.avatar, au.bio,
+		       COALESCE(a.meta_title, ''), COALESCE(a.meta_description, ''), COALESCE(a.canonical_url, ''),
+		       COALESCE(a.og_image, ''), a.noindex, a.view_count, a.created_at, a.updated_at,
+		       COALESCE(a.published_at, a.publish_at, a.created_at)
 		FROM articles a
 		LEFT JOIN categories c ON a.category_id = c.id
-		WHERE a.slug = ? AND a.is_published = 1`, slug).
+		LEFT JOIN authors au ON a.author_id = au.id
+		WHERE a.slug = ? AND `+publicArticleCondition, slug).
 		Scan(&id, &title, &articleSlug, &content, &summary, &coverImage,
-			&categoryId, &categoryName, &categorySlug, &viewCount, &createdAt, &updatedAt)
+			&categoryId, &categoryName, &categorySlug, &authorName, &authorSlug, &authorAvatar, &authorBio,
+			&metaTitle, &metaDescription, &canonicalUrl, &ogImage, &noindex, &viewCount, &createdAt, &updatedAt, &publishedAt)
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Article not found"})
@@ -170,32 +204,28 @@ func GetPublicArticleBySlug(c *gin.Context) {
 	// Increment view count
 	models.DB.Exec("UPDATE articles SET view_count = view_count + 1 WHERE id = ?", id)
 
-	// Get related articles (same category, exclude current)
-	related := []map[string]interface{}{}
-	if categoryId != nil {
-		relatedRows, _ := models.DB.Query(`
-			SELECT id, title, slug, summary, cover_image, created_at
-			FROM articles 
-			WHERE category_id = ? AND id != ? AND is_published = 1
-			ORDER BY created_at DESC
-			LIMIT 4`, *categoryId, id)
-		defer relatedRows.Close()
-
-		for relatedRows.Next() {
-			var rId int64
-			var rTitle, rSlug, rSummary, rCover string
-			var rCreatedAt time.Time
-			relatedRows.Scan(&rId, &rTitle, &rSlug, &rSummary, &rCover, &rCreatedAt)
-			related = append(related, map[string]interface{}{
-				"id":          rId,
-				"title":       rTitle,
-				"slug":        rSlug,
-				"summary":     rSummary,
-				"cover_image": rCover,
-				"created_at":  rCreatedAt,
-			})
-		}
+	related := relatedArticles(id, categoryId, 4)
+
+	byline := publicByline(authorName, authorSlug, authorAvatar, authorBio)
+	tags := articleTagList(loadArticleTags([]int64{id}), id)
+	source := seoArticle{
+		Title:           title,
+		Slug:            articleSlug,
+		Summary:         summary,
+		Content:         content,
+		CoverImage:      coverImage,
+		CategoryName:    categoryName,
+		MetaTitle:       metaTitle,
+		MetaDescription: metaDescription,
+		CanonicalUrl:    canonicalUrl,
+		OgImage:         ogImage,
+		Noindex:         noindex,
+		Author:          byline,
+		Tags:            tags,
+		PublishedAt:     publishedAt,
+		UpdatedAt:       updatedAt,
 	}
+	seo := buildArticleSEO(source)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -208,10 +238,67 @@ func GetPublicArticleBySlug(c *gin.Context) {
 			"cover_image":   coverImage,
 			"category_name": categoryName,
 			"category_slug": categorySlug,
+			"author":        byline,
 			"view_count":    viewCount + 1,
 			"created_at":    createdAt,
+			"published_at":  publishedAt,
 			"updated_at":    updatedAt,
+			"tags":          tags,
 			"related":       related,
+			"seo":           seo,
+			"json_ld":       articleJSONLD(source, seo),
 		},
 	})
 }
+
+// relatedArticles returns up to limit public articles related to an article:
+// those sharing the most tags first (same category breaking ties), then the
+// latest of the same category
+func relatedArticles(articleId int64, categoryId *int64, limit int) []map[string]interface{} {
+	related := []map[string]interface{}{}
+	seen := map[int64]bool{articleId: true}
+
+	scan := func(rows *sql.Rows, err error) {
+		if err != nil {
+			return
+		}
+		defer rows.Close()
+		for rows.Next() && len(related) < limit {
+			var rId int64
+			var rTitle, rSlug, rSummary, rCover string
+			var rCreatedAt time.Time
+			if rows.Scan(&rId, &rTitle, &rSlug, &rSummary, &rCover, &rCreatedAt) != nil || seen[rId] {
+				continue
+			}
+			seen[rId] = true
+			related = append(related, map[string]interface{}{
+				"id":          rId,
+				"title":       rTitle,
+				"slug":        rSlug,
+				"summary":     rSummary,
+				"cover_image": rCover,
+				"created_at":  rCreatedAt,
+			})
+		}
+	}
+
+	scan(models.DB.Query(`
+		SELECT a.id, a.title, a.slug, COALESCE(a.summary, ''), COALESCE(a.cover_image, ''), a.created_at
+		FROM article_tags at
+		JOIN article_tags shared ON shared.tag_id = at.tag_id AND shared.article_id != at.article_id
+		JOIN articles a ON a.id = shared.article_id
+		WHERE at.article_id = ? AND `+publicArticleCondition+`
+		GROUP BY a.id
+		ORDER BY COUNT(*) DESC, a.category_id <=> ? DESC, a.created_at DESC
+		LIMIT ?`, articleId, categoryId, limit))
+
+	if len(related) < limit && categoryId != nil {
+		scan(models.DB.Query(`
+			SELECT a.id, a.title, a.slug, COALESCE(a.summary, ''), COALESCE(a.cover_image, ''), a.created_at
+			FROM articles a
+			WHERE a.category_id = ? AND a.id != ? AND `+publicArticleCondition+`
+			ORDER BY a.created_at DESC
+			LIMIT ?`, *categoryId, articleId, limit+len(related)))
+	}
+	return related
+}
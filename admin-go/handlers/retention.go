@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"admin-go/models"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// retentionTables lists the raw tracking tables the retention policy purges,
+// with the column rows expire by and the columns identifying a row. Rollups,
+// daily_stats, live_samples, visitor_first_seen and alerts are kept forever.
+var retentionTables = []struct {
+	name       string
+	timeColumn string
+	keyColumns []string
+}{
+	{"page_views", "viewed_at", []string{"id"}},
+	{"visitor_events", "created_at", []string{"id"}},
+	{"live_viewers", "last_seen_at", []string{"match_id", "visitor_id"}},
+	{"visitor_logs", "visit_time", []string{"id"}},
+}
+
+// retentionBatchPause lets other queries through between two delete batches
+const retentionBatchPause = 50 * time.Millisecond
+
+// retentionMu keeps scheduled and manual runs from overlapping
+var retentionMu sync.Mutex
+
+type RetentionTableResult struct {
+	Table       string `json:"table"`
+	Archived    int64  `json:"archived"`
+	Deleted     int64  `json:"deleted"`
+	ArchiveFile string `json:"archive_file,omitempty"`
+}
+
+type RetentionResult struct {
+	RetentionDays int                    `json:"retention_days"`
+	Cutoff        time.Time              `json:"cutoff"`
+	Tables        []RetentionTableResult `json:"tables"`
+	StartedAt     time.Time              `json:"started_at"`
+	FinishedAt    time.Time              `json:"finished_at"`
+}
+
+// StartRetentionJob enforces the retention policy every
+// RETENTION_INTERVAL_HOURS when RETENTION_DAYS is set
+func StartRetentionJob() {
+	cfg := config.GetRetentionConfig()
+	if cfg.RetentionDays <= 0 || cfg.RetentionIntervalHours <= 0 {
+		log.Println("Data retention job disabled (raw visitor data is kept forever)")
+		return
+	}
+
+	go func() {
+		run := func() {
+			if models.DB == nil {
+				return
+			}
+			result, err := RunRetention()
+			if err != nil {
+				log.Printf("Data retention error: %v", err)
+			}
+			if result != nil {
+				for _, t := range result.Tables {
+					if t.Deleted > 0 {
+						log.Printf("Data retention: purged %d rows from %s (archive: %s)", t.Deleted, t.Table, t.ArchiveFile)
+					}
+				}
+			}
+		}
+
+		run()
+		ticker := time.NewTicker(time.Duration(cfg.RetentionIntervalHours) * time.Hour)
+		defer ticker.Stop()
+		for range ticker.C {
+			run()
+		}
+	}()
+}
+
+// errRetentionRunning is returned when a retention run is started while
+// another one is still going
+var errRetentionRunning = errors.New("a retention run is already in progress")
+
+// retentionCutoff is the start of the oldest reporting day whose raw data is
+// kept; everything before it is purged
+func retentionCutoff(retentionDays int) time.Time {
+	return dayRange(reportNow().AddDate(0, 0, -retentionDays), 1).From
+}
+
+// RunRetention archives and deletes raw visitor data older than
+// RETENTION_DAYS (whole reporting days), in batches of RETENTION_BATCH_SIZE.
+// Each batch is written to the archive before it is deleted. The run is
+// recorded in retention_runs; on error the partial result is returned.
+func RunRetention() (*RetentionResult, error) {
+	cfg := config.GetRetentionConfig()
+	if cfg.RetentionDays <= 0 {
+		return nil, errors.New("RETENTION_DAYS is not set")
+	}
+	if cfg.RetentionArchive && cfg.RetentionArchiveFormat != "jsonl" && cfg.RetentionArchiveFormat != "csv" {
+		return nil, errors.New("RETENTION_ARCHIVE_FORMAT must be jsonl or csv")
+	}
+	if !retentionMu.TryLock() {
+		return nil, errRetentionRunning
+	}
+	defer retentionMu.Unlock()
+
+	batchSize := cfg.RetentionBatchSize
+	if batchSize <= 0 {
+		batchSize = 1000
+	}
+
+	result := &RetentionResult{
+		RetentionDays: cfg.RetentionDays,
+		Cutoff:        retentionCutoff(cfg.RetentionDays),
+		Tables:        []RetentionTableResult{},
+		StartedAt:     time.Now(),
+	}
+
+	var runErr error
+	for _, table := range retentionTables {
+		tableResult, err := purgeTable(table.name, table.timeColumn, table.keyColumns, result.Cutoff, batchSize, result.StartedAt)
+		result.Tables = append(result.Tables, tableResult)
+		if err != nil {
+			runErr = fmt.Errorf("%s: %v", table.name, err)
+			break
+		}
+	}
+	result.FinishedAt = time.Now()
+
+	summary, _ := json.Marshal(result)
+	errText := ""
+	if runErr != nil {
+		errText = runErr.Error()
+	}
+	models.DB.Exec(`
+		INSERT INTO retention_runs (cutoff, result, error, started_at, finished_at)
+		VALUES (?, ?, ?, ?, ?)`, result.Cutoff, string(summary), errText, result.StartedAt, result.FinishedAt)
+
+	return result, runErr
+}
+
+// purgeTable moves the rows of table older than cutoff to the archive and
+// deletes them, one batch at a time
+func purgeTable(table, timeColumn string, keyColumns []string, cutoff time.Time, batchSize int, runTime time.Time) (RetentionTableResult, error) {
+	result := RetentionTableResult{Table: table}
+	cfg := config.GetRetentionConfig()
+
+	var archive *retentionArchive
+	defer func() {
+		if archive != nil {
+			archive.Close()
+		}
+	}()
+
+	for {
+		rows, err := models.DB.Query(`
+			SELECT * FROM `+table+`
+			WHERE `+timeColumn+` < ?
+			ORDER BY `+timeColumn+`
+			LIMIT ?`, cutoff, batchSize)
+		if err != nil {
+			return result, err
+		}
+		columns, batch, err := scanGenericRows(rows)
+		if err != nil {
+			return result, err
+		}
+		if len(batch) == 0 {
+			return result, nil
+		}
+
+		if cfg.RetentionArchive {
+			if archive == nil {
+				archive, err = newRetentionArchive(cfg.RetentionArchiveDir, table, cfg.RetentionArchiveFormat, runTime)
+				if err != nil {
+					return result, err
+				}
+				result.ArchiveFile = archive.path
+			}
+			if err := archive.Write(columns, batch); err != nil {
+				return result, err
+			}
+			result.Archived += int64(len(batch))
+		}
+
+		// Delete exactly the archived rows
+		keyIndex := []int{}
+		for _, key := range keyColumns {
+			for i, column := range columns {
+				if column == key {
+					keyIndex = append(keyIndex, i)
+				}
+			}
+		}
+		tuple := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(keyColumns)), ", ") + ")"
+		placeholders := make([]string, len(batch))
+		args := []interface{}{}
+		for i, row := range batch {
+			placeholders[i] = tuple
+			for _, k := range keyIndex {
+				args = append(args, row[k])
+			}
+		}
+		deleted, err := models.DB.Exec(`
+			DELETE FROM `+table+`
+			WHERE (`+strings.Join(keyColumns, ", ")+`) IN (`+strings.Join(placeholders, ", ")+`)`, args...)
+		if err != nil {
+			return result, err
+		}
+		n, _ := deleted.RowsAffected()
+		result.Deleted += n
+
+		if len(batch) < batchSize {
+			return result, nil
+		}
+		time.Sleep(retentionBatchPause)
+	}
+}
+
+// scanGenericRows reads every row of a SELECT * with its column names;
+// text columns become strings
+func scanGenericRows(rows interface {
+	Columns() ([]string, error)
+	Next() bool
+	Scan(dest ...interface{}) error
+	Err() error
+	Close() error
+}) ([]string, [][]interface{}, error) {
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	batch := [][]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, nil, err
+		}
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		batch = append(batch, values)
+	}
+	return columns, batch, rows.Err()
+}
+
+// retentionArchive is a gzip-compressed JSONL or CSV file of purged rows
+type retentionArchive struct {
+	path   string
+	format string
+	file   *os.File
+	gz     *gzip.Writer
+	csv    *csv.Writer
+}
+
+func newRetentionArchive(dir, table, format string, runTime time.Time) (*retentionArchive, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.%s.gz", table, runTime.Format("20060102-150405"), format))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, err
+	}
+	a := &retentionArchive{path: path, format: format, file: file, gz: gzip.NewWriter(file)}
+	if format == "csv" {
+		a.csv = csv.NewWriter(a.gz)
+	}
+	return a, nil
+}
+
+// Write appends a batch and syncs it to disk, so rows are only deleted once
+// they are safely archived
+func (a *retentionArchive) Write(columns []string, batch [][]interface{}) error {
+	if a.csv != nil {
+		if stat, _ := a.file.Stat(); stat != nil && stat.Size() == 0 {
+			if err := a.csv.Write(columns); err != nil {
+				return err
+			}
+		}
+		for _, row := range batch {
+			record := make([]string, len(row))
+			for i, v := range row {
+				switch value := v.(type) {
+				case nil:
+				case time.Time:
+					record[i] = value.UTC().Format(time.RFC3339)
+				default:
+					record[i] = fmt.Sprint(value)
+				}
+			}
+			if err := a.csv.Write(record); err != nil {
+				return err
+			}
+		}
+		a.csv.Flush()
+		if err := a.csv.Error(); err != nil {
+			return err
+		}
+	} else {
+		enc := json.NewEncoder(a.gz)
+		for _, row := range batch {
+			record := make(map[string]interface{}, len(columns))
+			for i, column := range columns {
+				record[column] = row[i]
+			}
+			if err := enc.Encode(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := a.gz.Flush(); err != nil {
+		return err
+	}
+	return a.file.Sync()
+}
+
+func (a *retentionArchive) Close() error {
+	if err := a.gz.Close(); err != nil {
+		a.file.Close()
+		return err
+	}
+	return a.file.Close()
+}
+
+// GetRetentionStatus returns the retention policy and the latest runs
+func GetRetentionStatus(c *gin.Context) {
+	cfg := config.GetRetentionConfig()
+
+	runs := []gin.H{}
+	rows, err := models.DB.Query(`
+		SELECT id, cutoff, COALESCE(result, ''), COALESCE(error, ''), started_at, finished_at
+		FROM retention_runs
+		ORDER BY id DESC
+		LIMIT 20`)
+	if err == nil {
+		defer rows.Close()
+		for rows.Next() {
+			var id int64
+			var cutoff, startedAt, finishedAt *time.Time
+			var resultJson, runErr string
+			if rows.Scan(&id, &cutoff, &resultJson, &runErr, &startedAt, &finishedAt) != nil {
+				continue
+			}
+			var result RetentionResult
+			json.Unmarshal([]byte(resultJson), &result)
+			runs = append(runs, gin.H{
+				"id":          id,
+				"cutoff":      cutoff,
+				"tables":      result.Tables,
+				"error":       runErr,
+				"started_at":  startedAt,
+				"finished_at": finishedAt,
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"policy": gin.H{
+				"retention_days": cfg.RetentionDays,
+				"interval_hours": cfg.RetentionIntervalHours,
+				"batch_size":     cfg.RetentionBatchSize,
+				"archive":        cfg.RetentionArchive,
+				"archive_dir":    cfg.RetentionArchiveDir,
+				"archive_format": cfg.RetentionArchiveFormat,
+			},
+			"runs": runs,
+		},
+	})
+}
+
+// RunRetentionNow enforces the retention policy immediately
+func RunRetentionNow(c *gin.Context) {
+	result, err := RunRetention()
+	if errors.Is(err, errRetentionRunning) {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": err.Error(), "data": result})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": result})
+}
@@ -0,0 +1,485 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"admin-go/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// referrerSourceExpr classifies visitor_logs.referrer into a traffic source
+const referrerSourceExpr = `CASE
+		         WHEN referrer IS NULL OR referrer = '' THEN 'Direct'
+		         WHEN referrer LIKE '%google%' THEN 'Google'
+		         WHEN referrer LIKE '%facebook%' THEN 'Facebook'
+		         WHEN referrer LIKE '%twitter%' OR referrer LIKE '%x.com%' THEN 'Twitter/X'
+		         WHEN referrer LIKE '%bing%' THEN 'Bing'
+		         ELSE 'Other'
+		       END`
+
+// rollupDimensions maps each rollup dimension to the visitor_logs expression it groups by
+var rollupDimensions = []struct {
+	name string
+	expr string
+}{
+	{"page", "LEFT(COALESCE(NULLIF(page_path, ''), '/'), 255)"},
+	{"device", "COALESCE(NULLIF(device_type, ''), 'Unknown')"},
+	{"browser", "COALESCE(NULLIF(browser, ''), 'Unknown')"},
+	{"country", "COALESCE(NULLIF(country_name, ''), 'Unknown')"},
+	{"referrer", referrerSourceExpr},
+}
+
+// useRollups reports whether a range can be served from the rollup tables,
+// which are bucketed in the configured reporting timezone and only written
+// while the stats aggregator runs. Unique visitors only add up within a
+// day, so multi-day ranges still count them from visitor_logs.
+func useRollups(r statsRange) bool {
+	return config.GetStatsConfig().StatsRollupInterval > 0 &&
+		r.From.Location().String() == config.GetReportLocation().String()
+}
+
+// StartStatsAggregator periodically rebuilds today's rollups and daily_stats
+// from visitor_logs. The previous day is finalized once after midnight.
+func StartStatsAggregator() {
+	interval := time.Duration(config.GetStatsConfig().StatsRollupInterval) * time.Second
+	if interval <= 0 {
+		log.Println("Stats aggregator disabled")
+		return
+	}
+
+	go func() {
+		lastDate := ""
+		aggregate := func() {
+			if models.DB == nil {
+				return
+			}
+			now := reportNow()
+			today := dayRange(now, 1)
+			if lastDate != today.FromDate() {
+				if err := recomputeStatsDay(dayRange(now.AddDate(0, 0, -1), 1)); err != nil {
+					log.Printf("Stats aggregator error: %v", err)
+				}
+			}
+			if err := recomputeStatsDay(today); err != nil {
+				log.Printf("Stats aggregator error: %v", err)
+				return
+			}
+			lastDate = today.FromDate()
+		}
+
+		aggregate()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			aggregate()
+		}
+	}()
+}
+
+// statsExecer is a *sql.DB or a *sql.Tx the aggregates are rebuilt through
+type statsExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// recomputeStatsDay rebuilds the hourly and daily rollups and the daily_stats
+// row of one reporting day from visitor_logs
+func recomputeStatsDay(day statsRange) error {
+	tx, err := models.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := recomputeStatsDayTx(tx, day); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %v", day.FromDate(), err)
+	}
+	return nil
+}
+
+// recomputeStatsDayTx is recomputeStatsDay within the caller's transaction
+func recomputeStatsDayTx(tx statsExecer, day statsRange) error {
+	date := day.FromDate()
+
+	if _, err := tx.Exec("DELETE FROM stats_daily WHERE stats_date = ?", date); err != nil {
+		return fmt.Errorf("%s: %v", date, err)
+	}
+	if _, err := tx.Exec("DELETE FROM stats_hourly WHERE bucket_hour >= ? AND bucket_hour < ?", day.From, day.To); err != nil {
+		return fmt.Errorf("%s: %v", date, err)
+	}
+
+	// Hourly buckets are UTC hours, so any whole-hour timezone can read them
+	_, err := tx.Exec(`
+		INSERT INTO stats_hourly (bucket_hour, dimension, dim_value, page_views, unique_visitors)
+		SELECT DATE_FORMAT(visit_time, '%Y-%m-%d %H:00:00'), 'total', '',
+		       SUM(page_view_count), COUNT(DISTINCT `+visitorColumn()+`)
+		FROM visitor_logs
+		WHERE visit_time >= ? AND visit_time < ?
+		GROUP BY 1
+		ON DUPLICATE KEY UPDATE page_views = VALUES(page_views), unique_visitors = VALUES(unique_visitors)`,
+		day.From, day.To)
+	if err != nil {
+		return fmt.Errorf("%s: %v", date, err)
+	}
+
+	for _, dim := range rollupDimensions {
+		_, err = tx.Exec(`
+			INSERT INTO stats_hourly (bucket_hour, dimension, dim_value, page_views, unique_visitors)
+			SELECT DATE_FORMAT(visit_time, '%Y-%m-%d %H:00:00'), ?, `+dim.expr+`,
+			       SUM(page_view_count), COUNT(DISTINCT `+visitorColumn()+`)
+			FROM visitor_logs
+			WHERE visit_time >= ? AND visit_time < ?
+			GROUP BY 1, 3
+			ON DUPLICATE KEY UPDATE page_views = VALUES(page_views), unique_visitors = VALUES(unique_visitors)`,
+			dim.name, day.From, day.To)
+		if err != nil {
+			return fmt.Errorf("%s %s hourly: %v", date, dim.name, err)
+		}
+
+		_, err = tx.Exec(`
+			INSERT INTO stats_daily (stats_date, dimension, dim_value, page_views, unique_visitors)
+			SELECT ?, ?, `+dim.expr+`,
+			       SUM(page_view_count), COUNT(DISTINCT `+visitorColumn()+`)
+			FROM visitor_logs
+			WHERE visit_time >= ? AND visit_time < ?
+			GROUP BY 3
+			ON DUPLICATE KEY UPDATE page_views = VALUES(page_views), unique_visitors = VALUES(unique_visitors)`,
+			date, dim.name, day.From, day.To)
+		if err != nil {
+			return fmt.Errorf("%s %s daily: %v", date, dim.name, err)
+		}
+	}
+
+	if err := recomputeDailyStats(tx, day); err != nil {
+		return fmt.Errorf("%s daily_stats: %v", date, err)
+	}
+	return nil
+}
+
+// recomputeStatsDate rebuilds every aggregate of a YYYY-MM-DD reporting day
+func recomputeStatsDate(date string) error {
+	day, err := dateRange(date, config.GetReportLocation())
+	if err != nil {
+		return err
+	}
+	return recomputeStatsDay(day)
+}
+
+// recomputeDailyStats rebuilds the daily_stats row of a reporting day from
+// visitor_logs
+func recomputeDailyStats(db statsExecer, day statsRange) error {
+	date := day.FromDate()
+
+	var pv, uv, newVisitors, returningVisitors, sessions, avgDuration int
+	err := db.QueryRow(`
+		SELECT COALESCE(SUM(page_view_count), 0),
+		       COUNT(DISTINCT `+visitorColumn()+`),
+		       COALESCE(SUM(CASE WHEN is_new_visitor = 1 THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN is_new_visitor = 0 THEN 1 ELSE 0 END), 0),
+		       COUNT(DISTINCT visitor_id),
+		       COALESCE(ROUND(AVG(COALESCE(duration, 0))), 0)
+		FROM visitor_logs
+		WHERE visit_time >= ? AND visit_time < ?`, day.From, day.To).Scan(&pv, &uv, &newVisitors, &returningVisitors, &sessions, &avgDuration)
+	if err != nil {
+		return err
+	}
+
+	if pv == 0 && uv == 0 {
+		_, err = db.Exec("DELETE FROM daily_stats WHERE stats_date = ?", date)
+		return err
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO daily_stats (stats_date, page_views, unique_visitors, unique_ips, new_visitors, returning_visitors, sessions, avg_duration)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+		    page_views = VALUES(page_views),
+		    unique_visitors = VALUES(unique_visitors),
+		    unique_ips = VALUES(unique_ips),
+		    new_visitors = VALUES(new_visitors),
+		    returning_visitors = VALUES(returning_visitors),
+		    sessions = VALUES(sessions),
+		    avg_duration = VALUES(avg_duration),
+		    updated_at = CURRENT_TIMESTAMP`,
+		date, pv, uv, uv, newVisitors, returningVisitors, sessions, avgDuration)
+	return err
+}
+
+// visitorLogDates lists the reporting days (YYYY-MM-DD) of the visitor_logs
+// rows matching whereClause
+func visitorLogDates(whereClause string, args []interface{}) ([]string, error) {
+	dates := []string{}
+	var first, last sql.NullTime
+	err := models.DB.QueryRow("SELECT MIN(visit_time), MAX(visit_time) FROM visitor_logs WHERE "+whereClause, args...).
+		Scan(&first, &last)
+	if err != nil || !first.Valid {
+		return dates, err
+	}
+
+	loc := config.GetReportLocation()
+	local, localArgs := localTimeExpr("visit_time", first.Time.In(loc), last.Time.Add(time.Second).In(loc))
+	rows, err := models.DB.Query("SELECT DISTINCT DATE_FORMAT("+local+", '%Y-%m-%d') FROM visitor_logs WHERE "+whereClause+" ORDER BY 1",
+		append(localArgs, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var date string
+		if err := rows.Scan(&date); err != nil {
+			return nil, err
+		}
+		dates = append(dates, date)
+	}
+	return dates, rows.Err()
+}
+
+// StatsRecomputeResult reports the outcome of a recomputation run
+type StatsRecomputeResult struct {
+	From     string   `json:"from"`
+	To       string   `json:"to"`
+	Days     int      `json:"days"`
+	Skipped  []string `json:"skipped"`
+	Failed   []string `json:"failed"`
+	Duration string   `json:"duration"`
+}
+
+// RecomputeStats rebuilds rollups and daily_stats for every day between the
+// inclusive YYYY-MM-DD bounds (default: today) from the raw visitor logs.
+// Rollups are the only record of days purged by the retention policy, so
+// ranges before the retention cutoff are refused and days without raw rows
+// are skipped rather than emptied.
+func RecomputeStats(from, to string) (StatsRecomputeResult, error) {
+	startedAt := time.Now()
+	r, err := parseDateRange(from, to, reportNow())
+	if err != nil {
+		return StatsRecomputeResult{}, err
+	}
+	if days := config.GetRetentionConfig().RetentionDays; days > 0 {
+		if cutoff := retentionCutoff(days); r.From.Before(cutoff) {
+			return StatsRecomputeResult{}, fmt.Errorf("raw data before %s has been purged (RETENTION_DAYS=%d), so its rollups cannot be rebuilt",
+				cutoff.Format("2006-01-02"), days)
+		}
+	}
+
+	result := StatsRecomputeResult{From: r.FromDate(), To: r.ToDate(), Skipped: []string{}, Failed: []string{}}
+	for d := r.From; d.Before(r.To); d = d.AddDate(0, 0, 1) {
+		day := dayRange(d, 1)
+		var hasLogs int
+		err := models.DB.QueryRow("SELECT 1 FROM visitor_logs WHERE visit_time >= ? AND visit_time < ? LIMIT 1",
+			day.From, day.To).Scan(&hasLogs)
+		if err == sql.ErrNoRows {
+			result.Skipped = append(result.Skipped, day.FromDate())
+			continue
+		}
+		if err == nil {
+			err = recomputeStatsDay(day)
+		}
+		if err != nil {
+			log.Printf("Stats recompute error: %v", err)
+			result.Failed = append(result.Failed, d.Format("2006-01-02"))
+			continue
+		}
+		result.Days++
+	}
+	result.Duration = time.Since(startedAt).Round(time.Millisecond).String()
+	return result, nil
+}
+
+// RecomputeStatsHandler rebuilds the aggregates of a date range
+func RecomputeStatsHandler(c *gin.Context) {
+	var req struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	c.ShouldBindJSON(&req)
+
+	result, err := RecomputeStats(req.From, req.To)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": result})
+}
+
+// rollupBreakdown sums a stats_daily dimension over a range; each row holds
+// the dimension value under key plus pv and uv. uv is the sum of daily
+// uniques, so it is only exact for a single day.
+func rollupBreakdown(r statsRange, dimension, key, orderBy string, limit int) []map[string]interface{} {
+	query := `
+		SELECT dim_value, SUM(page_views) as pv, SUM(unique_visitors) as uv
+		FROM stats_daily
+		WHERE dimension = ? AND stats_date >= ? AND stats_date <= ?
+		GROUP BY dim_value
+		ORDER BY ` + orderBy + ` DESC`
+	args := []interface{}{dimension, r.FromDate(), r.ToDate()}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	result := []map[string]interface{}{}
+	rows, err := models.DB.Query(query, args...)
+	if err != nil {
+		return result
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var value string
+		var pv, uv int
+		rows.Scan(&value, &pv, &uv)
+		result = append(result, map[string]interface{}{
+			key:  value,
+			"pv": pv,
+			"uv": uv,
+		})
+	}
+	return result
+}
+
+// dimensionBreakdown is rollupBreakdown for any range: multi-day ranges and
+// ranges outside the reporting timezone are grouped from visitor_logs with
+// the same expressions, so their uv counts each visitor once
+func dimensionBreakdown(r statsRange, dimension, key, orderBy string, limit int) []map[string]interface{} {
+	if useRollups(r) && r.Days() == 1 {
+		return rollupBreakdown(r, dimension, key, orderBy, limit)
+	}
+
+	expr := ""
+	for _, dim := range rollupDimensions {
+		if dim.name == dimension {
+			expr = dim.expr
+		}
+	}
+
+	result := []map[string]interface{}{}
+	if expr == "" {
+		return result
+	}
+
+	query := `
+		SELECT ` + expr + ` as dim_value, SUM(page_view_count) as pv, COUNT(DISTINCT ` + visitorColumn() + `) as uv
+		FROM visitor_logs
+		WHERE visit_time >= ? AND visit_time < ?
+		GROUP BY dim_value
+		ORDER BY ` + orderBy + ` DESC`
+	args := []interface{}{r.From, r.To}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := models.DB.Query(query, args...)
+	if err != nil {
+		return result
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var value string
+		var pv, uv int
+		rows.Scan(&value, &pv, &uv)
+		result = append(result, map[string]interface{}{
+			key:  value,
+			"pv": pv,
+			"uv": uv,
+		})
+	}
+	return result
+}
+
+// rollupHourly sums the hourly rollups of a range per hour of day in the
+// range's timezone
+func rollupHourly(r statsRange) []map[string]interface{} {
+	result := []map[string]interface{}{}
+	rows, err := models.DB.Query(`
+		SELECT bucket_hour, page_views, unique_visitors
+		FROM stats_hourly
+		WHERE dimension = 'total' AND bucket_hour >= ? AND bucket_hour < ?`, r.From, r.To)
+	if err != nil {
+		return result
+	}
+	defer rows.Close()
+
+	var pv, uv [24]int
+	var seen [24]bool
+	for rows.Next() {
+		var bucket time.Time
+		var bucketPV, bucketUV int
+		if rows.Scan(&bucket, &bucketPV, &bucketUV) != nil {
+			continue
+		}
+		hour := bucket.In(r.From.Location()).Hour()
+		pv[hour] += bucketPV
+		uv[hour] += bucketUV
+		seen[hour] = true
+	}
+
+	for hour := 0; hour < 24; hour++ {
+		if !seen[hour] {
+			continue
+		}
+		result = append(result, map[string]interface{}{
+			"hour": fmt.Sprintf("%02d", hour),
+			"pv":   pv[hour],
+			"uv":   uv[hour],
+		})
+	}
+	return result
+}
+
+// getTrend returns daily PV/UV over a range, from daily_stats when the range
+// is in the reporting timezone and from visitor_logs otherwise
+func getTrend(r statsRange) []map[string]interface{} {
+	trend := []map[string]interface{}{}
+
+	var query string
+	var args []interface{}
+	if useRollups(r) {
+		query = `
+			SELECT DATE_FORMAT(stats_date, '%Y-%m-%d'), page_views, unique_visitors
+			FROM daily_stats
+			WHERE stats_date >= ? AND stats_date <= ?
+			ORDER BY stats_date`
+		args = []interface{}{r.FromDate(), r.ToDate()}
+	} else {
+		local, localArgs := localTimeExpr("visit_time", r.From, r.To)
+		query = `
+			SELECT DATE_FORMAT(` + local + `, '%Y-%m-%d') as date,
+			       SUM(page_view_count), COUNT(DISTINCT ` + visitorColumn() + `)
+			FROM visitor_logs
+			WHERE visit_time >= ? AND visit_time < ?
+			GROUP BY date
+			ORDER BY date`
+		args = append(localArgs, r.From, r.To)
+	}
+
+	rows, err := models.DB.Query(query, args...)
+	if err != nil {
+		return trend
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var date string
+		var pv, uv int
+		rows.Scan(&date, &pv, &uv)
+		trend = append(trend, map[string]interface{}{
+			"date": date,
+			"pv":   pv,
+			"uv":   uv,
+		})
+	}
+	return trend
+}
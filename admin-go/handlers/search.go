@@ -0,0 +1,619 @@
+package handlers
+
+import (
+	"admin-go/models"
+	"html"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/unicode/norm"
+)
+
+// searchRebuildInterval is how often the article search index is rebuilt
+// from scratch; saves update it immediately in between
+const searchRebuildInterval = 10 * time.Minute
+
+// maxSearchResults caps the ranked matches of one public query
+const maxSearchResults = 1000
+
+// BM25 parameters and the weight of each indexed field
+const (
+	searchK1 = 1.2
+	searchB  = 0.75
+)
+
+const (
+	fieldTitle = iota
+	fieldSummary
+	fieldContent
+	searchFieldCount
+)
+
+var searchFieldWeights = [searchFieldCount]float64{3, 2, 1}
+
+// htmlStripPattern matches script/style blocks and tags
+var htmlStripPattern = regexp.MustCompile(`(?is)<script[^>]*>.*?</script>|<style[^>]*>.*?</style>|<[^>]+>`)
+
+// searchDoc is an indexed article
+type searchDoc struct {
+	ID           int64
+	Title        string
+	Slug         string
+	Summary      string
+	Text         string // content without markup
+	CoverImage   string
+	CategoryName string
+	CategorySlug string
+	IsPublished  bool
+	PublishAt    *time.Time
+	UnpublishAt  *time.Time
+	CreatedAt    time.Time
+
+	lengths [searchFieldCount]int
+	terms   map[string][searchFieldCount]int
+	// Folded tokens of the title and of the summary plus text, joined by
+	// spaces, for phrase matching
+	titleTokens string
+	bodyTokens  string
+}
+
+// visible reports whether the public site shows the article at now, like
+// publicArticleCondition
+func (d *searchDoc) visible(now time.Time) bool {
+	published := d.IsPublished || (d.PublishAt != nil && !d.PublishAt.After(now))
+	return published && (d.UnpublishAt == nil || d.UnpublishAt.After(now))
+}
+
+// articleIndex is an in-memory inverted index of articles over folded
+// (lowercase, diacritic-free) terms of the title, summary and content.
+// vocabulary holds the terms of postings in order, for prefix lookups; it is
+// refreshed after a batch of changes.
+type articleIndex struct {
+	sync.RWMutex
+	built      bool
+	docs       map[int64]*searchDoc
+	postings   map[string]map[int64]struct{}
+	totals     [searchFieldCount]int
+	vocabulary []string
+	vocabDirty bool
+}
+
+var articleSearch = newArticleIndex()
+
+func newArticleIndex() *articleIndex {
+	return &articleIndex{docs: map[int64]*searchDoc{}, postings: map[string]map[int64]struct{}{}}
+}
+
+// foldRune maps a rune to its lowercase base letter (ế -> e, Đ -> d);
+// combining marks fold to nothing
+func foldRune(r rune) (rune, bool) {
+	if r < utf8.RuneSelf {
+		return unicode.ToLower(r), true
+	}
+	if r == 'đ' || r == 'Đ' {
+		return 'd', true
+	}
+	if unicode.Is(unicode.Mn, r) {
+		return 0, false
+	}
+	for _, base := range norm.NFD.String(string(r)) {
+		return unicode.ToLower(base), true
+	}
+	return r, true
+}
+
+// foldText folds a text for matching; positions[i] is the index in the
+// original runes of folded rune i
+func foldText(s string) (folded []rune, positions []int) {
+	for i, r := range []rune(s) {
+		if f, ok := foldRune(r); ok {
+			folded = append(folded, f)
+			positions = append(positions, i)
+		}
+	}
+	return folded, positions
+}
+
+// searchTokens splits a text into folded terms
+func searchTokens(s string) []string {
+	folded, _ := foldText(s)
+	return strings.FieldsFunc(string(folded), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// stripHTML returns the readable text of an HTML fragment
+func stripHTML(s string) string {
+	s = htmlStripPattern.ReplaceAllString(s, " ")
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
+
+// add indexes a document, replacing an earlier version of it
+func (idx *articleIndex) add(doc *searchDoc) {
+	idx.remove(doc.ID)
+
+	doc.terms = map[string][searchFieldCount]int{}
+	var fieldTokens [searchFieldCount][]string
+	for field, text := range [searchFieldCount]string{doc.Title, doc.Summary, doc.Text} {
+		tokens := searchTokens(text)
+		fieldTokens[field] = tokens
+		doc.lengths[field] = len(tokens)
+		idx.totals[field] += len(tokens)
+		for _, t := range tokens {
+			tf := doc.terms[t]
+			tf[field]++
+			doc.terms[t] = tf
+		}
+	}
+	doc.titleTokens = strings.Join(fieldTokens[fieldTitle], " ")
+	doc.bodyTokens = strings.Join(append(fieldTokens[fieldSummary], fieldTokens[fieldContent]...), " ")
+
+	for t := range doc.terms {
+		if idx.postings[t] == nil {
+			idx.postings[t] = map[int64]struct{}{}
+			idx.vocabDirty = true
+		}
+		idx.postings[t][doc.ID] = struct{}{}
+	}
+	idx.docs[doc.ID] = doc
+}
+
+func (idx *articleIndex) remove(id int64) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+	for t := range doc.terms {
+		delete(idx.postings[t], id)
+		if len(idx.postings[t]) == 0 {
+			delete(idx.postings, t)
+			idx.vocabDirty = true
+		}
+	}
+	for field := range idx.totals {
+		idx.totals[field] -= doc.lengths[field]
+	}
+	delete(idx.docs, id)
+}
+
+// refreshVocabulary re-sorts the vocabulary after terms were added or
+// removed; the caller holds the write lock
+func (idx *articleIndex) refreshVocabulary() {
+	if !idx.vocabDirty {
+		return
+	}
+	idx.vocabulary = make([]string, 0, len(idx.postings))
+	for t := range idx.postings {
+		idx.vocabulary = append(idx.vocabulary, t)
+	}
+	sort.Strings(idx.vocabulary)
+	idx.vocabDirty = false
+}
+
+// minPrefixLength is the shortest last term also matched as a prefix
+// (search-as-you-type); shorter Vietnamese syllables would match too much
+const minPrefixLength = 3
+
+// prefixMatchWeight scales the score of words matched only by prefix
+const prefixMatchWeight = 0.5
+
+// searchQuery is a parsed search: its folded terms, the last one also
+// matching as a prefix
+type searchQuery struct {
+	raw   string
+	terms []string
+}
+
+func parseSearchQuery(q string) searchQuery {
+	seen := map[string]bool{}
+	query := searchQuery{raw: strings.ToLower(strings.TrimSpace(q))}
+	for _, t := range searchTokens(q) {
+		if !seen[t] {
+			seen[t] = true
+			query.terms = append(query.terms, t)
+		}
+	}
+	return query
+}
+
+// matches reports whether a folded token matches term i of the query
+func (q searchQuery) matches(token string, i int) bool {
+	if q.prefixes(i) {
+		return strings.HasPrefix(token, q.terms[i])
+	}
+	return token == q.terms[i]
+}
+
+// prefixes reports whether term i also matches longer words
+func (q searchQuery) prefixes(i int) bool {
+	return i == len(q.terms)-1 && len([]rune(q.terms[i])) >= minPrefixLength
+}
+
+type searchHit struct {
+	doc   *searchDoc
+	score float64
+}
+
+// search returns the documents containing every query term that pass
+// filter, best first, at most limit of them (0 for all). Scores are BM25
+// over the weighted fields, boosted when the whole query appears as a
+// phrase, more so with the same diacritics.
+func (idx *articleIndex) search(q searchQuery, filter func(*searchDoc) bool, limit int) []searchHit {
+	if len(q.terms) == 0 {
+		return nil
+	}
+
+	idx.RLock()
+	defer idx.RUnlock()
+
+	n := float64(len(idx.docs))
+	if n == 0 {
+		return nil
+	}
+	var avgLength [searchFieldCount]float64
+	for field := range avgLength {
+		avgLength[field] = math.Max(float64(idx.totals[field])/n, 1)
+	}
+
+	// Terms each query term stands for (prefix expansion for the last one)
+	expansions := make([][]string, len(q.terms))
+	for i, term := range q.terms {
+		if _, ok := idx.postings[term]; ok {
+			expansions[i] = append(expansions[i], term)
+		}
+		if q.prefixes(i) {
+			for j := sort.SearchStrings(idx.vocabulary, term); j < len(idx.vocabulary) && strings.HasPrefix(idx.vocabulary[j], term); j++ {
+				if idx.vocabulary[j] != term {
+					expansions[i] = append(expansions[i], idx.vocabulary[j])
+				}
+			}
+		}
+		if len(expansions[i]) == 0 {
+			return nil
+		}
+	}
+
+	// Candidates: documents with the rarest term
+	rarest := 0
+	rarestCount := math.MaxInt
+	for i, terms := range expansions {
+		count := 0
+		for _, t := range terms {
+			count += len(idx.postings[t])
+		}
+		if count < rarestCount {
+			rarest, rarestCount = i, count
+		}
+	}
+	candidates := map[int64]struct{}{}
+	for _, t := range expansions[rarest] {
+		for id := range idx.postings[t] {
+			candidates[id] = struct{}{}
+		}
+	}
+
+	phrase := strings.Join(q.terms, " ")
+	hits := []searchHit{}
+	for id := range candidates {
+		doc := idx.docs[id]
+		if filter != nil && !filter(doc) {
+			continue
+		}
+
+		score := 0.0
+		matchedAll := true
+		for i, terms := range expansions {
+			best := 0.0
+			for _, t := range terms {
+				tf, ok := doc.terms[t]
+				if !ok {
+					continue
+				}
+				df := float64(len(idx.postings[t]))
+				idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+				termScore := 0.0
+				for field := 0; field < searchFieldCount; field++ {
+					if tf[field] == 0 {
+						continue
+					}
+					f := float64(tf[field])
+					lengthNorm := 1 - searchB + searchB*float64(doc.lengths[field])/avgLength[field]
+					termScore += searchFieldWeights[field] * f * (searchK1 + 1) / (f + searchK1*lengthNorm)
+				}
+				if t != q.terms[i] {
+					termScore *= prefixMatchWeight
+				}
+				best = math.Max(best, idf*termScore)
+			}
+			if best == 0 {
+				matchedAll = false
+				break
+			}
+			score += best
+		}
+		if !matchedAll {
+			continue
+		}
+
+		if len(q.terms) > 1 {
+			if strings.Contains(doc.titleTokens, phrase) {
+				score *= 1.5
+			} else if strings.Contains(doc.bodyTokens, phrase) {
+				score *= 1.2
+			}
+		}
+		if q.raw != "" && strings.Contains(strings.ToLower(doc.Title), q.raw) {
+			score *= 1.2
+		}
+		hits = append(hits, searchHit{doc: doc, score: score})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].doc.CreatedAt.After(hits[j].doc.CreatedAt)
+	})
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// highlight escapes text for HTML and wraps the words matching the query in
+// <mark>. With maxLength > 0 only a window of about that many characters
+// around the first match is returned, with an ellipsis where it was cut.
+func highlight(text string, q searchQuery, maxLength int) string {
+	runes := []rune(text)
+	folded, positions := foldText(text)
+
+	// Matching words as [start, end) ranges of the original runes
+	type span struct{ start, end int }
+	spans := []span{}
+	for i := 0; i < len(folded); {
+		if !unicode.IsLetter(folded[i]) && !unicode.IsDigit(folded[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(folded) && (unicode.IsLetter(folded[j]) || unicode.IsDigit(folded[j])) {
+			j++
+		}
+		token := string(folded[i:j])
+		for t := range q.terms {
+			if q.matches(token, t) {
+				end := positions[j-1] + 1
+				for end < len(runes) && unicode.Is(unicode.Mn, runes[end]) {
+					end++
+				}
+				spans = append(spans, span{positions[i], end})
+				break
+			}
+		}
+		i = j
+	}
+
+	from, to := 0, len(runes)
+	if maxLength > 0 && len(runes) > maxLength {
+		if len(spans) > 0 {
+			from = spans[0].start - maxLength/4
+		}
+		if from < 0 {
+			from = 0
+		}
+		// Start on a word boundary
+		for from > 0 && !unicode.IsSpace(runes[from-1]) && spans[0].start-from < maxLength/2 {
+			from--
+		}
+		to = from + maxLength
+		if to > len(runes) {
+			to = len(runes)
+			from = int(math.Max(0, float64(to-maxLength)))
+		}
+		for to < len(runes) && !unicode.IsSpace(runes[to]) && to-from < maxLength+20 {
+			to++
+		}
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, s := range spans {
+		if s.end <= from || s.start >= to {
+			continue
+		}
+		start := int(math.Max(float64(s.start), float64(from)))
+		end := int(math.Min(float64(s.end), float64(to)))
+		b.WriteString(html.EscapeString(string(runes[pos:start])))
+		b.WriteString("<mark>" + html.EscapeString(string(runes[start:end])) + "</mark>")
+		pos = end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:to])))
+	if to < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// loadSearchDocs reads articles (all, or the given IDs) for indexing
+func loadSearchDocs(ids ...int64) ([]*searchDoc, error) {
+	query := `
+		SELECT a.id, a.title, a.slug, COALESCE(a.summary, ''), COALESCE(a.content, ''), COALESCE(a.cover_image, ''),
+		       COALESCE(c.name, ''), COALESCE(c.slug, ''), a.is_published, a.publish_at, a.unpublish_at, a.created_at
+		FROM articles a
+		LEFT JOIN categories c ON a.category_id = c.id`
+	args := []interface{}{}
+	if len(ids) > 0 {
+		query += " WHERE a.id IN (" + strings.TrimSuffix(strings.Repeat("?, ", len(ids)), ", ") + ")"
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+
+	rows, err := models.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []*searchDoc{}
+	for rows.Next() {
+		var d searchDoc
+		var content string
+		if err := rows.Scan(&d.ID, &d.Title, &d.Slug, &d.Summary, &content, &d.CoverImage,
+			&d.CategoryName, &d.CategorySlug, &d.IsPublished, &d.PublishAt, &d.UnpublishAt, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		d.Text = stripHTML(content)
+		docs = append(docs, &d)
+	}
+	return docs, rows.Err()
+}
+
+// rebuildSearchIndex indexes every article into a new index and swaps it in
+func rebuildSearchIndex() error {
+	docs, err := loadSearchDocs()
+	if err != nil {
+		return err
+	}
+
+	fresh := newArticleIndex()
+	for _, doc := range docs {
+		fresh.add(doc)
+	}
+	fresh.refreshVocabulary()
+
+	articleSearch.Lock()
+	articleSearch.docs, articleSearch.postings, articleSearch.totals = fresh.docs, fresh.postings, fresh.totals
+	articleSearch.vocabulary, articleSearch.vocabDirty = fresh.vocabulary, false
+	articleSearch.built = true
+	articleSearch.Unlock()
+	return nil
+}
+
+// reindexArticles refreshes articles in the search index after they were
+// saved or deleted
+func reindexArticles(ids ...int64) {
+	if len(ids) == 0 || models.DB == nil {
+		return
+	}
+	docs, err := loadSearchDocs(ids...)
+	if err != nil {
+		log.Printf("Search index error: %v", err)
+		return
+	}
+
+	articleSearch.Lock()
+	defer articleSearch.Unlock()
+	for _, id := range ids {
+		articleSearch.remove(id)
+	}
+	for _, doc := range docs {
+		articleSearch.add(doc)
+	}
+	articleSearch.refreshVocabulary()
+}
+
+// StartSearchIndexer builds the article search index and rebuilds it every
+// searchRebuildInterval
+func StartSearchIndexer() {
+	go func() {
+		ticker := time.NewTicker(searchRebuildInterval)
+		defer ticker.Stop()
+		for {
+			if models.DB != nil {
+				if err := rebuildSearchIndex(); err != nil {
+					log.Printf("Search index error: %v", err)
+				}
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// searchArticleIDs returns the IDs of the best maxSearchResults articles
+// matching keyword, best first; ok is false while the index is not built yet
+func searchArticleIDs(keyword string) (ids []int64, ok bool) {
+	articleSearch.RLock()
+	built := articleSearch.built
+	articleSearch.RUnlock()
+	if !built {
+		return nil, false
+	}
+
+	for _, hit := range articleSearch.search(parseSearchQuery(keyword), nil, maxSearchResults) {
+		ids = append(ids, hit.doc.ID)
+	}
+	return ids, true
+}
+
+// SearchPublicArticles searches the articles visible on the public site by
+// title, summary and content, ignoring Vietnamese diacritics, ranked by
+// relevance with highlighted titles and snippets
+func SearchPublicArticles(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	categorySlug := c.Query("category")
+
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 50 {
+		pageSize = 10
+	}
+
+	query := parseSearchQuery(q)
+	if len([]rune(q)) > 200 || len(query.terms) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "q must contain a word to search for"})
+		return
+	}
+
+	now := time.Now()
+	hits := articleSearch.search(query, func(d *searchDoc) bool {
+		return d.visible(now) && (categorySlug == "" || d.CategorySlug == categorySlug)
+	}, maxSearchResults)
+
+	results := []map[string]interface{}{}
+	for i := (page - 1) * pageSize; i < len(hits) && i < page*pageSize; i++ {
+		d := hits[i].doc
+		snippetSource := d.Text
+		if snippetSource == "" {
+			snippetSource = d.Summary
+		}
+		results = append(results, map[string]interface{}{
+			"id":              d.ID,
+			"title":           d.Title,
+			"title_highlight": highlight(d.Title, query, 0),
+			"slug":            d.Slug,
+			"summary":         d.Summary,
+			"snippet":         highlight(snippetSource, query, 200),
+			"cover_image":     d.CoverImage,
+			"category_name":   d.CategoryName,
+			"category_slug":   d.CategorySlug,
+			"created_at":      d.CreatedAt,
+			"score":           math.Round(hits[i].score*1000) / 1000,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"query":     q,
+		"data":      results,
+		"total":     len(hits),
+		"page":      page,
+		"page_size": pageSize,
+	})
+}
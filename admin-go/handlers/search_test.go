@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFoldText(t *testing.T) {
+	tests := []struct {
+		in            string
+		wantFolded    string
+		wantPositions []int
+	}{
+		{"", "", nil},
+		{"ABC", "abc", []int{0, 1, 2}},
+		{"Tiếng Việt", "tieng viet", []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{"Đà Nẵng", "da nang", []int{0, 1, 2, 3, 4, 5, 6}},
+		// Decomposed text: the combining marks are dropped
+		{"ết", "et", []int{0, 3}},
+	}
+	for _, tt := range tests {
+		folded, positions := foldText(tt.in)
+		if string(folded) != tt.wantFolded || !reflect.DeepEqual(positions, tt.wantPositions) {
+			t.Errorf("foldText(%q) = %q, %v; want %q, %v", tt.in, string(folded), positions, tt.wantFolded, tt.wantPositions)
+		}
+	}
+}
+
+func TestSearchTokens(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"  --  ", nil},
+		{"Bóng đá: U23 Việt Nam!", []string{"bong", "da", "u23", "viet", "nam"}},
+		{"covid-19", []string{"covid", "19"}},
+	}
+	for _, tt := range tests {
+		if got := searchTokens(tt.in); len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("searchTokens(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		in        string
+		wantRaw   string
+		wantTerms []string
+	}{
+		{"", "", nil},
+		{"  Việt Nam  ", "việt nam", []string{"viet", "nam"}},
+		{"Nam nam NAM", "nam nam nam", []string{"nam"}},
+		{"Đà đa", "đà đa", []string{"da"}},
+	}
+	for _, tt := range tests {
+		q := parseSearchQuery(tt.in)
+		if q.raw != tt.wantRaw || !reflect.DeepEqual(q.terms, tt.wantTerms) {
+			t.Errorf("parseSearchQuery(%q) = %q, %q; want %q, %q", tt.in, q.raw, q.terms, tt.wantRaw, tt.wantTerms)
+		}
+	}
+}
+
+func TestSearchQueryMatches(t *testing.T) {
+	tests := []struct {
+		query string
+		term  int
+		token string
+		want  bool
+	}{
+		{"viet nam", 0, "viet", true},
+		{"viet nam", 0, "vietnam", false}, // only the last term is a prefix
+		{"viet nam", 1, "nam", true},
+		{"viet nam", 1, "namdinh", true},
+		{"viet na", 1, "nam", false}, // too short for a prefix
+		{"bong", 0, "bong", true},
+		{"bong", 0, "bongda", true},
+		{"bong", 0, "bon", false},
+	}
+	for _, tt := range tests {
+		if got := parseSearchQuery(tt.query).matches(tt.token, tt.term); got != tt.want {
+			t.Errorf("%q term %d matches %q = %v, want %v", tt.query, tt.term, tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		text      string
+		query     string
+		maxLength int
+		want      string
+	}{
+		{"Tin Việt Nam", "viet", 0, "Tin <mark>Việt</mark> Nam"},
+		{"Tin Việt Nam", "nam", 0, "Tin Việt <mark>Nam</mark>"},
+		{"Việt Nam, việt nam", "viet nam", 0, "<mark>Việt</mark> <mark>Nam</mark>, <mark>việt</mark> <mark>nam</mark>"},
+		{"Bóng đá hôm nay", "bong", 0, "<mark>Bóng</mark> đá hôm nay"},
+		{"Bóng đá hôm nay", "bo", 0, "Bóng đá hôm nay"},
+		{"Decomposed Việt", "viet", 0, "Decomposed <mark>Việt</mark>"},
+		{"<b>Việt</b> & Nam", "viet", 0, "&lt;b&gt;<mark>Việt</mark>&lt;/b&gt; &amp; Nam"},
+		{"one two three four five six seven eight", "five", 16, "…four <mark>five</mark> six seven…"},
+		{"one two three four five six seven eight", "one", 10, "<mark>one</mark> two three…"},
+		{"one two three four five six seven eight", "xyz", 10, "one two three…"},
+	}
+	for _, tt := range tests {
+		if got := highlight(tt.text, parseSearchQuery(tt.query), tt.maxLength); got != tt.want {
+			t.Errorf("highlight(%q, %q, %d) = %q, want %q", tt.text, tt.query, tt.maxLength, got, tt.want)
+		}
+	}
+}
+
+func TestArticleIndexSearch(t *testing.T) {
+	idx := newArticleIndex()
+	idx.add(&searchDoc{ID: 1, Title: "Bóng đá Việt Nam", Text: "Đội tuyển thắng trận"})
+	idx.add(&searchDoc{ID: 2, Title: "Thời tiết", Text: "Nắng nóng ở Việt Nam và bóng râm"})
+	idx.add(&searchDoc{ID: 3, Title: "Kinh tế", Text: "Giá vàng tăng"})
+	idx.add(&searchDoc{ID: 4, Title: "Bóng chuyền", Text: "Giải bóng chuyền nữ"})
+	idx.remove(4)
+	idx.refreshVocabulary()
+
+	tests := []struct {
+		query string
+		limit int
+		want  []int64
+	}{
+		{"viet nam", 0, []int64{1, 2}},
+		{"bong da", 0, []int64{1}},
+		{"vàng", 0, []int64{3}},
+		{"chuyen", 0, nil},      // removed
+		{"thắn", 0, []int64{1}}, // prefix of thang
+		{"tha", 0, []int64{1}},  // prefix of thang, not of thoi
+		{"th", 0, nil},          // too short for a prefix
+		{"viet nam", 1, []int64{1}},
+		{"", 0, nil},
+	}
+	for _, tt := range tests {
+		hits := idx.search(parseSearchQuery(tt.query), nil, tt.limit)
+		var got []int64
+		for _, h := range hits {
+			got = append(got, h.doc.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("search(%q, %d) = %v, want %v", tt.query, tt.limit, got, tt.want)
+		}
+	}
+
+	visible := func(d *searchDoc) bool { return d.ID != 1 }
+	if hits := idx.search(parseSearchQuery("viet nam"), visible, 0); len(hits) != 1 || hits[0].doc.ID != 2 {
+		t.Errorf("filtered search returned %d hits", len(hits))
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"admin-go/config"
 	"admin-go/models"
 	"fmt"
 	"net/http"
@@ -11,7 +12,7 @@ import (
 
 // GetSitemap generates sitemap.xml with all public pages
 func GetSitemap(c *gin.Context) {
-	baseURL := "http://localhost:3000" // Can be configured
+	baseURL := config.GetSiteConfig().SiteURL
 
 	var urls []string
 
@@ -23,8 +24,7 @@ func GetSitemap(c *gin.Context) {
 		SELECT DISTINCT c.slug 
 		FROM categories c 
 		INNER JOIN articles a ON a.category_id = c.id 
-		WHERE a.is_published = 1
-	`)
+		WHERE ` + publicArticleCondition)
 	if err == nil {
 		defer categoryRows.Close()
 		for categoryRows.Next() {
@@ -35,10 +35,29 @@ func GetSitemap(c *gin.Context) {
 		}
 	}
 
+	// Tag pages with published articles
+	tagRows, err := models.DB.Query(`
+		SELECT DISTINCT t.slug
+		FROM tags t
+		INNER JOIN article_tags at ON at.tag_id = t.id
+		INNER JOIN articles a ON a.id = at.article_id
+		WHERE ` + publicArticleCondition)
+	if err == nil {
+		defer tagRows.Close()
+		for tagRows.Next() {
+			var slug string
+			if tagRows.Scan(&slug) == nil {
+				urls = append(urls, fmt.Sprintf(`<url><loc>%s/tag/%s</loc><changefreq>daily</changefreq><priority>0.5</priority></url>`, baseURL, slug))
+			}
+		}
+	}
+
 	// Get all published articles
 	articleRows, err := models.DB.Query(`
-		SELECT slug, updated_at FROM articles WHERE is_published = 1 ORDER BY created_at DESC
-	`)
+		SELECT a.slug, a.updated_at FROM articles a
+		WHERE `+publicArticleCondition+` AND a.noindex = 0
+		  AND (a.canonical_url IS NULL OR a.canonical_url = '' OR a.canonical_url = CONCAT(?, '/article/', a.slug))
+		ORDER BY a.created_at DESC`, baseURL)
 	if err == nil {
 		defer articleRows.Close()
 		for articleRows.Next() {
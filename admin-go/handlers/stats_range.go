@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// statsRange is a half-open [From, To) interval of whole days
+type statsRange struct {
+	From time.Time
+	To   time.Time
+}
+
+const maxStatsRangeDays = 400
+
+// reportLocation returns the timezone analytics are bucketed in,
+// honoring a per-request ?tz= override (e.g. tz=UTC)
+func reportLocation(c *gin.Context) (*time.Location, error) {
+	if tz := c.Query("tz"); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q", tz)
+		}
+		return loc, nil
+	}
+	return config.GetReportLocation(), nil
+}
+
+// reportNow returns the current time in the configured reporting timezone
+func reportNow() time.Time {
+	return time.Now().In(config.GetReportLocation())
+}
+
+// tzOffset returns the UTC offset of t's location at t (e.g. "+07:00")
+func tzOffset(t time.Time) string {
+	return t.Format("-07:00")
+}
+
+// localTimeExpr converts a UTC column to local time in from's timezone for
+// rows between from and to. Every UTC offset in effect during the range
+// (DST changes) gets its own CASE branch, so MySQL needs no timezone tables.
+func localTimeExpr(column string, from, to time.Time) (string, []interface{}) {
+	offset := "?"
+	args := []interface{}{}
+	t := from
+	for {
+		_, end := t.ZoneBounds()
+		if end.IsZero() || !end.Before(to) {
+			break
+		}
+		if offset == "?" {
+			offset = "CASE"
+		}
+		offset += " WHEN " + column + " < ? THEN ?"
+		args = append(args, end, tzOffset(t))
+		t = end
+	}
+	if offset != "?" {
+		offset += " ELSE ? END"
+	}
+	args = append(args, tzOffset(t))
+	return "CONVERT_TZ(" + column + ", '+00:00', " + offset + ")", args
+}
+
+// dateRange returns the range of a single YYYY-MM-DD day in loc
+func dateRange(date string, loc *time.Location) (statsRange, error) {
+	day, err := time.ParseInLocation("2006-01-02", date, loc)
+	if err != nil {
+		return statsRange{}, err
+	}
+	return statsRange{From: day, To: day.AddDate(0, 0, 1)}, nil
+}
+
+// dayRange returns the range covering the given number of days ending with day
+func dayRange(day time.Time, days int) statsRange {
+	end := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location()).AddDate(0, 0, 1)
+	return statsRange{From: end.AddDate(0, 0, -days), To: end}
+}
+
+// Days returns the number of days covered by the range
+func (r statsRange) Days() int {
+	return int(r.To.Sub(r.From).Hours()/24 + 0.5)
+}
+
+// FromDate returns the first day of the range as YYYY-MM-DD
+func (r statsRange) FromDate() string {
+	return r.From.Format("2006-01-02")
+}
+
+// ToDate returns the last day of the range (inclusive) as YYYY-MM-DD
+func (r statsRange) ToDate() string {
+	return r.To.AddDate(0, 0, -1).Format("2006-01-02")
+}
+
+func (r statsRange) JSON() gin.H {
+	return gin.H{"from": r.FromDate(), "to": r.ToDate(), "days": r.Days(), "timezone": r.From.Location().String()}
+}
+
+// parseDateRange reads inclusive YYYY-MM-DD bounds; missing bounds default to today
+func parseDateRange(fromStr, toStr string, now time.Time) (statsRange, error) {
+	today := now.Format("2006-01-02")
+	if fromStr == "" {
+		fromStr = today
+	}
+	if toStr == "" {
+		toStr = today
+	}
+
+	from, err := time.ParseInLocation("2006-01-02", fromStr, now.Location())
+	if err != nil {
+		return statsRange{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", fromStr)
+	}
+	to, err := time.ParseInLocation("2006-01-02", toStr, now.Location())
+	if err != nil {
+		return statsRange{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", toStr)
+	}
+	if to.Before(from) {
+		return statsRange{}, errors.New("'to' must not be before 'from'")
+	}
+
+	r := statsRange{From: from, To: to.AddDate(0, 0, 1)}
+	if r.Days() > maxStatsRangeDays {
+		return statsRange{}, fmt.Errorf("date range cannot exceed %d days", maxStatsRangeDays)
+	}
+	return r, nil
+}
+
+// queryRange reads the from/to query parameters, defaulting to the last days
+// days up to today in the request's reporting timezone
+func queryRange(c *gin.Context, days int) (statsRange, error) {
+	loc, err := reportLocation(c)
+	if err != nil {
+		return statsRange{}, err
+	}
+	now := time.Now().In(loc)
+	if c.Query("from") == "" && c.Query("to") == "" {
+		return dayRange(now, days), nil
+	}
+	return parseDateRange(c.Query("from"), c.Query("to"), now)
+}
+
+// parseComparisonRange resolves the compare parameter:
+// previous (same length right before), year (same dates one year earlier)
+// or custom (compare_from / compare_to). Returns nil when no comparison is requested.
+func parseComparisonRange(c *gin.Context, current statsRange, now time.Time) (*statsRange, error) {
+	switch c.Query("compare") {
+	case "":
+		return nil, nil
+	case "previous":
+		days := current.Days()
+		return &statsRange{From: current.From.AddDate(0, 0, -days), To: current.From}, nil
+	case "year":
+		return &statsRange{From: current.From.AddDate(-1, 0, 0), To: current.To.AddDate(-1, 0, 0)}, nil
+	case "custom":
+		if c.Query("compare_from") == "" || c.Query("compare_to") == "" {
+			return nil, errors.New("compare_from and compare_to are required for custom comparison")
+		}
+		r, err := parseDateRange(c.Query("compare_from"), c.Query("compare_to"), now)
+		if err != nil {
+			return nil, err
+		}
+		return &r, nil
+	}
+	return nil, errors.New("compare must be one of: previous, year, custom")
+}
+
+// metricDelta describes how a metric changed between two periods.
+// ChangePct is nil when the previous value is zero.
+type metricDelta struct {
+	Current   int64    `json:"current"`
+	Previous  int64    `json:"previous"`
+	Delta     int64    `json:"delta"`
+	ChangePct *float64 `json:"change_pct"`
+}
+
+func newMetricDelta(current, previous int64) metricDelta {
+	d := metricDelta{Current: current, Previous: previous, Delta: current - previous}
+	if previous != 0 {
+		pct := math.Round(float64(d.Delta)/float64(previous)*10000) / 100
+		d.ChangePct = &pct
+	}
+	return d
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case uint32:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+// compareBreakdown annotates each current row with a "comparison" map holding
+// the delta of every numeric metric against the row with the same key in the
+// previous period
+func compareBreakdown(current, previous []map[string]interface{}, keys []string, metrics []string) {
+	keyOf := func(row map[string]interface{}) string {
+		k := ""
+		for _, key := range keys {
+			k += fmt.Sprint(row[key]) + "\x00"
+		}
+		return k
+	}
+
+	prevByKey := make(map[string]map[string]interface{}, len(previous))
+	for _, row := range previous {
+		prevByKey[keyOf(row)] = row
+	}
+
+	for _, row := range current {
+		prev := prevByKey[keyOf(row)]
+		comparison := map[string]metricDelta{}
+		for _, metric := range metrics {
+			cur, ok := toInt64(row[metric])
+			if !ok {
+				continue
+			}
+			var prevValue int64
+			if prev != nil {
+				prevValue, _ = toInt64(prev[metric])
+			}
+			comparison[metric] = newMetricDelta(cur, prevValue)
+		}
+		row["comparison"] = comparison
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLocalTimeExpr(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saigon, err := time.LoadLocation("Asia/Ho_Chi_Minh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		wantExpr string
+		wantArgs []interface{}
+	}{
+		{
+			name:     "no DST",
+			from:     time.Date(2026, 3, 1, 0, 0, 0, 0, saigon),
+			to:       time.Date(2026, 4, 1, 0, 0, 0, 0, saigon),
+			wantExpr: "CONVERT_TZ(visit_time, '+00:00', ?)",
+			wantArgs: []interface{}{"+07:00"},
+		},
+		{
+			name:     "within one offset",
+			from:     time.Date(2026, 6, 1, 0, 0, 0, 0, newYork),
+			to:       time.Date(2026, 7, 1, 0, 0, 0, 0, newYork),
+			wantExpr: "CONVERT_TZ(visit_time, '+00:00', ?)",
+			wantArgs: []interface{}{"-04:00"},
+		},
+		{
+			name:     "spring forward",
+			from:     time.Date(2026, 3, 1, 0, 0, 0, 0, newYork),
+			to:       time.Date(2026, 4, 1, 0, 0, 0, 0, newYork),
+			wantExpr: "CONVERT_TZ(visit_time, '+00:00', CASE WHEN visit_time < ? THEN ? ELSE ? END)",
+			wantArgs: []interface{}{time.Date(2026, 3, 8, 7, 0, 0, 0, time.UTC), "-05:00", "-04:00"},
+		},
+		{
+			name: "spring forward and fall back",
+			from: time.Date(2026, 3, 1, 0, 0, 0, 0, newYork),
+			to:   time.Date(2026, 12, 1, 0, 0, 0, 0, newYork),
+			wantExpr: "CONVERT_TZ(visit_time, '+00:00', " +
+				"CASE WHEN visit_time < ? THEN ? WHEN visit_time < ? THEN ? ELSE ? END)",
+			wantArgs: []interface{}{
+				time.Date(2026, 3, 8, 7, 0, 0, 0, time.UTC), "-05:00",
+				time.Date(2026, 11, 1, 6, 0, 0, 0, time.UTC), "-04:00",
+				"-05:00",
+			},
+		},
+		{
+			name:     "UTC",
+			from:     time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+			to:       time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC),
+			wantExpr: "CONVERT_TZ(visit_time, '+00:00', ?)",
+			wantArgs: []interface{}{"+00:00"},
+		},
+	}
+	for _, tt := range tests {
+		expr, args := localTimeExpr("visit_time", tt.from, tt.to)
+		if expr != tt.wantExpr {
+			t.Errorf("%s: expr = %q, want %q", tt.name, expr, tt.wantExpr)
+		}
+		if len(args) != len(tt.wantArgs) {
+			t.Errorf("%s: args = %v, want %v", tt.name, args, tt.wantArgs)
+			continue
+		}
+		for i := range args {
+			got, want := args[i], tt.wantArgs[i]
+			if gotTime, ok := got.(time.Time); ok {
+				if wantTime, ok := want.(time.Time); !ok || !gotTime.Equal(wantTime) {
+					t.Errorf("%s: arg %d = %v, want %v", tt.name, i, got, want)
+				}
+				continue
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: arg %d = %v, want %v", tt.name, i, got, want)
+			}
+		}
+	}
+}
+
+func TestParseDateRange(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, newYork)
+
+	tests := []struct {
+		from, to string
+		wantFrom string
+		wantTo   string
+		wantDays int
+		wantErr  bool
+	}{
+		{"", "", "2026-03-10", "2026-03-10", 1, false},
+		{"2026-03-07", "2026-03-09", "2026-03-07", "2026-03-09", 3, false}, // spans the DST change
+		{"2026-03-09", "2026-03-07", "", "", 0, true},
+		{"2026-3-7", "", "", "", 0, true},
+		{"2025-01-01", "2026-03-01", "", "", 0, true},
+	}
+	for _, tt := range tests {
+		r, err := parseDateRange(tt.from, tt.to, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseDateRange(%q, %q) error = %v, want error %v", tt.from, tt.to, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if r.FromDate() != tt.wantFrom || r.ToDate() != tt.wantTo || r.Days() != tt.wantDays {
+			t.Errorf("parseDateRange(%q, %q) = %s..%s (%d days), want %s..%s (%d days)",
+				tt.from, tt.to, r.FromDate(), r.ToDate(), r.Days(), tt.wantFrom, tt.wantTo, tt.wantDays)
+		}
+	}
+}
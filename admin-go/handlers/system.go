@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"admin-go/config"
 	"admin-go/models"
 	"net"
 	"net/http"
@@ -52,7 +53,7 @@ func AddToBlacklist(c *gin.Context) {
 
 	var expiresAt *time.Time
 	if req.ExpiresAt != "" {
-		t, err := time.Parse("2006-01-02 15:04:05", req.ExpiresAt)
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", req.ExpiresAt, config.GetReportLocation())
 		if err == nil {
 			expiresAt = &t
 		}
@@ -158,38 +159,15 @@ func GetSystemInfo(c *gin.Context) {
 	})
 }
 
-// ClearAllVisitors deletes all visitor logs and daily stats
-func ClearAllVisitors(c *gin.Context) {
-	// Delete all visitor logs
-	_, err := models.DB.Exec("DELETE FROM visitor_logs")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to clear visitor logs"})
-		return
-	}
-
-	// Delete all daily stats
-	_, err = models.DB.Exec("DELETE FROM daily_stats")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to clear daily stats"})
-		return
-	}
-
-	// Delete realtime stats
-	_, err = models.DB.Exec("DELETE FROM realtime_stats")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to clear realtime stats"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"success": true, "message": "All visitor records cleared"})
-}
-
-// ClearDailyStats deletes only daily statistics
+// ClearDailyStats deletes only daily statistics and rollups. Days whose raw
+// logs are still kept can be rebuilt with /api/stats/recompute; the rollups
+// of days purged by the retention policy are lost.
 func ClearDailyStats(c *gin.Context) {
-	_, err := models.DB.Exec("DELETE FROM daily_stats")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to clear daily stats"})
-		return
+	for _, table := range []string{"daily_stats", "stats_daily", "stats_hourly"} {
+		if _, err := models.DB.Exec("DELETE FROM " + table); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to clear daily stats"})
+			return
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Daily statistics cleared"})
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"admin-go/models"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tagTypes are the kinds of tag; topic is the default
+var tagTypes = map[string]bool{"topic": true, "team": true, "league": true, "player": true}
+
+// maxArticleTags caps the tags of one article
+const maxArticleTags = 30
+
+var tagSlugPattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// slugSeparatorPattern matches the runs of characters a slug replaces by "-"
+var slugSeparatorPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+type TagRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Slug        string `json:"slug"`
+	Type        string `json:"type"`
+	Description string `json:"description"`
+}
+
+// generateTagSlug makes a slug from a tag name, keeping Vietnamese letters
+// as their base letter (Hà Nội FC -> ha-noi-fc). Names without any such
+// letter (⚽, 東京) get a hash of the name, so the same name always maps to
+// the same tag.
+func generateTagSlug(name string) string {
+	folded, _ := foldText(name)
+	slug := strings.Trim(slugSeparatorPattern.ReplaceAllString(string(folded), "-"), "-")
+	if slug == "" {
+		sum := sha1.Sum([]byte(strings.ToLower(strings.TrimSpace(name))))
+		slug = hex.EncodeToString(sum[:6])
+	}
+	return slug
+}
+
+// validateTagRequest normalizes a tag request, returning an error message
+func validateTagRequest(req *TagRequest) string {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len([]rune(req.Name)) > 100 {
+		return "Name must be 1-100 characters"
+	}
+	if req.Type == "" {
+		req.Type = "topic"
+	}
+	if !tagTypes[req.Type] {
+		return "type must be topic, team, league or player"
+	}
+	if req.Slug == "" {
+		req.Slug = generateTagSlug(req.Name)
+	}
+	if !tagSlugPattern.MatchString(req.Slug) {
+		return "Slug can only contain lowercase letters, numbers, and hyphens"
+	}
+	return ""
+}
+
+func GetTags(c *gin.Context) {
+	tagType := c.Query("type")
+	keyword := c.Query("keyword")
+
+	whereClause := "1=1"
+	args := []interface{}{}
+	if tagType != "" {
+		whereClause += " AND t.tag_type = ?"
+		args = append(args, tagType)
+	}
+	if keyword != "" {
+		whereClause += " AND (t.name LIKE ? OR t.slug LIKE ?)"
+		args = append(args, "%"+keyword+"%", "%"+generateTagSlug(keyword)+"%")
+	}
+
+	rows, err := models.DB.Query(`
+		SELECT t.id, t.name, t.slug, t.tag_type, COALESCE(t.description, ''), t.created_at,
+		       (SELECT COUNT(*) FROM article_tags WHERE tag_id = t.id) as article_count
+		FROM tags t
+		WHERE `+whereClause+`
+		ORDER BY t.tag_type, t.name`, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var t models.Tag
+		var createdAt time.Time
+		rows.Scan(&t.ID, &t.Name, &t.Slug, &t.Type, &t.Description, &createdAt, &t.ArticleCount)
+		t.CreatedAt = &createdAt
+		tags = append(tags, t)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": tags})
+}
+
+func CreateTag(c *gin.Context) {
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if msg := validateTagRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var existingId int64
+	if models.DB.QueryRow("SELECT id FROM tags WHERE slug = ?", req.Slug).Scan(&existingId) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Slug already exists"})
+		return
+	}
+
+	result, err := models.DB.Exec(`
+		INSERT INTO tags (name, slug, tag_type, description, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		req.Name, req.Slug, req.Type, req.Description, time.Now(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create tag"})
+		return
+	}
+
+	id, _ := result.LastInsertId()
+	c.JSON(http.StatusOK, gin.H{"success": true, "id": id, "slug": req.Slug, "message": "Tag created successfully"})
+}
+
+func UpdateTag(c *gin.Context) {
+	id := c.Param("id")
+
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if msg := validateTagRequest(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var existingId int64
+	if models.DB.QueryRow("SELECT id FROM tags WHERE slug = ? AND id != ?", req.Slug, id).Scan(&existingId) == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Slug already exists"})
+		return
+	}
+
+	result, err := models.DB.Exec(`
+		UPDATE tags SET name = ?, slug = ?, tag_type = ?, description = ?, updated_at = ?
+		WHERE id = ?`,
+		req.Name, req.Slug, req.Type, req.Description, time.Now(), id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tag"})
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var exists int
+		if models.DB.QueryRow("SELECT 1 FROM tags WHERE id = ?", id).Scan(&exists) == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Tag not found"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Tag updated successfully"})
+}
+
+// DeleteTag deletes a tag and removes it from its articles
+func DeleteTag(c *gin.Context) {
+	id := c.Param("id")
+
+	_, err := models.DB.Exec("DELETE FROM tags WHERE id = ?", id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tag"})
+		return
+	}
+	models.DB.Exec("DELETE FROM article_tags WHERE tag_id = ?", id)
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Tag deleted successfully"})
+}
+
+// resolveTags returns the IDs of the named tags, creating the missing ones
+// as topics. Names are matched by slug, so "Hà Nội" and "ha noi" are the
+// same tag.
+func resolveTags(names []string) ([]int64, error) {
+	ids := []int64{}
+	seen := map[string]bool{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		slug := generateTagSlug(name)
+		if name == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+
+		var id int64
+		err := models.DB.QueryRow("SELECT id FROM tags WHERE slug = ?", slug).Scan(&id)
+		if err == sql.ErrNoRows {
+			// INSERT IGNORE leaves a tag created meanwhile alone
+			if _, err = models.DB.Exec(`
+				INSERT IGNORE INTO tags (name, slug, tag_type, created_at, updated_at)
+				VALUES (?, ?, 'topic', ?, ?)`, name, slug, time.Now(), time.Now()); err != nil {
+				return nil, err
+			}
+			err = models.DB.QueryRow("SELECT id FROM tags WHERE slug = ?", slug).Scan(&id)
+		}
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// setArticleTags replaces the tags of an article with tagIds (from
+// resolveTags), within the transaction that saves the article
+func setArticleTags(tx *sql.Tx, articleId int64, tagIds []int64) error {
+	if _, err := tx.Exec("DELETE FROM article_tags WHERE article_id = ?", articleId); err != nil {
+		return err
+	}
+	for _, tagId := range tagIds {
+		if _, err := tx.Exec("INSERT INTO article_tags (article_id, tag_id) VALUES (?, ?)", articleId, tagId); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadArticleTags returns the tags of the given articles by article ID
+func loadArticleTags(articleIds []int64) map[int64][]models.Tag {
+	tags := map[int64][]models.Tag{}
+	if len(articleIds) == 0 {
+		return tags
+	}
+
+	args := make([]interface{}, len(articleIds))
+	for i, id := range articleIds {
+		args[i] = id
+	}
+	rows, err := models.DB.Query(`
+		SELECT at.article_id, t.id, t.name, t.slug, t.tag_type
+		FROM article_tags at
+		JOIN tags t ON t.id = at.tag_id
+		WHERE at.article_id IN (`+strings.TrimSuffix(strings.Repeat("?,", len(articleIds)), ",")+`)
+		ORDER BY t.name`, args...)
+	if err != nil {
+		return tags
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var articleId int64
+		var t models.Tag
+		if rows.Scan(&articleId, &t.ID, &t.Name, &t.Slug, &t.Type) == nil {
+			tags[articleId] = append(tags[articleId], t)
+		}
+	}
+	return tags
+}
+
+// articleTagList returns the tags of an article, never nil
+func articleTagList(tags map[int64][]models.Tag, articleId int64) []models.Tag {
+	if list := tags[articleId]; list != nil {
+		return list
+	}
+	return []models.Tag{}
+}
+
+// GetPublicTags lists the tags that have published articles, most used
+// first
+func GetPublicTags(c *gin.Context) {
+	if models.DB == nil {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": []models.Tag{}})
+		return
+	}
+
+	tagType := c.Query("type")
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 500 {
+		limit = 100
+	}
+
+	whereClause := publicArticleCondition
+	args := []interface{}{}
+	if tagType != "" {
+		whereClause += " AND t.tag_type = ?"
+		args = append(args, tagType)
+	}
+	args = append(args, limit)
+
+	rows, err := models.DB.Query(`
+		SELECT t.id, t.name, t.slug, t.tag_type, COUNT(*) as article_count
+		FROM tags t
+		JOIN article_tags at ON at.tag_id = t.id
+		JOIN articles a ON a.id = at.article_id
+		WHERE `+whereClause+`
+		GROUP BY t.id
+		ORDER BY article_count DESC, t.name
+		LIMIT ?`, args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	defer rows.Close()
+
+	tags := []models.Tag{}
+	for rows.Next() {
+		var t models.Tag
+		if rows.Scan(&t.ID, &t.Name, &t.Slug, &t.Type, &t.ArticleCount) == nil {
+			tags = append(tags, t)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": tags})
+}
+
+// GetPublicTagBySlug returns a tag for its tag page; the articles come from
+// /api/public/articles?tag=
+func GetPublicTagBySlug(c *gin.Context) {
+	if models.DB == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Tag not found"})
+		return
+	}
+
+	var t models.Tag
+	err := models.DB.QueryRow(`
+		SELECT t.id, t.name, t.slug, t.tag_type, COALESCE(t.description, ''),
+		       (SELECT COUNT(*) FROM article_tags at JOIN articles a ON a.id = at.article_id
+		        WHERE at.tag_id = t.id AND `+publicArticleCondition+`)
+		FROM tags t WHERE t.slug = ?`, c.Param("slug")).
+		Scan(&t.ID, &t.Name, &t.Slug, &t.Type, &t.Description, &t.ArticleCount)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Tag not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": t})
+}
@@ -0,0 +1,33 @@
+package handlers
+
+import "testing"
+
+func TestGenerateTagSlug(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"Hà Nội FC", "ha-noi-fc"},
+		{"Đội tuyển Việt Nam", "doi-tuyen-viet-nam"},
+		{"  V-League 2026!  ", "v-league-2026"},
+		{"Hoàng Anh Gia Lai", "hoang-anh-gia-lai"},
+	}
+	for _, tt := range tests {
+		if got := generateTagSlug(tt.name); got != tt.want {
+			t.Errorf("generateTagSlug(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// Names without a Latin letter or digit get a stable hash slug
+	for _, name := range []string{"⚽", "東京", "🏆 ⚽"} {
+		slug := generateTagSlug(name)
+		if !tagSlugPattern.MatchString(slug) {
+			t.Errorf("generateTagSlug(%q) = %q, not a valid slug", name, slug)
+		}
+		if again := generateTagSlug(name); again != slug {
+			t.Errorf("generateTagSlug(%q) not stable: %q then %q", name, slug, again)
+		}
+	}
+	if generateTagSlug("⚽") == generateTagSlug("東京") {
+		t.Error("different names share a hash slug")
+	}
+}
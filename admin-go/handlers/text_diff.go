@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"regexp"
+	"strings"
+)
+
+// maxDiffCells bounds the LCS table of a diff; larger changed regions are
+// reported as one deletion and one insertion
+const maxDiffCells = 4000000
+
+// htmlTokenPattern splits HTML into tags, words and whitespace runs
+var htmlTokenPattern = regexp.MustCompile(`<[^>]*>|[^\s<]+|\s+`)
+
+// diffOp is a run of tokens kept ('='), deleted ('-') or inserted ('+')
+type diffOp struct {
+	Kind   byte
+	Tokens []string
+}
+
+func tokenizeHTML(s string) []string {
+	return htmlTokenPattern.FindAllString(s, -1)
+}
+
+// diffTokens computes the changes turning a into b. The common prefix and
+// suffix are skipped; the rest is aligned on its longest common subsequence.
+func diffTokens(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := []diffOp{}
+	add := func(kind byte, token string) {
+		if n := len(ops); n > 0 && ops[n-1].Kind == kind {
+			ops[n-1].Tokens = append(ops[n-1].Tokens, token)
+			return
+		}
+		ops = append(ops, diffOp{Kind: kind, Tokens: []string{token}})
+	}
+
+	for _, t := range a[:prefix] {
+		add('=', t)
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA)*len(midB) > maxDiffCells {
+		for _, t := range midA {
+			add('-', t)
+		}
+		for _, t := range midB {
+			add('+', t)
+		}
+	} else {
+		// lcs[i][j] is the LCS length of midA[i:] and midB[j:]
+		cols := len(midB) + 1
+		lcs := make([]int32, (len(midA)+1)*cols)
+		for i := len(midA) - 1; i >= 0; i-- {
+			for j := len(midB) - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i*cols+j] = lcs[(i+1)*cols+j+1] + 1
+				} else if lcs[(i+1)*cols+j] >= lcs[i*cols+j+1] {
+					lcs[i*cols+j] = lcs[(i+1)*cols+j]
+				} else {
+					lcs[i*cols+j] = lcs[i*cols+j+1]
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < len(midA) && j < len(midB) {
+			switch {
+			case midA[i] == midB[j]:
+				add('=', midA[i])
+				i++
+				j++
+			case lcs[(i+1)*cols+j] >= lcs[i*cols+j+1]:
+				add('-', midA[i])
+				i++
+			default:
+				add('+', midB[j])
+				j++
+			}
+		}
+		for ; i < len(midA); i++ {
+			add('-', midA[i])
+		}
+		for ; j < len(midB); j++ {
+			add('+', midB[j])
+		}
+	}
+
+	for _, t := range a[len(a)-suffix:] {
+		add('=', t)
+	}
+	return ops
+}
+
+// renderHTMLDiff renders a diff of HTML tokens as HTML, wrapping inserted
+// text in <ins> and deleted text in <del>. Inserted tags are kept and
+// deleted tags dropped so the result follows the newer markup.
+func renderHTMLDiff(ops []diffOp) string {
+	var b strings.Builder
+	for _, op := range ops {
+		if op.Kind == '=' {
+			for _, t := range op.Tokens {
+				b.WriteString(t)
+			}
+			continue
+		}
+
+		wrap := "ins"
+		if op.Kind == '-' {
+			wrap = "del"
+		}
+		open := false
+		for _, t := range op.Tokens {
+			if strings.HasPrefix(t, "<") {
+				if open {
+					b.WriteString("</" + wrap + ">")
+					open = false
+				}
+				if op.Kind == '+' {
+					b.WriteString(t)
+				}
+				continue
+			}
+			if !open {
+				b.WriteString("<" + wrap + ">")
+				open = true
+			}
+			b.WriteString(t)
+		}
+		if open {
+			b.WriteString("</" + wrap + ">")
+		}
+	}
+	return b.String()
+}
+
+// diffWordCounts counts the words inserted and deleted by a diff
+func diffWordCounts(ops []diffOp) (inserted, deleted int) {
+	for _, op := range ops {
+		for _, t := range op.Tokens {
+			if strings.HasPrefix(t, "<") || strings.TrimSpace(t) == "" {
+				continue
+			}
+			switch op.Kind {
+			case '+':
+				inserted++
+			case '-':
+				deleted++
+			}
+		}
+	}
+	return inserted, deleted
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTokenizeHTML(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", nil},
+		{"Xin chào", []string{"Xin", " ", "chào"}},
+		{"<p>Một  hai</p>\n", []string{"<p>", "Một", "  ", "hai", "</p>", "\n"}},
+		{`<a href="/x y">link</a>`, []string{`<a href="/x y">`, "link", "</a>"}},
+	}
+	for _, tt := range tests {
+		if got := tokenizeHTML(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("tokenizeHTML(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// formatDiff writes ops compactly, e.g. "=a b|-c|+d"
+func formatDiff(ops []diffOp) string {
+	parts := make([]string, len(ops))
+	for i, op := range ops {
+		parts[i] = string(op.Kind) + strings.Join(op.Tokens, "")
+	}
+	return strings.Join(parts, "|")
+}
+
+func TestDiffTokens(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{"", "", ""},
+		{"a b", "a b", "=a b"},
+		{"", "a b", "+a b"},
+		{"a b", "", "-a b"},
+		{"a b c", "a x c", "=a |-b|+x|= c"},
+		{"a b c", "a c", "=a |-b |=c"},
+		{"a c", "a b c", "=a |+b |=c"},
+		{"one two three four", "two four five", "-one |=two |-three |=four|+ five"},
+	}
+	for _, tt := range tests {
+		ops := diffTokens(tokenizeHTML(tt.a), tokenizeHTML(tt.b))
+		if got := formatDiff(ops); got != tt.want {
+			t.Errorf("diffTokens(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDiffTokensRebuildsBoth(t *testing.T) {
+	pairs := [][2]string{
+		{"<p>Tin tức mới nhất</p>", "<p>Tin tức <b>nóng</b> nhất</p>"},
+		{"a b a b a", "b a b a b"},
+		{"x y z", "z y x"},
+	}
+	for _, p := range pairs {
+		a, b := tokenizeHTML(p[0]), tokenizeHTML(p[1])
+		var oldText, newText strings.Builder
+		for _, op := range diffTokens(a, b) {
+			for _, t := range op.Tokens {
+				if op.Kind != '+' {
+					oldText.WriteString(t)
+				}
+				if op.Kind != '-' {
+					newText.WriteString(t)
+				}
+			}
+		}
+		if oldText.String() != p[0] || newText.String() != p[1] {
+			t.Errorf("diff of %q and %q rebuilds %q and %q", p[0], p[1], oldText.String(), newText.String())
+		}
+	}
+}
+
+func TestRenderHTMLDiff(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want string
+	}{
+		{"<p>a b</p>", "<p>a b</p>", "<p>a b</p>"},
+		{"<p>a b</p>", "<p>a c</p>", "<p>a <del>b</del><ins>c</ins></p>"},
+		{"<p>a</p>", "<p>a <b>b</b></p>", "<p>a<ins> </ins><b><ins>b</ins></b></p>"},
+		{"<p>a <i>b</i></p>", "<p>a</p>", "<p>a<del> </del><del>b</del></p>"},
+	}
+	for _, tt := range tests {
+		got := renderHTMLDiff(diffTokens(tokenizeHTML(tt.a), tokenizeHTML(tt.b)))
+		if got != tt.want {
+			t.Errorf("renderHTMLDiff(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestDiffWordCounts(t *testing.T) {
+	tests := []struct {
+		a, b                      string
+		wantInserted, wantDeleted int
+	}{
+		{"a b c", "a b c", 0, 0},
+		{"a b c", "a x y c", 2, 1},
+		{"<p>a</p>", "<h2>a</h2>", 0, 0},
+		{"", "<p>một hai ba</p>", 3, 0},
+	}
+	for _, tt := range tests {
+		inserted, deleted := diffWordCounts(diffTokens(tokenizeHTML(tt.a), tokenizeHTML(tt.b)))
+		if inserted != tt.wantInserted || deleted != tt.wantDeleted {
+			t.Errorf("diffWordCounts(%q, %q) = %d, %d; want %d, %d",
+				tt.a, tt.b, inserted, deleted, tt.wantInserted, tt.wantDeleted)
+		}
+	}
+}
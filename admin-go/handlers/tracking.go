@@ -2,8 +2,10 @@ package handlers
 
 import (
 	"admin-go/models"
+	"database/sql"
 	"encoding/json"
 	"net/http"
+	"regexp"
 	"strings"
 	"time"
 
@@ -41,14 +43,23 @@ func Track(c *gin.Context) {
 		return
 	}
 
+	// Respect Do-Not-Track / Global Privacy Control
+	if isTrackingOptOut(c) {
+		c.JSON(http.StatusOK, gin.H{"success": true, "ignored": "dnt"})
+		return
+	}
+
+	countTrackRequest()
+
 	var req models.TrackingRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		countTrackError()
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request"})
 		return
 	}
 
 	ip := getClientIP(c)
-	now := time.Now()
+	now := reportNow()
 
 	switch strings.ToLower(req.Action) {
 	case "pageview":
@@ -57,7 +68,15 @@ func Track(c *gin.Context) {
 		recordHeartbeat(req, ip, now)
 	case "leave":
 		recordLeave(req, ip, now)
+	case "event":
+		if strings.TrimSpace(req.Event) == "" {
+			countTrackError()
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "event is required"})
+			return
+		}
+		recordEvent(req, now)
 	default:
+		countTrackError()
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid action"})
 		return
 	}
@@ -65,24 +84,38 @@ func Track(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
-func recordPageView(req models.TrackingRequest, ip string, now time.Time) {
-	today := now.Format("2006-01-02")
+func recordPageView(req models.TrackingRequest, rawIP string, now time.Time) {
+	// Days are bucketed in the reporting timezone. daily_stats and the hourly
+	// totals are kept current here; the stats aggregator rebuilds them and
+	// the dimension rollups exactly from these rows.
+	day := dayRange(now, 1)
 
-	// Check if this IP has visited before today (to determine if new visitor)
+	// Only the anonymized form of the IP is ever stored
+	ip := anonymizeIP(rawIP)
+
+	// A visit is one row per visitor and day. Visitors are told apart by IP,
+	// or by visitor_id when truncated IPs are shared by a whole network.
+	visitor := ip
+	if visitorColumn() == "visitor_id" {
+		visitor = req.VisitorId
+	}
+
+	// Check if this visitor has visited before today (to determine if new visitor)
 	var hasVisitedBefore int
 	models.DB.QueryRow(`
 		SELECT COUNT(*) FROM visitor_logs 
-		WHERE ip_address = ? AND DATE(visit_time) < ?`, ip, today).Scan(&hasVisitedBefore)
+		WHERE `+visitorColumn()+` = ? AND visit_time < ?`, visitor, day.From).Scan(&hasVisitedBefore)
 
 	isNewVisitor := hasVisitedBefore == 0
 
-	// Check if visitor exists today (same IP on same day)
+	// Check if visitor exists today (same visitor on same day)
 	var existingID int64
 	var existingPageViewCount int
 	var existingVisitedPages string
+	var existingVisitTime time.Time
 	err := models.DB.QueryRow(`
-		SELECT id, page_view_count, COALESCE(visited_pages, '[]') FROM visitor_logs 
-		WHERE ip_address = ? AND DATE(visit_time) = ?`, ip, today).Scan(&existingID, &existingPageViewCount, &existingVisitedPages)
+		SELECT id, page_view_count, COALESCE(visited_pages, '[]'), visit_time FROM visitor_logs 
+		WHERE `+visitorColumn()+` = ? AND visit_time >= ? AND visit_time < ?`, visitor, day.From, day.To).Scan(&existingID, &existingPageViewCount, &existingVisitedPages, &existingVisitTime)
 
 	screenRes := ""
 	if req.ScreenWidth > 0 && req.ScreenHeight > 0 {
@@ -111,38 +144,178 @@ func recordPageView(req models.TrackingRequest, ip string, now time.Time) {
 		}
 		visitedPagesJson, _ := json.Marshal(visitedPages)
 
-		models.DB.Exec(`
+		trackExec(`
 			UPDATE visitor_logs 
 			SET page_view_count = page_view_count + 1, 
 			    last_visit_time = ?,
 			    visited_pages = ?
 			WHERE id = ?`, now, string(visitedPagesJson), existingID)
 
-		// Update daily stats - increment PV only (UV already counted)
-		updateDailyStatsPV(today)
+		recordPageViewEvent(existingID, req, now)
+		dashboardPageView(now, false, ip, req)
+		if updateTodayStats(existingVisitTime, false, false, false) != nil {
+			countTrackError()
+		}
 	} else {
-		// Create new record - first visit today from this IP
+		// Create new record - first visit today from this visitor
 		visitedPages, _ := json.Marshal([]string{req.PagePath})
 
-		// Try to get geo-location info (placeholder - you'll need a geo service)
-		countryCode, countryName, city := getGeoLocation(ip)
+		// A visitor_id not seen yet today starts a new session
+		var visitorLogsToday int
+		models.DB.QueryRow(`
+			SELECT COUNT(*) FROM visitor_logs
+			WHERE visitor_id = ? AND visit_time >= ? AND visit_time < ?`, req.VisitorId, day.From, day.To).Scan(&visitorLogsToday)
+
+		// Use a cached location if we have one; otherwise the background
+		// worker resolves it so tracking never waits on a geo provider
+		geo, geoCached := cachedGeoLocation(rawIP)
+		if !geoCached {
+			geo = &GeoLocationInfo{}
+		}
 
-		models.DB.Exec(`
+		result, err := trackExec(`
 			INSERT INTO visitor_logs (
-				ip_address, visitor_id, session_id, page_path, page_type, 
+				ip_address, ip_hex, visitor_id, session_id, page_path, page_type, 
 				reference_id, referrer, user_agent, device_type, os, browser,
 				screen_resolution, country_code, country_name, city,
+				region, asn, org, is_hosting,
 				visit_time, is_new_visitor, page_view_count, 
 				visited_pages, last_visit_time
-			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-			ip, req.VisitorId, req.SessionId, req.PagePath, req.PageType,
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			ip, ipHex(ip), req.VisitorId, req.SessionId, req.PagePath, req.PageType,
 			req.ReferenceId, req.Referrer, "", req.DeviceType, req.OS, req.Browser,
-			screenRes, countryCode, countryName, city,
+			screenRes, geo.CountryCode, geo.CountryName, geo.City,
+			geo.Region, geo.ASN, geo.Org, geo.IsHosting,
 			now, isNewVisitor, 1, string(visitedPages), now)
+		if err == nil {
+			logID, _ := result.LastInsertId()
+			recordPageViewEvent(logID, req, now)
+			dashboardPageView(now, true, ip, req)
+			if updateTodayStats(now, true, isNewVisitor, visitorLogsToday == 0) != nil {
+				countTrackError()
+			}
+			if !geoCached {
+				enqueueGeoEnrichment(logID, rawIP)
+			}
+		}
+	}
+}
 
-		// Update daily stats - increment both PV and UV
-		updateDailyStatsNew(today, ip, isNewVisitor)
+// updateTodayStats counts a page view in the daily_stats row and the hourly
+// total of its visit, so today's numbers do not wait for the stats
+// aggregator. newVisit is the first page view of a visitor today, which also
+// counts a unique visitor; newSession is the first visit of a visitor_id.
+func updateTodayStats(visitTime time.Time, newVisit, isNewVisitor, newSession bool) error {
+	var uv, newVisitors, returningVisitors, sessions int
+	if newVisit {
+		uv = 1
+		if isNewVisitor {
+			newVisitors = 1
+		} else {
+			returningVisitors = 1
+		}
+	}
+	if newSession {
+		sessions = 1
+	}
+
+	_, err := models.DB.Exec(`
+		INSERT INTO daily_stats (stats_date, page_views, unique_visitors, unique_ips, new_visitors, returning_visitors, sessions)
+		VALUES (?, 1, ?, ?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+		    page_views = page_views + 1,
+		    unique_visitors = unique_visitors + VALUES(unique_visitors),
+		    unique_ips = unique_ips + VALUES(unique_ips),
+		    new_visitors = new_visitors + VALUES(new_visitors),
+		    returning_visitors = returning_visitors + VALUES(returning_visitors),
+		    sessions = sessions + VALUES(sessions)`,
+		visitTime.Format("2006-01-02"), uv, uv, newVisitors, returningVisitors, sessions)
+	if err != nil {
+		return err
+	}
+
+	// Hourly buckets are the UTC hour of the visit, like the aggregator's
+	_, err = models.DB.Exec(`
+		INSERT INTO stats_hourly (bucket_hour, dimension, dim_value, page_views, unique_visitors)
+		VALUES (?, 'total', '', 1, ?)
+		ON DUPLICATE KEY UPDATE
+		    page_views = page_views + 1,
+		    unique_visitors = unique_visitors + VALUES(unique_visitors)`,
+		visitTime.UTC().Truncate(time.Hour), uv)
+	return err
+}
+
+// trackExec runs a write of the tracking endpoint and counts a failure
+// towards the tracking error rate alert
+func trackExec(query string, args ...interface{}) (sql.Result, error) {
+	result, err := models.DB.Exec(query, args...)
+	if err != nil {
+		countTrackError()
+	}
+	return result, err
+}
+
+// recordPageViewEvent appends a page view to the visit's ordered journey
+func recordPageViewEvent(logID int64, req models.TrackingRequest, now time.Time) {
+	if logID == 0 {
+		return
+	}
+	pagePath := req.PagePath
+	if pagePath == "" {
+		pagePath = "/"
 	}
+	trackExec(`
+		INSERT INTO page_views (log_id, visitor_id, session_id, page_path, page_type, reference_id, referrer, viewed_at, article_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		logID, req.VisitorId, req.SessionId, pagePath, req.PageType, req.ReferenceId, req.Referrer, now,
+		articleIDForPage(req))
+
+	// First visit of this visitor, for retention cohorts
+	if req.VisitorId != "" {
+		trackExec("INSERT IGNORE INTO visitor_first_seen (visitor_id, first_seen_at) VALUES (?, ?)", req.VisitorId, now)
+	}
+
+	// Viewers of a live match room count from their first page view
+	recordLiveViewer(req, now)
+}
+
+// articleSlugPattern matches the slugs articles can be published under
+var articleSlugPattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// articleIDForPage links a page view to the article it shows. /article/<id>
+// URLs carry the id as reference_id; other article URLs end with the slug
+// (/<category>/<slug> or /<slug>).
+func articleIDForPage(req models.TrackingRequest) *int64 {
+	var id int64
+	if req.PageType == "article" && req.ReferenceId != "" {
+		if models.DB.QueryRow("SELECT id FROM articles WHERE id = ?", req.ReferenceId).Scan(&id) == nil {
+			return &id
+		}
+	}
+
+	segments := strings.Split(strings.Trim(req.PagePath, "/"), "/")
+	slug := segments[len(segments)-1]
+	if !articleSlugPattern.MatchString(slug) {
+		return nil
+	}
+	if models.DB.QueryRow("SELECT id FROM articles WHERE slug = ?", slug).Scan(&id) != nil {
+		return nil
+	}
+	return &id
+}
+
+// recordEvent stores a custom event (e.g. a funnel goal such as "signup_click")
+func recordEvent(req models.TrackingRequest, now time.Time) {
+	eventName := strings.TrimSpace(req.Event)
+	// event_name is VARCHAR(100): cut by characters, not bytes, so a
+	// multibyte character is never split
+	if runes := []rune(eventName); len(runes) > 100 {
+		eventName = string(runes[:100])
+	}
+	trackExec(`
+		INSERT INTO visitor_events (visitor_id, session_id, event_name, page_path, page_type, reference_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		req.VisitorId, req.SessionId, eventName, req.PagePath, req.PageType, req.ReferenceId, now)
 }
 
 func recordHeartbeat(req models.TrackingRequest, ip string, now time.Time) {
@@ -151,44 +324,65 @@ func recordHeartbeat(req models.TrackingRequest, ip string, now time.Time) {
 		roomKey = "match_" + req.ReferenceId
 	}
 
+	recordLiveViewer(req, now)
+
 	// Update realtime stats
 	var existingRoom int64
 	err := models.DB.QueryRow("SELECT id FROM realtime_stats WHERE room_key = ?", roomKey).Scan(&existingRoom)
 
 	if err == nil {
 		if req.Status == "leave" || req.Event == "visibility_hidden" {
-			models.DB.Exec(`
+			trackExec(`
 				UPDATE realtime_stats 
-				SET online_count = MAX(0, online_count - 1), last_updated = ? 
+				SET online_count = GREATEST(online_count, 1) - 1, last_updated = ? 
 				WHERE room_key = ?`, now, roomKey)
 		} else {
-			models.DB.Exec(`
+			trackExec(`
 				UPDATE realtime_stats 
 				SET last_updated = ? 
 				WHERE room_key = ?`, now, roomKey)
 		}
 	} else {
-		models.DB.Exec(`
+		trackExec(`
 			INSERT INTO realtime_stats (room_key, online_count, last_updated) 
 			VALUES (?, 1, ?)`, roomKey, now)
 	}
 
+	// Tabs hidden on mobile are often never unloaded, so keep the deepest
+	// scroll position of the page view up to date
+	if req.ScrollDepth > 0 {
+		trackExec(`
+			UPDATE page_views 
+			SET scroll_depth = GREATEST(scroll_depth, ?) 
+			WHERE visitor_id = ? AND page_path = ? 
+			ORDER BY viewed_at DESC 
+			LIMIT 1`, clampScrollDepth(req.ScrollDepth), req.VisitorId, req.PagePath)
+	}
+
 	// Update visitor last visit time
-	models.DB.Exec(`
+	day := dayRange(now, 1)
+	trackExec(`
 		UPDATE visitor_logs 
 		SET last_visit_time = ? 
-		WHERE visitor_id = ? AND DATE(visit_time) = ?`,
-		now, req.VisitorId, now.Format("2006-01-02"))
+		WHERE visitor_id = ? AND visit_time >= ? AND visit_time < ?`,
+		now, req.VisitorId, day.From, day.To)
 }
 
 func recordLeave(req models.TrackingRequest, ip string, now time.Time) {
 	if req.Duration > 0 {
-		models.DB.Exec(`
+		trackExec(`
 			UPDATE visitor_logs 
 			SET duration = ? 
 			WHERE visitor_id = ? AND page_path = ? 
 			ORDER BY visit_time DESC 
 			LIMIT 1`, req.Duration, req.VisitorId, req.PagePath)
+
+		trackExec(`
+			UPDATE page_views 
+			SET duration = ?, scroll_depth = GREATEST(scroll_depth, ?) 
+			WHERE visitor_id = ? AND page_path = ? 
+			ORDER BY viewed_at DESC 
+			LIMIT 1`, req.Duration, clampScrollDepth(req.ScrollDepth), req.VisitorId, req.PagePath)
 	}
 
 	// Update realtime stats
@@ -196,72 +390,22 @@ func recordLeave(req models.TrackingRequest, ip string, now time.Time) {
 	recordHeartbeat(req, ip, now)
 }
 
-func updateDailyStatsNew(date string, ip string, isNewVisitor bool) {
-	// First visit today from this IP - increment PV, UV, and unique IPs
-	var existingID int64
-	err := models.DB.QueryRow("SELECT id FROM daily_stats WHERE stats_date = ?", date).Scan(&existingID)
-
-	if err == nil {
-		// Update existing daily_stats record
-		if isNewVisitor {
-			models.DB.Exec(`
-				UPDATE daily_stats 
-				SET page_views = page_views + 1,
-				    unique_visitors = unique_visitors + 1,
-				    unique_ips = unique_ips + 1,
-				    new_visitors = new_visitors + 1,
-				    updated_at = CURRENT_TIMESTAMP 
-				WHERE stats_date = ?`, date)
-		} else {
-			models.DB.Exec(`
-				UPDATE daily_stats 
-				SET page_views = page_views + 1,
-				    unique_visitors = unique_visitors + 1,
-				    unique_ips = unique_ips + 1,
-				    returning_visitors = returning_visitors + 1,
-				    updated_at = CURRENT_TIMESTAMP 
-				WHERE stats_date = ?`, date)
-		}
-	} else {
-		// Create new daily_stats record
-		newVisitorCount := 0
-		returningVisitorCount := 0
-		if isNewVisitor {
-			newVisitorCount = 1
-		} else {
-			returningVisitorCount = 1
-		}
-		models.DB.Exec(`
-			INSERT INTO daily_stats (stats_date, page_views, unique_visitors, unique_ips, new_visitors, returning_visitors) 
-			VALUES (?, 1, 1, 1, ?, ?)`, date, newVisitorCount, returningVisitorCount)
+// clampScrollDepth keeps a reported scroll depth within 0-100 percent
+func clampScrollDepth(depth int) int {
+	if depth < 0 {
+		return 0
 	}
-}
-
-func updateDailyStatsPV(date string) {
-	// Existing visitor today - only increment PV
-	var existingID int64
-	err := models.DB.QueryRow("SELECT id FROM daily_stats WHERE stats_date = ?", date).Scan(&existingID)
-
-	if err == nil {
-		// Update existing daily_stats record - only increment page_views
-		models.DB.Exec(`
-			UPDATE daily_stats 
-			SET page_views = page_views + 1,
-			    updated_at = CURRENT_TIMESTAMP 
-			WHERE stats_date = ?`, date)
-	} else {
-		// This shouldn't happen, but create a record just in case
-		models.DB.Exec(`
-			INSERT INTO daily_stats (stats_date, page_views, unique_visitors, unique_ips, new_visitors) 
-			VALUES (?, 1, 0, 0, 0)`, date)
+	if depth > 100 {
+		return 100
 	}
+	return depth
 }
 
 func GetOnlineCount(c *gin.Context) {
 	room := c.Query("room")
 
 	var total int
-	fiveMinutesAgo := time.Now().Add(-5 * time.Minute).Format("2006-01-02 15:04:05")
+	fiveMinutesAgo := time.Now().Add(-5 * time.Minute)
 
 	if room != "" {
 		models.DB.QueryRow(`
@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// visitorSortColumns maps the sort parameter of the visitor list to the
+// indexed column it orders by; the keyset cursor compares against the same
+// column
+var visitorSortColumns = map[string]string{
+	"visit_time":      "visit_time",
+	"last_visit_time": "last_visit_time",
+	"page_view_count": "page_view_count",
+	"duration":        "duration",
+}
+
+// visitorListFilter builds the visitor_logs condition for the list filters:
+// date or from/to, ip (address or CIDR), country, device, browser, os, path
+// (prefix), referrer_source, visitor_type (new|returning) and min_pageviews
+func visitorListFilter(c *gin.Context) (string, []interface{}, error) {
+	conditions := []string{"1=1"}
+	args := []interface{}{}
+
+	loc, err := reportLocation(c)
+	if err != nil {
+		return "", nil, err
+	}
+	if date := c.Query("date"); date != "" {
+		day, err := dateRange(date, loc)
+		if err != nil {
+			return "", nil, errors.New("Invalid date, expected YYYY-MM-DD")
+		}
+		conditions = append(conditions, "visit_time >= ? AND visit_time < ?")
+		args = append(args, day.From, day.To)
+	} else if c.Query("from") != "" || c.Query("to") != "" {
+		r, err := parseDateRange(c.Query("from"), c.Query("to"), time.Now().In(loc))
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, "visit_time >= ? AND visit_time < ?")
+		args = append(args, r.From, r.To)
+	}
+
+	if ip := strings.TrimSpace(c.Query("ip")); ip != "" {
+		condition, ipArgs, err := ipFilterCondition(ip)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, ipArgs...)
+	}
+
+	if country := strings.TrimSpace(c.Query("country")); country != "" {
+		conditions = append(conditions, "(country_code = ? OR country_name = ?)")
+		args = append(args, country, country)
+	}
+
+	for param, column := range map[string]string{"device": "device_type", "browser": "browser", "os": "os"} {
+		if value := strings.TrimSpace(c.Query(param)); value != "" {
+			conditions = append(conditions, column+" = ?")
+			args = append(args, value)
+		}
+	}
+
+	// Landing page or any page visited that day; every page view, the
+	// landing page included, is a page_views row
+	if path := strings.TrimSpace(c.Query("path")); path != "" {
+		conditions = append(conditions, "id IN (SELECT log_id FROM page_views WHERE page_path LIKE ?)")
+		args = append(args, escapeLike(path)+"%")
+	}
+
+	if source := strings.TrimSpace(c.Query("referrer_source")); source != "" {
+		conditions = append(conditions, "("+referrerSourceExpr+") = ?")
+		args = append(args, source)
+	}
+
+	switch c.Query("visitor_type") {
+	case "":
+	case "new":
+		conditions = append(conditions, "is_new_visitor = 1")
+	case "returning":
+		conditions = append(conditions, "is_new_visitor = 0")
+	default:
+		return "", nil, errors.New("visitor_type must be new or returning")
+	}
+
+	if minPageviews := c.Query("min_pageviews"); minPageviews != "" {
+		n, err := strconv.Atoi(minPageviews)
+		if err != nil || n < 0 {
+			return "", nil, errors.New("min_pageviews must be a non-negative number")
+		}
+		conditions = append(conditions, "page_view_count >= ?")
+		args = append(args, n)
+	}
+
+	return strings.Join(conditions, " AND "), args, nil
+}
+
+// ipHex is the ip_hex column of a stored IP: its 16-byte (IPv4-mapped) form
+// in hex, so address ranges are ranges of the indexed column. Hashed IPs
+// have none.
+func ipHex(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	return strings.ToUpper(hex.EncodeToString(parsed.To16()))
+}
+
+// ipFilterCondition matches a single address (raw or anonymized form) or
+// every stored address inside a CIDR block
+func ipFilterCondition(ip string) (string, []interface{}, error) {
+	if !strings.Contains(ip, "/") {
+		return "ip_address IN (?, ?)", []interface{}{ip, anonymizeIP(ip)}, nil
+	}
+
+	_, network, err := net.ParseCIDR(ip)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid CIDR %q", ip)
+	}
+
+	first := network.IP
+	if len(network.Mask) == net.IPv4len {
+		first = first.To4()
+	}
+	last := make(net.IP, len(first))
+	for i := range first {
+		last[i] = first[i] | ^network.Mask[i]
+	}
+
+	// Hashed addresses have an empty ip_hex, outside every range
+	return "ip_hex BETWEEN ? AND ?", []interface{}{ipHex(first.String()), ipHex(last.String())}, nil
+}
+
+// escapeLike escapes the LIKE wildcards of a user supplied value
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// visitorCursor is the position after the last row of a keyset page
+type visitorCursor struct {
+	Value json.RawMessage `json:"v"`
+	ID    int64           `json:"id"`
+}
+
+func encodeVisitorCursor(value interface{}, id int64) string {
+	if t, ok := value.(time.Time); ok {
+		value = t.UTC().Format(time.RFC3339Nano)
+	}
+	raw, _ := json.Marshal(value)
+	data, _ := json.Marshal(visitorCursor{Value: raw, ID: id})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeVisitorCursor returns the sort value and id stored in a cursor
+func decodeVisitorCursor(cursor, sortKey string) (interface{}, int64, error) {
+	invalid := errors.New("invalid cursor")
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, 0, invalid
+	}
+	var vc visitorCursor
+	if err := json.Unmarshal(data, &vc); err != nil {
+		return nil, 0, invalid
+	}
+
+	switch sortKey {
+	case "visit_time", "last_visit_time":
+		var s string
+		if json.Unmarshal(vc.Value, &s) != nil {
+			return nil, 0, invalid
+		}
+		t, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, 0, invalid
+		}
+		return t, vc.ID, nil
+	default:
+		var n int64
+		if json.Unmarshal(vc.Value, &n) != nil {
+			return nil, 0, invalid
+		}
+		return n, vc.ID, nil
+	}
+}
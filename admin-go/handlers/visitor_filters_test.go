@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"admin-go/config"
+	"encoding/base64"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestIPHex(t *testing.T) {
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"203.0.113.7", "00000000000000000000FFFFCB007107"},
+		{"::ffff:203.0.113.7", "00000000000000000000FFFFCB007107"},
+		{"2001:db8::1", "20010DB8000000000000000000000001"},
+		{"", ""},
+		{"unknown", ""},
+		{"h:0123456789abcdef0123456789abcdef", ""},
+	}
+	for _, tt := range tests {
+		if got := ipHex(tt.ip); got != tt.want {
+			t.Errorf("ipHex(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestIPFilterCondition(t *testing.T) {
+	withConfig(t, &config.Config{PrivacyIPMode: "none"})
+	tests := []struct {
+		ip        string
+		wantWhere string
+		wantArgs  []interface{}
+		wantErr   bool
+	}{
+		{
+			ip:        "203.0.113.7",
+			wantWhere: "ip_address IN (?, ?)",
+			wantArgs:  []interface{}{"203.0.113.7", "203.0.113.7"},
+		},
+		{
+			ip:        "203.0.113.0/24",
+			wantWhere: "ip_hex BETWEEN ? AND ?",
+			wantArgs:  []interface{}{"00000000000000000000FFFFCB007100", "00000000000000000000FFFFCB0071FF"},
+		},
+		{
+			ip:        "203.0.113.77/32",
+			wantWhere: "ip_hex BETWEEN ? AND ?",
+			wantArgs:  []interface{}{"00000000000000000000FFFFCB00714D", "00000000000000000000FFFFCB00714D"},
+		},
+		{
+			ip:        "2001:db8::/32",
+			wantWhere: "ip_hex BETWEEN ? AND ?",
+			wantArgs:  []interface{}{"20010DB8000000000000000000000000", "20010DB8FFFFFFFFFFFFFFFFFFFFFFFF"},
+		},
+		{ip: "203.0.113.0/33", wantErr: true},
+		{ip: "unknown/24", wantErr: true},
+	}
+	for _, tt := range tests {
+		where, args, err := ipFilterCondition(tt.ip)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ipFilterCondition(%q) error = %v, wantErr %v", tt.ip, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if where != tt.wantWhere || !reflect.DeepEqual(args, tt.wantArgs) {
+			t.Errorf("ipFilterCondition(%q) = %q %v, want %q %v", tt.ip, where, args, tt.wantWhere, tt.wantArgs)
+		}
+	}
+}
+
+func TestVisitorCursorRoundTrip(t *testing.T) {
+	visited := time.Date(2026, 3, 14, 9, 26, 53, 589793000, time.FixedZone("ICT", 7*3600))
+	tests := []struct {
+		sortKey string
+		value   interface{}
+		want    interface{}
+	}{
+		{"visit_time", visited, visited.UTC()},
+		{"last_visit_time", visited.UTC(), visited.UTC()},
+		{"duration", 125, int64(125)},
+		{"page_view_count", int64(0), int64(0)},
+	}
+	for _, tt := range tests {
+		cursor := encodeVisitorCursor(tt.value, 42)
+		value, id, err := decodeVisitorCursor(cursor, tt.sortKey)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tt.sortKey, err)
+			continue
+		}
+		if !reflect.DeepEqual(value, tt.want) || id != 42 {
+			t.Errorf("%s: decoded %#v, %d; want %#v, 42", tt.sortKey, value, id, tt.want)
+		}
+	}
+}
+
+func TestDecodeVisitorCursorInvalid(t *testing.T) {
+	raw := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name    string
+		cursor  string
+		sortKey string
+	}{
+		{"not base64", "!!!", "duration"},
+		{"not JSON", raw("duration"), "duration"},
+		{"time for a number", encodeVisitorCursor(time.Now(), 1), "duration"},
+		{"number for a time", encodeVisitorCursor(125, 1), "visit_time"},
+		{"malformed time", raw(`{"v":"yesterday","id":1}`), "visit_time"},
+	}
+	for _, tt := range tests {
+		if value, _, err := decodeVisitorCursor(tt.cursor, tt.sortKey); err == nil {
+			t.Errorf("%s: decoded %#v, want an error", tt.name, value)
+		}
+	}
+}
@@ -5,51 +5,93 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-func GetVisitorStats(c *gin.Context) {
-	today := time.Now().Format("2006-01-02")
-	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
-	last7Days := time.Now().AddDate(0, 0, -7).Format("2006-01-02")
-	last30Days := time.Now().AddDate(0, 0, -30).Format("2006-01-02")
-
-	// Today stats - PV is sum of page_view_count, UV is unique IPs
-	var todayPV int
-	models.DB.QueryRow(`SELECT COALESCE(SUM(page_view_count), 0) FROM visitor_logs WHERE DATE(visit_time) = ?`, today).Scan(&todayPV)
-
-	var todayUV int
-	models.DB.QueryRow(`SELECT COUNT(DISTINCT ip_address) FROM visitor_logs WHERE DATE(visit_time) = ?`, today).Scan(&todayUV)
-
-	var todayNew int
-	models.DB.QueryRow(`SELECT COUNT(*) FROM visitor_logs WHERE DATE(visit_time) = ? AND is_new_visitor = 1`, today).Scan(&todayNew)
-
-	// Yesterday stats
-	var yesterdayPV int
-	models.DB.QueryRow(`SELECT COALESCE(SUM(page_view_count), 0) FROM visitor_logs WHERE DATE(visit_time) = ?`, yesterday).Scan(&yesterdayPV)
-	var yesterdayUV int
-	models.DB.QueryRow(`SELECT COUNT(DISTINCT ip_address) FROM visitor_logs WHERE DATE(visit_time) = ?`, yesterday).Scan(&yesterdayUV)
-
-	// Last 7 days total
-	var last7PV int
-	models.DB.QueryRow(`SELECT COALESCE(SUM(page_view_count), 0) FROM visitor_logs WHERE DATE(visit_time) >= ?`, last7Days).Scan(&last7PV)
-	var last7UV int
-	models.DB.QueryRow(`SELECT COUNT(DISTINCT ip_address) FROM visitor_logs WHERE DATE(visit_time) >= ?`, last7Days).Scan(&last7UV)
-
-	// Last 30 days total
-	var last30PV int
-	models.DB.QueryRow(`SELECT COALESCE(SUM(page_view_count), 0) FROM visitor_logs WHERE DATE(visit_time) >= ?`, last30Days).Scan(&last30PV)
-	var last30UV int
-	models.DB.QueryRow(`SELECT COUNT(DISTINCT ip_address) FROM visitor_logs WHERE DATE(visit_time) >= ?`, last30Days).Scan(&last30UV)
+// visitorSummary holds the headline metrics of a period
+type visitorSummary struct {
+	PV                int64 `json:"pv"`
+	UV                int64 `json:"uv"`
+	NewVisitors       int64 `json:"new_visitors"`
+	ReturningVisitors int64 `json:"returning_visitors"`
+	Sessions          int64 `json:"sessions"`
+	AvgDuration       int64 `json:"avg_duration"`
+}
+
+// getVisitorSummary reads daily_stats when the range is in the reporting
+// timezone. Over several days UV and sessions are counted from visitor_logs,
+// since a visitor seen on two days is still one visitor.
+func getVisitorSummary(r statsRange) visitorSummary {
+	var s visitorSummary
+	if useRollups(r) {
+		models.DB.QueryRow(`
+			SELECT COALESCE(SUM(page_views), 0),
+			       COALESCE(SUM(unique_visitors), 0),
+			       COALESCE(SUM(new_visitors), 0),
+			       COALESCE(SUM(returning_visitors), 0),
+			       COALESCE(SUM(sessions), 0),
+			       COALESCE(ROUND(SUM(avg_duration * (new_visitors + returning_visitors)) / NULLIF(SUM(new_visitors + returning_visitors), 0)), 0)
+			FROM daily_stats
+			WHERE stats_date >= ? AND stats_date <= ?`, r.FromDate(), r.ToDate()).
+			Scan(&s.PV, &s.UV, &s.NewVisitors, &s.ReturningVisitors, &s.Sessions, &s.AvgDuration)
+		if r.Days() > 1 {
+			models.DB.QueryRow(`
+				SELECT COUNT(DISTINCT `+visitorColumn()+`), COUNT(DISTINCT visitor_id)
+				FROM visitor_logs
+				WHERE visit_time >= ? AND visit_time < ?`, r.From, r.To).
+				Scan(&s.UV, &s.Sessions)
+		}
+		return s
+	}
 
-	// Real-time online
-	var realtimeOnline int
-	fiveMinutesAgo := time.Now().Add(-5 * time.Minute).Format("2006-01-02 15:04:05")
 	models.DB.QueryRow(`
-		SELECT COUNT(DISTINCT visitor_id) FROM visitor_logs 
-		WHERE last_visit_time >= ?`, fiveMinutesAgo).Scan(&realtimeOnline)
+		SELECT COALESCE(SUM(page_view_count), 0),
+		       COUNT(DISTINCT `+visitorColumn()+`),
+		       COALESCE(SUM(CASE WHEN is_new_visitor = 1 THEN 1 ELSE 0 END), 0),
+		       COALESCE(SUM(CASE WHEN is_new_visitor = 0 THEN 1 ELSE 0 END), 0),
+		       COUNT(DISTINCT visitor_id),
+		       COALESCE(ROUND(AVG(COALESCE(duration, 0))), 0)
+		FROM visitor_logs 
+		WHERE visit_time >= ? AND visit_time < ?`, r.From, r.To).
+		Scan(&s.PV, &s.UV, &s.NewVisitors, &s.ReturningVisitors, &s.Sessions, &s.AvgDuration)
+	return s
+}
+
+// compare returns the delta of every summary metric against a previous period
+func (s visitorSummary) compare(prev visitorSummary) map[string]metricDelta {
+	return map[string]metricDelta{
+		"pv":                 newMetricDelta(s.PV, prev.PV),
+		"uv":                 newMetricDelta(s.UV, prev.UV),
+		"new_visitors":       newMetricDelta(s.NewVisitors, prev.NewVisitors),
+		"returning_visitors": newMetricDelta(s.ReturningVisitors, prev.ReturningVisitors),
+		"sessions":           newMetricDelta(s.Sessions, prev.Sessions),
+		"avg_duration":       newMetricDelta(s.AvgDuration, prev.AvgDuration),
+	}
+}
+
+// breakdownKeys lists the fields identifying a row of each breakdown, used
+// to match rows between the current and the comparison period
+var breakdownKeys = map[string][]string{
+	"device_stats":   {"device"},
+	"browser_stats":  {"browser"},
+	"top_pages":      {"page"},
+	"hourly_stats":   {"hour"},
+	"country_stats":  {"country"},
+	"region_stats":   {"country_code", "region"},
+	"asn_stats":      {"asn"},
+	"hosting_stats":  {"network"},
+	"referrer_stats": {"source"},
+}
+
+// getVisitorBreakdowns runs every distribution query over a date range,
+// reading the rollup tables when the range is in the reporting timezone
+func getVisitorBreakdowns(r statsRange) map[string][]map[string]interface{} {
+	if useRollups(r) {
+		return getRollupBreakdowns(r)
+	}
 
 	// Device distribution
 	deviceStats := []map[string]interface{}{}
@@ -57,9 +99,9 @@ func GetVisitorStats(c *gin.Context) {
 		SELECT COALESCE(device_type, 'Unknown') as device, 
 		       SUM(page_view_count) as count 
 		FROM visitor_logs 
-		WHERE DATE(visit_time) = ? 
+		WHERE visit_time >= ? AND visit_time < ? 
 		GROUP BY device_type 
-		ORDER BY count DESC`, today)
+		ORDER BY count DESC`, r.From, r.To)
 	if err == nil && deviceRows != nil {
 		defer deviceRows.Close()
 		for deviceRows.Next() {
@@ -79,10 +121,10 @@ func GetVisitorStats(c *gin.Context) {
 		SELECT COALESCE(browser, 'Unknown') as browser, 
 		       SUM(page_view_count) as count 
 		FROM visitor_logs 
-		WHERE DATE(visit_time) = ? 
+		WHERE visit_time >= ? AND visit_time < ? 
 		GROUP BY browser 
 		ORDER BY count DESC 
-		LIMIT 10`, today)
+		LIMIT 10`, r.From, r.To)
 	if err == nil && browserRows != nil {
 		defer browserRows.Close()
 		for browserRows.Next() {
@@ -101,12 +143,12 @@ func GetVisitorStats(c *gin.Context) {
 	pageRows, err := models.DB.Query(`
 		SELECT page_path, 
 		       SUM(page_view_count) as pv, 
-		       COUNT(DISTINCT ip_address) as uv 
+		       COUNT(DISTINCT `+visitorColumn()+`) as uv 
 		FROM visitor_logs 
-		WHERE DATE(visit_time) = ? 
+		WHERE visit_time >= ? AND visit_time < ? 
 		GROUP BY page_path 
 		ORDER BY pv DESC 
-		LIMIT 10`, today)
+		LIMIT 10`, r.From, r.To)
 	if err == nil && pageRows != nil {
 		defer pageRows.Close()
 		for pageRows.Next() {
@@ -121,41 +163,17 @@ func GetVisitorStats(c *gin.Context) {
 		}
 	}
 
-	// Hourly distribution - UV is unique IPs per hour
-	hourlyStats := []map[string]interface{}{}
-	hourlyRows, err := models.DB.Query(`
-		SELECT HOUR(visit_time) as hour, 
-		       COUNT(*) as pv,
-		       COUNT(DISTINCT ip_address) as uv
-		FROM visitor_logs 
-		WHERE DATE(visit_time) = ? 
-		GROUP BY HOUR(visit_time)
-		ORDER BY hour`, today)
-	if err == nil && hourlyRows != nil {
-		defer hourlyRows.Close()
-		for hourlyRows.Next() {
-			var hour int
-			var pv, uv int
-			hourlyRows.Scan(&hour, &pv, &uv)
-			hourlyStats = append(hourlyStats, map[string]interface{}{
-				"hour": fmt.Sprintf("%02d", hour),
-				"pv":   pv,
-				"uv":   uv,
-			})
-		}
-	}
-
 	// Country distribution - ensure we get data even if country_name is NULL
 	countryStats := []map[string]interface{}{}
 	countryRows, err := models.DB.Query(`
 		SELECT COALESCE(NULLIF(country_name, ''), 'Unknown') as country, 
-		       COUNT(DISTINCT ip_address) as uv,
+		       COUNT(DISTINCT `+visitorColumn()+`) as uv,
 		       SUM(page_view_count) as pv
 		FROM visitor_logs 
-		WHERE DATE(visit_time) = ? 
+		WHERE visit_time >= ? AND visit_time < ? 
 		GROUP BY COALESCE(NULLIF(country_name, ''), 'Unknown')
 		ORDER BY uv DESC 
-		LIMIT 10`, today)
+		LIMIT 10`, r.From, r.To)
 	if err == nil && countryRows != nil {
 		defer countryRows.Close()
 		for countryRows.Next() {
@@ -173,20 +191,13 @@ func GetVisitorStats(c *gin.Context) {
 	// Referrer sources
 	referrerStats := []map[string]interface{}{}
 	referrerRows, err := models.DB.Query(`
-		SELECT CASE 
-		         WHEN referrer IS NULL OR referrer = '' THEN 'Direct'
-		         WHEN referrer LIKE '%google%' THEN 'Google'
-		         WHEN referrer LIKE '%facebook%' THEN 'Facebook'
-		         WHEN referrer LIKE '%twitter%' OR referrer LIKE '%x.com%' THEN 'Twitter/X'
-		         WHEN referrer LIKE '%bing%' THEN 'Bing'
-		         ELSE 'Other'
-		       END as source,
-		       COUNT(DISTINCT ip_address) as uv,
+		SELECT `+referrerSourceExpr+` as source,
+		       COUNT(DISTINCT `+visitorColumn()+`) as uv,
 		       SUM(page_view_count) as pv
 		FROM visitor_logs 
-		WHERE DATE(visit_time) = ? 
+		WHERE visit_time >= ? AND visit_time < ? 
 		GROUP BY source 
-		ORDER BY uv DESC`, today)
+		ORDER BY uv DESC`, r.From, r.To)
 	if err == nil && referrerRows != nil {
 		defer referrerRows.Close()
 		for referrerRows.Next() {
@@ -201,66 +212,264 @@ func GetVisitorStats(c *gin.Context) {
 		}
 	}
 
-	// 7-day trend data
-	trendStats := []map[string]interface{}{}
-	trendRows, err := models.DB.Query(`
-		SELECT DATE(visit_time) as date,
-		       SUM(page_view_count) as pv,
-		       COUNT(DISTINCT ip_address) as uv
+	breakdowns := getNetworkBreakdowns(r)
+	breakdowns["device_stats"] = deviceStats
+	breakdowns["browser_stats"] = browserStats
+	breakdowns["top_pages"] = topPages
+	breakdowns["hourly_stats"] = hourlyBreakdown(r)
+	breakdowns["country_stats"] = countryStats
+	breakdowns["referrer_stats"] = referrerStats
+	breakdowns["trend_stats"] = getTrend(r)
+	return breakdowns
+}
+
+// hourlyBreakdown returns PV and UV per hour of day over the raw logs of a
+// range; UV is unique visitors per hour
+func hourlyBreakdown(r statsRange) []map[string]interface{} {
+	hourlyStats := []map[string]interface{}{}
+	local, localArgs := localTimeExpr("visit_time", r.From, r.To)
+	hourlyRows, err := models.DB.Query(`
+		SELECT HOUR(`+local+`) as hour, 
+		       COUNT(*) as pv,
+		       COUNT(DISTINCT `+visitorColumn()+`) as uv
 		FROM visitor_logs 
-		WHERE DATE(visit_time) >= ? 
-		GROUP BY DATE(visit_time)
-		ORDER BY date`, last7Days)
-	if err == nil && trendRows != nil {
-		defer trendRows.Close()
-		for trendRows.Next() {
-			var date string
+		WHERE visit_time >= ? AND visit_time < ? 
+		GROUP BY hour
+		ORDER BY hour`, append(localArgs, r.From, r.To)...)
+	if err == nil && hourlyRows != nil {
+		defer hourlyRows.Close()
+		for hourlyRows.Next() {
+			var hour int
 			var pv, uv int
-			trendRows.Scan(&date, &pv, &uv)
-			trendStats = append(trendStats, map[string]interface{}{
-				"date": date,
+			hourlyRows.Scan(&hour, &pv, &uv)
+			hourlyStats = append(hourlyStats, map[string]interface{}{
+				"hour": fmt.Sprintf("%02d", hour),
 				"pv":   pv,
 				"uv":   uv,
 			})
 		}
 	}
+	return hourlyStats
+}
+
+// getRollupBreakdowns serves the page, device, browser, country, referrer,
+// hourly and trend distributions from the rollup tables. Over several days
+// only page views are summed from them; distributions with unique visitors
+// are counted from visitor_logs.
+func getRollupBreakdowns(r statsRange) map[string][]map[string]interface{} {
+	deviceStats := rollupBreakdown(r, "device", "device", "pv", 0)
+	browserStats := rollupBreakdown(r, "browser", "browser", "pv", 10)
+	for _, rows := range [][]map[string]interface{}{deviceStats, browserStats} {
+		for _, row := range rows {
+			row["count"] = row["pv"]
+			delete(row, "pv")
+			delete(row, "uv")
+		}
+	}
+
+	breakdowns := getNetworkBreakdowns(r)
+	breakdowns["device_stats"] = deviceStats
+	breakdowns["browser_stats"] = browserStats
+	breakdowns["top_pages"] = dimensionBreakdown(r, "page", "page", "pv", 10)
+	breakdowns["country_stats"] = dimensionBreakdown(r, "country", "country", "uv", 10)
+	breakdowns["referrer_stats"] = dimensionBreakdown(r, "referrer", "source", "uv", 0)
+	if r.Days() == 1 {
+		breakdowns["hourly_stats"] = rollupHourly(r)
+	} else {
+		breakdowns["hourly_stats"] = hourlyBreakdown(r)
+	}
+	breakdowns["trend_stats"] = getTrend(r)
+	return breakdowns
+}
+
+// getNetworkBreakdowns runs the region, ASN and hosting distributions, which
+// are not rolled up, over the raw logs of a date range
+func getNetworkBreakdowns(r statsRange) map[string][]map[string]interface{} {
+	// Region (province) distribution, grouped per country
+	regionStats := []map[string]interface{}{}
+	regionRows, err := models.DB.Query(`
+		SELECT COALESCE(country_code, ''),
+		       COALESCE(NULLIF(region, ''), 'Unknown') as region,
+		       COUNT(DISTINCT `+visitorColumn()+`) as uv,
+		       SUM(page_view_count) as pv
+		FROM visitor_logs 
+		WHERE visit_time >= ? AND visit_time < ? 
+		GROUP BY country_code, COALESCE(NULLIF(region, ''), 'Unknown')
+		ORDER BY uv DESC 
+		LIMIT 20`, r.From, r.To)
+	if err == nil && regionRows != nil {
+		defer regionRows.Close()
+		for regionRows.Next() {
+			var countryCode, region string
+			var uv, pv int
+			regionRows.Scan(&countryCode, &region, &uv, &pv)
+			regionStats = append(regionStats, map[string]interface{}{
+				"country_code": countryCode,
+				"region":       region,
+				"uv":           uv,
+				"pv":           pv,
+			})
+		}
+	}
+
+	// Network (ASN / carrier) distribution
+	asnStats := []map[string]interface{}{}
+	asnRows, err := models.DB.Query(`
+		SELECT COALESCE(asn, 0),
+		       COALESCE(NULLIF(MAX(org), ''), 'Unknown') as org,
+		       MAX(COALESCE(is_hosting, 0)) as is_hosting,
+		       COUNT(DISTINCT `+visitorColumn()+`) as uv,
+		       SUM(page_view_count) as pv
+		FROM visitor_logs 
+		WHERE visit_time >= ? AND visit_time < ? 
+		GROUP BY COALESCE(asn, 0)
+		ORDER BY uv DESC 
+		LIMIT 20`, r.From, r.To)
+	if err == nil && asnRows != nil {
+		defer asnRows.Close()
+		for asnRows.Next() {
+			var asn uint32
+			var org string
+			var isHosting bool
+			var uv, pv int
+			asnRows.Scan(&asn, &org, &isHosting, &uv, &pv)
+			asnStats = append(asnStats, map[string]interface{}{
+				"asn":        asn,
+				"org":        org,
+				"is_hosting": isHosting,
+				"uv":         uv,
+				"pv":         pv,
+			})
+		}
+	}
+
+	// Hosting / datacenter vs residential traffic
+	hostingStats := []map[string]interface{}{}
+	hostingRows, err := models.DB.Query(`
+		SELECT CASE WHEN is_hosting = 1 THEN 'Hosting' ELSE 'Residential' END as network,
+		       COUNT(DISTINCT `+visitorColumn()+`) as uv,
+		       SUM(page_view_count) as pv
+		FROM visitor_logs 
+		WHERE visit_time >= ? AND visit_time < ? 
+		GROUP BY network
+		ORDER BY uv DESC`, r.From, r.To)
+	if err == nil && hostingRows != nil {
+		defer hostingRows.Close()
+		for hostingRows.Next() {
+			var network string
+			var uv, pv int
+			hostingRows.Scan(&network, &uv, &pv)
+			hostingStats = append(hostingStats, map[string]interface{}{
+				"network": network,
+				"uv":      uv,
+				"pv":      pv,
+			})
+		}
+	}
+
+	return map[string][]map[string]interface{}{
+		"region_stats":  regionStats,
+		"asn_stats":     asnStats,
+		"hosting_stats": hostingStats,
+	}
+}
+
+// GetVisitorStats returns visitor statistics for a date range (from/to,
+// defaulting to today) with an optional comparison period (compare=previous|year|custom).
+// Days and hours are bucketed in the reporting timezone or the ?tz= override.
+func GetVisitorStats(c *gin.Context) {
+	loc, err := reportLocation(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	now := time.Now().In(loc)
+
+	current, err := parseDateRange(c.Query("from"), c.Query("to"), now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	previous, err := parseComparisonRange(c, current, now)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	// Fixed periods kept for the dashboard cards
+	today := getVisitorSummary(dayRange(now, 1))
+	yesterday := getVisitorSummary(dayRange(now.AddDate(0, 0, -1), 1))
+	last7 := getVisitorSummary(dayRange(now, 8))
+	last30 := getVisitorSummary(dayRange(now, 31))
+
+	// Real-time online
+	var realtimeOnline int
+	fiveMinutesAgo := now.Add(-5 * time.Minute)
+	models.DB.QueryRow(`
+		SELECT COUNT(DISTINCT visitor_id) FROM visitor_logs 
+		WHERE last_visit_time >= ?`, fiveMinutesAgo).Scan(&realtimeOnline)
+
+	summary := getVisitorSummary(current)
+	breakdowns := getVisitorBreakdowns(current)
+
+	// Without an explicit range the trend keeps covering the last 7 days
+	if c.Query("from") == "" && c.Query("to") == "" {
+		breakdowns["trend_stats"] = getTrend(dayRange(now, 8))
+	}
+
+	data := gin.H{
+		"range":   current.JSON(),
+		"summary": summary,
+		"today": gin.H{
+			"pv":           today.PV,
+			"uv":           today.UV,
+			"new_visitors": today.NewVisitors,
+		},
+		"yesterday": gin.H{
+			"pv": yesterday.PV,
+			"uv": yesterday.UV,
+		},
+		"last7days": gin.H{
+			"pv": last7.PV,
+			"uv": last7.UV,
+		},
+		"last30days": gin.H{
+			"pv": last30.PV,
+			"uv": last30.UV,
+		},
+		"realtime_online": realtimeOnline,
+	}
+	for name, rows := range breakdowns {
+		data[name] = rows
+	}
+
+	if previous != nil {
+		prevSummary := getVisitorSummary(*previous)
+		prevBreakdowns := getVisitorBreakdowns(*previous)
+		for name, keys := range breakdownKeys {
+			compareBreakdown(breakdowns[name], prevBreakdowns[name], keys, []string{"count", "pv", "uv"})
+		}
+		data["comparison"] = gin.H{
+			"range":       previous.JSON(),
+			"summary":     prevSummary,
+			"deltas":      summary.compare(prevSummary),
+			"trend_stats": prevBreakdowns["trend_stats"],
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data": gin.H{
-			"today": gin.H{
-				"pv":           todayPV,
-				"uv":           todayUV,
-				"new_visitors": todayNew,
-			},
-			"yesterday": gin.H{
-				"pv": yesterdayPV,
-				"uv": yesterdayUV,
-			},
-			"last7days": gin.H{
-				"pv": last7PV,
-				"uv": last7UV,
-			},
-			"last30days": gin.H{
-				"pv": last30PV,
-				"uv": last30UV,
-			},
-			"realtime_online": realtimeOnline,
-			"device_stats":    deviceStats,
-			"browser_stats":   browserStats,
-			"top_pages":       topPages,
-			"hourly_stats":    hourlyStats,
-			"country_stats":   countryStats,
-			"referrer_stats":  referrerStats,
-			"trend_stats":     trendStats,
-		},
+		"data":    data,
 	})
 }
 
+// GetVisitorList returns visits matching the list filters (see
+// visitorListFilter), sorted by sort/order. Pass next_cursor back as cursor
+// for keyset pagination; page/page_size offset pagination is still supported.
 func GetVisitorList(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
-	dateFilter := c.Query("date")
+	cursor := c.Query("cursor")
 
 	if page < 1 {
 		page = 1
@@ -270,28 +479,56 @@ func GetVisitorList(c *gin.Context) {
 	}
 	offset := (page - 1) * pageSize
 
-	whereClause := "1=1"
-	args := []interface{}{}
+	sortKey := c.DefaultQuery("sort", "visit_time")
+	sortColumn, ok := visitorSortColumns[sortKey]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid sort column"})
+		return
+	}
+	order := strings.ToLower(c.DefaultQuery("order", "desc"))
+	if order != "asc" && order != "desc" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "order must be asc or desc"})
+		return
+	}
 
-	if dateFilter != "" {
-		whereClause += " AND DATE(visit_time) = ?"
-		args = append(args, dateFilter)
+	whereClause, args, err := visitorListFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
 	}
 
-	// Get total count
+	// Get total count (only for the first page of a keyset walk)
 	var total int
-	countQuery := "SELECT COUNT(*) FROM visitor_logs WHERE " + whereClause
-	models.DB.QueryRow(countQuery, args...).Scan(&total)
+	if cursor == "" {
+		countQuery := "SELECT COUNT(*) FROM visitor_logs WHERE " + whereClause
+		models.DB.QueryRow(countQuery, args...).Scan(&total)
+	}
+
+	if cursor != "" {
+		value, lastID, err := decodeVisitorCursor(cursor, sortKey)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+		cmp := "<"
+		if order == "asc" {
+			cmp = ">"
+		}
+		whereClause += fmt.Sprintf(" AND (%s %s ? OR (%s = ? AND id %s ?))", sortColumn, cmp, sortColumn, cmp)
+		args = append(args, value, value, lastID)
+		offset = 0
+	}
 
 	// Get visitors
 	query := `
 		SELECT id, ip_address, COALESCE(visitor_id, ''), COALESCE(page_path, '/'), 
 		       COALESCE(device_type, ''), COALESCE(os, ''), COALESCE(browser, ''),
-		       COALESCE(country_name, ''), COALESCE(city, ''), visit_time, 
+		       COALESCE(country_code, ''), COALESCE(country_name, ''), COALESCE(city, ''),
+		       COALESCE(referrer, ''), is_new_visitor, visit_time, last_visit_time,
 		       COALESCE(duration, 0), page_view_count
 		FROM visitor_logs 
 		WHERE ` + whereClause + `
-		ORDER BY visit_time DESC 
+		ORDER BY ` + sortColumn + ` ` + order + `, id ` + order + ` 
 		LIMIT ? OFFSET ?`
 
 	args = append(args, pageSize, offset)
@@ -303,13 +540,16 @@ func GetVisitorList(c *gin.Context) {
 	defer rows.Close()
 
 	visitors := []map[string]interface{}{}
+	nextCursor := ""
 	for rows.Next() {
 		var id int64
-		var ip, visitorId, path, device, os, browser, country, city string
-		var visitTime time.Time
+		var ip, visitorId, path, device, os, browser, countryCode, country, city, referrer string
+		var isNewVisitor bool
+		var visitTime, lastVisitTime time.Time
 		var duration, pvCount int
 
-		rows.Scan(&id, &ip, &visitorId, &path, &device, &os, &browser, &country, &city, &visitTime, &duration, &pvCount)
+		rows.Scan(&id, &ip, &visitorId, &path, &device, &os, &browser, &countryCode, &country, &city,
+			&referrer, &isNewVisitor, &visitTime, &lastVisitTime, &duration, &pvCount)
 
 		visitors = append(visitors, map[string]interface{}{
 			"id":              id,
@@ -319,22 +559,44 @@ func GetVisitorList(c *gin.Context) {
 			"device":          device,
 			"os":              os,
 			"browser":         browser,
+			"country_code":    countryCode,
 			"country":         country,
 			"city":            city,
+			"referrer":        referrer,
+			"is_new_visitor":  isNewVisitor,
 			"visit_time":      visitTime,
+			"last_visit_time": lastVisitTime,
 			"duration":        duration,
 			"page_view_count": pvCount,
 		})
+
+		sortValues := map[string]interface{}{
+			"visit_time":      visitTime,
+			"last_visit_time": lastVisitTime,
+			"page_view_count": pvCount,
+			"duration":        duration,
+		}
+		nextCursor = encodeVisitorCursor(sortValues[sortKey], id)
+	}
+	if len(visitors) < pageSize {
+		nextCursor = ""
+	}
+
+	data := gin.H{
+		"visitors":    visitors,
+		"page":        page,
+		"pageSize":    pageSize,
+		"sort":        sortKey,
+		"order":       order,
+		"next_cursor": nextCursor,
+	}
+	if cursor == "" {
+		data["total"] = total
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"data": gin.H{
-			"visitors": visitors,
-			"total":    total,
-			"page":     page,
-			"pageSize": pageSize,
-		},
+		"data":    data,
 	})
 }
 
@@ -344,31 +606,15 @@ func GetVisitorTrend(c *gin.Context) {
 		days = 7
 	}
 
-	startDate := time.Now().AddDate(0, 0, -days).Format("2006-01-02")
-
-	rows, err := models.DB.Query(`
-		SELECT stats_date, page_views, unique_visitors, unique_ips 
-		FROM daily_stats 
-		WHERE stats_date >= ? 
-		ORDER BY stats_date`, startDate)
-
+	loc, err := reportLocation(c)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
 		return
 	}
-	defer rows.Close()
-
-	trends := []map[string]interface{}{}
-	for rows.Next() {
-		var date string
-		var pv, uv, ips int
-		rows.Scan(&date, &pv, &uv, &ips)
-		trends = append(trends, map[string]interface{}{
-			"date": date,
-			"pv":   pv,
-			"uv":   uv,
-			"ips":  ips,
-		})
+	// UV and unique IPs are the same measure (distinct visitors per day)
+	trends := getTrend(dayRange(time.Now().In(loc), days+1))
+	for _, row := range trends {
+		row["ips"] = row["uv"]
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -9,6 +9,7 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	_ "time/tzdata" // reporting timezone must resolve even without system tzdata
 
 	"github.com/gin-gonic/gin"
 )
@@ -20,6 +21,40 @@ func main() {
 	// Initialize database
 	models.InitDB()
 
+	// Initialize geolocation providers and the enrichment worker
+	handlers.InitGeoProviders()
+	handlers.StartGeoWorker()
+
+	// Command line tools: admin-go <command> [args]
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
+	// Keep rollup tables and daily_stats up to date
+	handlers.StartStatsAggregator()
+
+	// Sample concurrent viewers of live match rooms
+	handlers.StartLiveSampler()
+
+	// Watch for traffic drops, spikes and tracking errors
+	handlers.StartAlertChecker()
+
+	// Email the daily/weekly analytics reports
+	handlers.StartReportScheduler()
+
+	// Publish and unpublish scheduled articles
+	handlers.StartArticleScheduler()
+
+	// Keep the dashboard snapshot fresh in memory
+	handlers.StartDashboardSnapshots()
+
+	// Archive and purge raw visitor data past RETENTION_DAYS
+	handlers.StartRetentionJob()
+
+	// Build the article search index and keep it rebuilt
+	handlers.StartSearchIndexer()
+
 	// Create Gin router
 	r := gin.Default()
 
@@ -94,10 +129,52 @@ func main() {
 		api.GET("/visitors/stats", handlers.GetVisitorStats)
 		api.GET("/visitors/list", handlers.GetVisitorList)
 		api.GET("/visitors/trend", handlers.GetVisitorTrend)
+		api.GET("/visitors/retention", handlers.GetRetentionCohorts)
+		api.GET("/visitors/:visitor_id", handlers.GetVisitorJourney)
+
+		// Traffic alerts
+		api.GET("/alerts", handlers.GetAlerts)
+		api.POST("/alerts/check", handlers.RunAlertCheck)
+		api.POST("/alerts/test", handlers.TestAlertChannels)
+		api.POST("/alerts/:id/acknowledge", handlers.AcknowledgeAlert)
+
+		// Scheduled email reports
+		api.GET("/reports/subscriptions", handlers.GetReportSubscriptions)
+		api.POST("/reports/subscriptions", handlers.CreateReportSubscription)
+		api.PUT("/reports/subscriptions/:id", handlers.UpdateReportSubscription)
+		api.DELETE("/reports/subscriptions/:id", handlers.DeleteReportSubscription)
+		api.GET("/reports/preview", handlers.PreviewEmailReport)
+		api.POST("/reports/send-now", handlers.SendEmailReportNow)
+
+		// Live match rooms
+		api.GET("/live/matches", handlers.GetLiveMatches)
+		api.GET("/live/matches/:match_id", handlers.GetLiveMatchStats)
+
+		// Funnels
+		api.GET("/funnels", handlers.GetFunnels)
+		api.GET("/funnels/:id", handlers.GetFunnel)
+		api.POST("/funnels", handlers.CreateFunnel)
+		api.PUT("/funnels/:id", handlers.UpdateFunnel)
+		api.DELETE("/funnels/:id", handlers.DeleteFunnel)
+		api.GET("/funnels/:id/report", handlers.GetFunnelReport)
+
+		// Analytics export
+		api.GET("/export/visitors", handlers.ExportVisitors)
+		api.GET("/export/stats", handlers.ExportStats)
+
+		// Statistics maintenance
+		api.POST("/stats/recompute", handlers.RecomputeStatsHandler)
 
 		// Article Management
 		api.GET("/articles", handlers.GetArticles)
+		api.GET("/articles/top", handlers.GetTopArticles)
+		api.GET("/articles/scheduled", handlers.GetScheduledArticles)
 		api.GET("/articles/:id", handlers.GetArticle)
+		api.GET("/articles/:id/stats", handlers.GetArticleStats)
+		api.GET("/articles/:id/revisions", handlers.GetArticleRevisions)
+		api.GET("/articles/:id/revisions/diff", handlers.DiffArticleRevisions)
+		api.GET("/articles/:id/revisions/:revision_id", handlers.GetArticleRevision)
+		api.POST("/articles/:id/revisions/:revision_id/restore", handlers.RestoreArticleRevision)
 		api.POST("/articles", handlers.CreateArticle)
 		api.PUT("/articles/:id", handlers.UpdateArticle)
 		api.DELETE("/articles/:id", handlers.DeleteArticle)
@@ -112,6 +189,19 @@ func main() {
 		api.PUT("/categories/:id", handlers.UpdateCategory)
 		api.DELETE("/categories/:id", handlers.DeleteCategory)
 
+		// Tags
+		api.GET("/tags", handlers.GetTags)
+		api.POST("/tags", handlers.CreateTag)
+		api.PUT("/tags/:id", handlers.UpdateTag)
+		api.DELETE("/tags/:id", handlers.DeleteTag)
+
+		// Authors
+		api.GET("/authors", handlers.GetAuthors)
+		api.GET("/authors/me", handlers.GetMyAuthor)
+		api.POST("/authors", handlers.CreateAuthor)
+		api.PUT("/authors/:id", handlers.UpdateAuthor)
+		api.DELETE("/authors/:id", handlers.DeleteAuthor)
+
 		// System Management - Blacklist/Whitelist
 		api.GET("/blacklist", handlers.GetBlacklist)
 		api.POST("/blacklist", handlers.AddToBlacklist)
@@ -138,14 +228,28 @@ func main() {
 		api.GET("/system/client-ip", handlers.GetClientIP)
 		api.POST("/system/clear-visitors", handlers.ClearAllVisitors)
 		api.POST("/system/clear-stats", handlers.ClearDailyStats)
+		api.GET("/system/retention", handlers.GetRetentionStatus)
+		api.POST("/system/retention/run", handlers.RunRetentionNow)
+
+		// Privacy - data subject requests
+		api.GET("/privacy/export", handlers.ExportVisitorData)
+		api.POST("/privacy/erase", handlers.EraseVisitorData)
+
+		// Geolocation maintenance
+		api.POST("/geo/backfill", handlers.StartGeoBackfill)
+		api.GET("/geo/backfill/status", handlers.GetGeoBackfillStatus)
 	}
 
 	// Public routes (not protected by IP filter)
 	r.POST("/api/track", handlers.Track)
 	r.GET("/api/track/online", handlers.GetOnlineCount)
 	r.GET("/api/public/categories", handlers.GetPublicCategories)
+	r.GET("/api/public/tags", handlers.GetPublicTags)
+	r.GET("/api/public/tag/:slug", handlers.GetPublicTagBySlug)
+	r.GET("/api/public/authors/:slug", handlers.GetPublicAuthorBySlug)
 	r.GET("/api/public/articles", handlers.GetPublicArticles)
 	r.GET("/api/public/article/:slug", handlers.GetPublicArticleBySlug)
+	r.GET("/api/public/search", handlers.SearchPublicArticles)
 	r.GET("/sitemap.xml", handlers.GetSitemap)
 
 	port := config.GetPort()
@@ -21,8 +21,10 @@ func InitDB() {
 		log.Fatal("Database configuration not loaded. Please ensure config.Load() is called before InitDB()")
 	}
 
-	// MySQL DSN format: user:password@tcp(host:port)/dbname?charset=utf8mb4&parseTime=True&loc=Local
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=%s&parseTime=True&loc=Local",
+	// MySQL DSN format: user:password@tcp(host:port)/dbname?charset=utf8mb4&parseTime=True&loc=UTC
+	// The session runs in UTC so TIMESTAMP values never depend on the server timezone;
+	// analytics convert to the reporting timezone explicitly.
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=%s&parseTime=True&loc=UTC&time_zone=%%27%%2B00%%3A00%%27",
 		cfg.DBUser, cfg.DBPassword, cfg.DBHost, cfg.DBPort, cfg.DBName, cfg.DBCharset)
 
 	log.Printf("Attempting to connect to MySQL database: %s@%s:%s/%s", cfg.DBUser, cfg.DBHost, cfg.DBPort, cfg.DBName)
@@ -61,6 +63,7 @@ func createTables() {
 		`CREATE TABLE IF NOT EXISTS visitor_logs (
 			id BIGINT AUTO_INCREMENT PRIMARY KEY,
 			ip_address VARCHAR(45) NOT NULL,
+			ip_hex CHAR(32),
 			visitor_id VARCHAR(255),
 			session_id VARCHAR(255),
 			page_path TEXT NOT NULL,
@@ -75,14 +78,19 @@ func createTables() {
 			country_code VARCHAR(10),
 			country_name VARCHAR(100),
 			city VARCHAR(100),
+			region VARCHAR(100),
+			asn INT UNSIGNED DEFAULT 0,
+			org VARCHAR(255),
+			is_hosting TINYINT(1) DEFAULT 0,
 			visit_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-			duration INT DEFAULT 0,
+			duration INT NOT NULL DEFAULT 0,
 			is_new_visitor TINYINT(1) DEFAULT 1,
 			page_view_count INT DEFAULT 1,
 			visited_pages TEXT,
 			last_visit_time TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			INDEX idx_visit_time (visit_time),
 			INDEX idx_ip_address (ip_address),
+			INDEX idx_ip_hex (ip_hex),
 			INDEX idx_visitor_id (visitor_id)
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
 
@@ -100,6 +108,139 @@ func createTables() {
 			INDEX idx_stats_date (stats_date)
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
 
+		`CREATE TABLE IF NOT EXISTS page_views (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			log_id BIGINT NOT NULL,
+			visitor_id VARCHAR(255),
+			session_id VARCHAR(255),
+			page_path TEXT NOT NULL,
+			page_type VARCHAR(100),
+			reference_id VARCHAR(255),
+			referrer TEXT,
+			viewed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			duration INT DEFAULT 0,
+			article_id BIGINT,
+			scroll_depth INT DEFAULT 0,
+			INDEX idx_log_id (log_id),
+			INDEX idx_visitor_viewed (visitor_id, viewed_at),
+			INDEX idx_viewed_at (viewed_at),
+			INDEX idx_article_viewed (article_id, viewed_at),
+			INDEX idx_page_path (page_path(191))
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+		`CREATE TABLE IF NOT EXISTS visitor_events (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			visitor_id VARCHAR(255),
+			session_id VARCHAR(255),
+			event_name VARCHAR(100) NOT NULL,
+			page_path TEXT,
+			page_type VARCHAR(100),
+			reference_id VARCHAR(255),
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_visitor_created (visitor_id, created_at),
+			INDEX idx_created_at (created_at)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+		`CREATE TABLE IF NOT EXISTS visitor_first_seen (
+			visitor_id VARCHAR(255) PRIMARY KEY,
+			first_seen_at TIMESTAMP NOT NULL,
+			INDEX idx_first_seen_at (first_seen_at)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+		`CREATE TABLE IF NOT EXISTS live_viewers (
+			match_id VARCHAR(255) NOT NULL,
+			visitor_id VARCHAR(255) NOT NULL,
+			first_seen_at TIMESTAMP NOT NULL,
+			last_seen_at TIMESTAMP NOT NULL,
+			watch_seconds INT DEFAULT 0,
+			is_watching TINYINT(1) DEFAULT 1,
+			PRIMARY KEY (match_id, visitor_id),
+			INDEX idx_last_seen_at (last_seen_at),
+			INDEX idx_visitor_id (visitor_id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+		`CREATE TABLE IF NOT EXISTS live_samples (
+			match_id VARCHAR(255) NOT NULL,
+			sample_time TIMESTAMP NOT NULL,
+			viewers INT DEFAULT 0,
+			PRIMARY KEY (match_id, sample_time),
+			INDEX idx_sample_time (sample_time)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+		`CREATE TABLE IF NOT EXISTS alerts (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			alert_type VARCHAR(50) NOT NULL,
+			metric VARCHAR(50) NOT NULL,
+			severity VARCHAR(20) NOT NULL,
+			message TEXT NOT NULL,
+			current_value DOUBLE DEFAULT 0,
+			baseline_value DOUBLE DEFAULT 0,
+			change_percent DOUBLE DEFAULT 0,
+			window_start TIMESTAMP NULL,
+			window_end TIMESTAMP NULL,
+			notifications TEXT,
+			acknowledged TINYINT(1) DEFAULT 0,
+			acknowledged_at TIMESTAMP NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_type_created (alert_type, metric, created_at),
+			INDEX idx_acknowledged (acknowledged)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+		`CREATE TABLE IF NOT EXISTS report_subscriptions (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			email VARCHAR(255) NOT NULL,
+			frequency VARCHAR(10) NOT NULL DEFAULT 'daily',
+			send_hour INT DEFAULT 7,
+			weekday INT DEFAULT 1,
+			is_enabled TINYINT(1) DEFAULT 1,
+			last_period VARCHAR(20),
+			last_sent_at TIMESTAMP NULL,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			UNIQUE KEY uk_email_frequency (email, frequency)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+		`CREATE TABLE IF NOT EXISTS retention_runs (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			cutoff TIMESTAMP NULL,
+			result TEXT,
+			error TEXT,
+			started_at TIMESTAMP NULL,
+			finished_at TIMESTAMP NULL
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+		`CREATE TABLE IF NOT EXISTS funnels (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			description TEXT,
+			steps TEXT NOT NULL,
+			conversion_window_minutes INT DEFAULT 1440,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+		`CREATE TABLE IF NOT EXISTS stats_hourly (
+			bucket_hour DATETIME NOT NULL,
+			dimension VARCHAR(20) NOT NULL,
+			dim_value VARCHAR(255) NOT NULL,
+			page_views INT DEFAULT 0,
+			unique_visitors INT DEFAULT 0,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			PRIMARY KEY (bucket_hour, dimension, dim_value),
+			INDEX idx_dimension_hour (dimension, bucket_hour)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+		`CREATE TABLE IF NOT EXISTS stats_daily (
+			stats_date DATE NOT NULL,
+			dimension VARCHAR(20) NOT NULL,
+			dim_value VARCHAR(255) NOT NULL,
+			page_views INT DEFAULT 0,
+			unique_visitors INT DEFAULT 0,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			PRIMARY KEY (stats_date, dimension, dim_value),
+			INDEX idx_dimension_date (dimension, stats_date)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
 		`CREATE TABLE IF NOT EXISTS realtime_stats (
 			id BIGINT AUTO_INCREMENT PRIMARY KEY,
 			room_key VARCHAR(255) UNIQUE NOT NULL,
@@ -119,13 +260,46 @@ func createTables() {
 			is_published TINYINT(1) DEFAULT 0,
 			is_recommended TINYINT(1) DEFAULT 0,
 			view_count INT DEFAULT 0,
+			publish_at TIMESTAMP NULL,
+			unpublish_at TIMESTAMP NULL,
+			published_at TIMESTAMP NULL,
+			author_id BIGINT,
+			last_editor_id BIGINT,
+			meta_title VARCHAR(255),
+			meta_description TEXT,
+			canonical_url TEXT,
+			og_image TEXT,
+			noindex TINYINT(1) DEFAULT 0,
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
 			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 			INDEX idx_published (is_published, created_at),
+			INDEX idx_publish_at (publish_at),
+			INDEX idx_unpublish_at (unpublish_at),
 			INDEX idx_category (category_id),
+			INDEX idx_author (author_id, created_at),
 			INDEX idx_slug (slug)
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
 
+		`CREATE TABLE IF NOT EXISTS article_revisions (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			article_id BIGINT NOT NULL,
+			action VARCHAR(20) NOT NULL,
+			restored_from BIGINT,
+			title TEXT NOT NULL,
+			slug VARCHAR(255),
+			content LONGTEXT,
+			content_json LONGTEXT,
+			summary TEXT,
+			cover_image TEXT,
+			category_id BIGINT,
+			is_published TINYINT(1) DEFAULT 0,
+			is_recommended TINYINT(1) DEFAULT 0,
+			admin_id BIGINT,
+			admin_name VARCHAR(100),
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			INDEX idx_article_revision (article_id, id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
 		`CREATE TABLE IF NOT EXISTS uploaded_images (
 			id BIGINT AUTO_INCREMENT PRIMARY KEY,
 			file_name VARCHAR(255) NOT NULL,
@@ -149,6 +323,35 @@ func createTables() {
 			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
 
+		`CREATE TABLE IF NOT EXISTS authors (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			admin_id BIGINT UNIQUE,
+			display_name VARCHAR(255) NOT NULL,
+			slug VARCHAR(255) UNIQUE NOT NULL,
+			avatar TEXT,
+			bio TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+		`CREATE TABLE IF NOT EXISTS tags (
+			id BIGINT AUTO_INCREMENT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			slug VARCHAR(255) UNIQUE NOT NULL,
+			tag_type VARCHAR(20) NOT NULL DEFAULT 'topic',
+			description TEXT,
+			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			INDEX idx_tag_type (tag_type, name)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
+		`CREATE TABLE IF NOT EXISTS article_tags (
+			article_id BIGINT NOT NULL,
+			tag_id BIGINT NOT NULL,
+			PRIMARY KEY (article_id, tag_id),
+			INDEX idx_tag_article (tag_id, article_id)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
 		`CREATE TABLE IF NOT EXISTS ip_blacklist (
 			id BIGINT AUTO_INCREMENT PRIMARY KEY,
 			ip_address VARCHAR(45) UNIQUE NOT NULL,
@@ -157,6 +360,21 @@ func createTables() {
 			expires_at TIMESTAMP NULL
 		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
 
+		`CREATE TABLE IF NOT EXISTS geo_cache (
+			ip_address VARCHAR(45) PRIMARY KEY,
+			country_code VARCHAR(10),
+			country_name VARCHAR(100),
+			city VARCHAR(100),
+			region VARCHAR(100),
+			asn INT UNSIGNED DEFAULT 0,
+			org VARCHAR(255),
+			is_hosting TINYINT(1) DEFAULT 0,
+			provider VARCHAR(50),
+			updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			expires_at TIMESTAMP NOT NULL,
+			INDEX idx_expires_at (expires_at)
+		) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`,
+
 		`CREATE TABLE IF NOT EXISTS ip_whitelist (
 			id BIGINT AUTO_INCREMENT PRIMARY KEY,
 			ip_address VARCHAR(45) UNIQUE NOT NULL,
@@ -213,6 +431,67 @@ func runMigrations() {
 		}
 	}
 
+	// Region / ASN / ISP enrichment columns
+	addColumnIfMissing("visitor_logs", "region", "VARCHAR(100)")
+	addColumnIfMissing("visitor_logs", "asn", "INT UNSIGNED DEFAULT 0")
+	addColumnIfMissing("visitor_logs", "org", "VARCHAR(255)")
+	addColumnIfMissing("visitor_logs", "is_hosting", "TINYINT(1) DEFAULT 0")
+	addColumnIfMissing("geo_cache", "region", "VARCHAR(100)")
+	addColumnIfMissing("geo_cache", "asn", "INT UNSIGNED DEFAULT 0")
+	addColumnIfMissing("geo_cache", "org", "VARCHAR(255)")
+	addColumnIfMissing("geo_cache", "is_hosting", "TINYINT(1) DEFAULT 0")
+
+	// Sortable visitor list columns (keyset pagination relies on them)
+	addIndexIfMissing("visitor_logs", "idx_last_visit_time", "last_visit_time")
+	addIndexIfMissing("visitor_logs", "idx_page_view_count", "page_view_count")
+	addIndexIfMissing("visitor_logs", "idx_duration", "duration")
+	requireNotNull("visitor_logs", "duration", "INT NOT NULL DEFAULT 0", 0)
+	addIndexIfMissing("visitor_logs", "idx_country_code", "country_code")
+
+	// Indexed visitor list filters: IP ranges (CIDR) and page path prefixes
+	addColumnIfMissing("visitor_logs", "ip_hex", "CHAR(32)")
+	addIndexIfMissing("visitor_logs", "idx_ip_hex", "ip_hex")
+	addIndexIfMissing("page_views", "idx_page_path", "page_path(191)")
+
+	// Per-article analytics
+	addColumnIfMissing("page_views", "article_id", "BIGINT")
+	addColumnIfMissing("page_views", "scroll_depth", "INT DEFAULT 0")
+	addIndexIfMissing("page_views", "idx_article_viewed", "article_id, viewed_at")
+
+	// Scheduled publishing
+	addColumnIfMissing("articles", "publish_at", "TIMESTAMP NULL")
+	addColumnIfMissing("articles", "unpublish_at", "TIMESTAMP NULL")
+	addIndexIfMissing("articles", "idx_publish_at", "publish_at")
+	addIndexIfMissing("articles", "idx_unpublish_at", "unpublish_at")
+
+	// First publication time; articles published before it existed use
+	// their creation time
+	addColumnIfMissing("articles", "published_at", "TIMESTAMP NULL")
+	if _, err := DB.Exec("UPDATE articles SET published_at = created_at WHERE published_at IS NULL AND is_published = 1"); err != nil {
+		log.Printf("Migration warning (articles.published_at): %v", err)
+	}
+
+	// Bylines
+	addColumnIfMissing("articles", "author_id", "BIGINT")
+	addColumnIfMissing("articles", "last_editor_id", "BIGINT")
+	addIndexIfMissing("articles", "idx_author", "author_id, created_at")
+
+	// SEO metadata
+	addColumnIfMissing("articles", "meta_title", "VARCHAR(255)")
+	addColumnIfMissing("articles", "meta_description", "TEXT")
+	addColumnIfMissing("articles", "canonical_url", "TEXT")
+	addColumnIfMissing("articles", "og_image", "TEXT")
+	addColumnIfMissing("articles", "noindex", "TINYINT(1) DEFAULT 0")
+
+	// Revisions record the admin user who saved them (author_id means an
+	// authors.id everywhere else), and articles without a slug
+	renameColumnIfExists("article_revisions", "author_id", "admin_id", "BIGINT")
+	renameColumnIfExists("article_revisions", "author_name", "admin_name", "VARCHAR(100)")
+	allowNullIfRequired("article_revisions", "slug", "VARCHAR(255)")
+
+	backfillVisitorFirstSeen()
+	backfillVisitorIPHex()
+
 	// Generate slugs for existing articles that don't have them
 	rows, err := DB.Query("SELECT id, title FROM articles WHERE slug IS NULL OR slug = ''")
 	if err != nil {
@@ -253,6 +532,160 @@ func runMigrations() {
 	}
 }
 
+// backfillVisitorFirstSeen fills visitor_first_seen from existing visits and
+// page views the first time the table is created
+func backfillVisitorFirstSeen() {
+	var count int
+	if err := DB.QueryRow("SELECT COUNT(*) FROM visitor_first_seen").Scan(&count); err != nil || count > 0 {
+		return
+	}
+
+	_, err := DB.Exec(`
+		INSERT IGNORE INTO visitor_first_seen (visitor_id, first_seen_at)
+		SELECT visitor_id, MIN(t) FROM (
+			SELECT visitor_id, visit_time as t FROM visitor_logs WHERE visitor_id IS NOT NULL AND visitor_id != ''
+			UNION ALL
+			SELECT visitor_id, viewed_at as t FROM page_views WHERE visitor_id IS NOT NULL AND visitor_id != ''
+		) seen
+		GROUP BY visitor_id`)
+	if err != nil {
+		log.Printf("Migration warning (visitor_first_seen): %v", err)
+	}
+}
+
+// backfillVisitorIPHex fills visitor_logs.ip_hex of rows written before the
+// column existed, in batches. Hashed IPs get an empty value.
+func backfillVisitorIPHex() {
+	const batchSize = 10000
+	for {
+		result, err := DB.Exec(`
+			UPDATE visitor_logs
+			SET ip_hex = COALESCE(CASE LENGTH(INET6_ATON(ip_address))
+			                          WHEN 4 THEN CONCAT('00000000000000000000FFFF', HEX(INET6_ATON(ip_address)))
+			                          WHEN 16 THEN HEX(INET6_ATON(ip_address))
+			                      END, '')
+			WHERE ip_hex IS NULL
+			LIMIT ?`, batchSize)
+		if err != nil {
+			log.Printf("Migration warning (visitor_logs.ip_hex): %v", err)
+			return
+		}
+		if n, _ := result.RowsAffected(); n < batchSize {
+			return
+		}
+	}
+}
+
+// addColumnIfMissing adds a column to an existing table when it does not exist yet
+func addColumnIfMissing(table, column, definition string) {
+	var exists bool
+	err := DB.QueryRow(`
+		SELECT COUNT(*) > 0 
+		FROM INFORMATION_SCHEMA.COLUMNS 
+		WHERE TABLE_SCHEMA = DATABASE() 
+		AND TABLE_NAME = ? 
+		AND COLUMN_NAME = ?
+	`, table, column).Scan(&exists)
+
+	if err == nil && !exists {
+		_, err = DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+		if err != nil {
+			log.Printf("Migration warning (%s.%s): %v", table, column, err)
+		}
+	}
+}
+
+// renameColumnIfExists renames a column of an existing table that still has
+// its old name
+func renameColumnIfExists(table, column, newName, definition string) {
+	var exists bool
+	err := DB.QueryRow(`
+		SELECT COUNT(*) > 0 
+		FROM INFORMATION_SCHEMA.COLUMNS 
+		WHERE TABLE_SCHEMA = DATABASE() 
+		AND TABLE_NAME = ? 
+		AND COLUMN_NAME = ?
+	`, table, column).Scan(&exists)
+
+	if err == nil && exists {
+		_, err = DB.Exec(fmt.Sprintf("ALTER TABLE %s CHANGE COLUMN %s %s %s", table, column, newName, definition))
+		if err != nil {
+			log.Printf("Migration warning (%s.%s): %v", table, newName, err)
+		}
+	}
+}
+
+// allowNullIfRequired drops the NOT NULL constraint of a column
+func allowNullIfRequired(table, column, definition string) {
+	var notNull bool
+	err := DB.QueryRow(`
+		SELECT COUNT(*) > 0 
+		FROM INFORMATION_SCHEMA.COLUMNS 
+		WHERE TABLE_SCHEMA = DATABASE() 
+		AND TABLE_NAME = ? 
+		AND COLUMN_NAME = ?
+		AND IS_NULLABLE = 'NO'
+	`, table, column).Scan(&notNull)
+
+	if err == nil && notNull {
+		_, err = DB.Exec(fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s NULL", table, column, definition))
+		if err != nil {
+			log.Printf("Migration warning (%s.%s): %v", table, column, err)
+		}
+	}
+}
+
+// requireNotNull sets NULL values of a nullable column to value, in batches,
+// and then makes the column NOT NULL with the given definition
+func requireNotNull(table, column, definition string, value interface{}) {
+	var nullable bool
+	err := DB.QueryRow(`
+		SELECT COUNT(*) > 0 
+		FROM INFORMATION_SCHEMA.COLUMNS 
+		WHERE TABLE_SCHEMA = DATABASE() 
+		AND TABLE_NAME = ? 
+		AND COLUMN_NAME = ?
+		AND IS_NULLABLE = 'YES'
+	`, table, column).Scan(&nullable)
+	if err != nil || !nullable {
+		return
+	}
+
+	const batchSize = 10000
+	for {
+		result, err := DB.Exec(fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s IS NULL LIMIT ?", table, column, column), value, batchSize)
+		if err != nil {
+			log.Printf("Migration warning (%s.%s): %v", table, column, err)
+			return
+		}
+		if n, _ := result.RowsAffected(); n < batchSize {
+			break
+		}
+	}
+	if _, err := DB.Exec(fmt.Sprintf("ALTER TABLE %s MODIFY COLUMN %s %s", table, column, definition)); err != nil {
+		log.Printf("Migration warning (%s.%s): %v", table, column, err)
+	}
+}
+
+// addIndexIfMissing creates an index on an existing table when it does not exist yet
+func addIndexIfMissing(table, index, columns string) {
+	var exists bool
+	err := DB.QueryRow(`
+		SELECT COUNT(*) > 0 
+		FROM INFORMATION_SCHEMA.STATISTICS 
+		WHERE TABLE_SCHEMA = DATABASE() 
+		AND TABLE_NAME = ? 
+		AND INDEX_NAME = ?
+	`, table, index).Scan(&exists)
+
+	if err == nil && !exists {
+		_, err = DB.Exec(fmt.Sprintf("ALTER TABLE %s ADD INDEX %s (%s)", table, index, columns))
+		if err != nil {
+			log.Printf("Migration warning (%s.%s): %v", table, index, err)
+		}
+	}
+}
+
 func generateSlugFromTitle(title string) string {
 	// Convert to lowercase
 	slug := strings.ToLower(title)
@@ -26,6 +26,10 @@ type VisitorLog struct {
 	CountryCode      string    `json:"country_code"`
 	CountryName      string    `json:"country_name"`
 	City             string    `json:"city"`
+	Region           string    `json:"region"`
+	ASN              uint32    `json:"asn"`
+	Org              string    `json:"org"`
+	IsHosting        bool      `json:"is_hosting"`
 	VisitTime        time.Time `json:"visit_time"`
 	Duration         int       `json:"duration"`
 	IsNewVisitor     bool      `json:"is_new_visitor"`
@@ -34,6 +38,85 @@ type VisitorLog struct {
 	LastVisitTime    time.Time `json:"last_visit_time"`
 }
 
+// PageView is a single page view of a visit, in the order it happened
+type PageView struct {
+	ID          int64     `json:"id"`
+	LogID       int64     `json:"log_id"`
+	VisitorId   string    `json:"visitor_id"`
+	SessionId   string    `json:"session_id"`
+	PagePath    string    `json:"page_path"`
+	PageType    string    `json:"page_type"`
+	ReferenceId string    `json:"reference_id"`
+	Referrer    string    `json:"referrer"`
+	ViewedAt    time.Time `json:"viewed_at"`
+	Duration    int       `json:"duration"`
+	ArticleId   *int64    `json:"article_id"`
+	ScrollDepth int       `json:"scroll_depth"`
+}
+
+// VisitorEvent is a custom event sent by the frontend (action "event")
+type VisitorEvent struct {
+	ID          int64     `json:"id"`
+	VisitorId   string    `json:"visitor_id"`
+	SessionId   string    `json:"session_id"`
+	EventName   string    `json:"event_name"`
+	PagePath    string    `json:"page_path"`
+	PageType    string    `json:"page_type"`
+	ReferenceId string    `json:"reference_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// FunnelStep matches page views by path pattern (* wildcard) or page_type
+// (optionally with reference_id), or custom events by name
+type FunnelStep struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"` // path, page_type or event
+	Value       string `json:"value"`
+	ReferenceId string `json:"reference_id,omitempty"`
+}
+
+type Funnel struct {
+	ID                      int64        `json:"id"`
+	Name                    string       `json:"name"`
+	Description             string       `json:"description"`
+	Steps                   []FunnelStep `json:"steps"`
+	ConversionWindowMinutes int          `json:"conversion_window_minutes"`
+	CreatedAt               time.Time    `json:"created_at"`
+	UpdatedAt               time.Time    `json:"updated_at"`
+}
+
+// Alert is a traffic anomaly raised by the alert checker
+type Alert struct {
+	ID             int64      `json:"id"`
+	AlertType      string     `json:"alert_type"`
+	Metric         string     `json:"metric"`
+	Severity       string     `json:"severity"`
+	Message        string     `json:"message"`
+	CurrentValue   float64    `json:"current_value"`
+	BaselineValue  float64    `json:"baseline_value"`
+	ChangePercent  float64    `json:"change_percent"`
+	WindowStart    time.Time  `json:"window_start"`
+	WindowEnd      time.Time  `json:"window_end"`
+	Notifications  string     `json:"notifications"`
+	Acknowledged   bool       `json:"acknowledged"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// ReportSubscription is a recipient of the scheduled analytics email report
+type ReportSubscription struct {
+	ID         int64      `json:"id"`
+	Email      string     `json:"email"`
+	Frequency  string     `json:"frequency"`
+	SendHour   int        `json:"send_hour"`
+	Weekday    int        `json:"weekday"`
+	IsEnabled  bool       `json:"is_enabled"`
+	LastPeriod string     `json:"last_period"`
+	LastSentAt *time.Time `json:"last_sent_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
 type DailyStats struct {
 	ID                int64     `json:"id"`
 	StatsDate         string    `json:"stats_date"`
@@ -55,7 +138,40 @@ type RealtimeStats struct {
 }
 
 type Article struct {
+	ID              int64      `json:"id"`
+	Title           string     `json:"title"`
+	Slug            string     `json:"slug"`
+	Content         string     `json:"content"`
+	ContentJson     string     `json:"content_json"`
+	Summary         string     `json:"summary"`
+	CoverImage      string     `json:"cover_image"`
+	CategoryId      *int64     `json:"category_id"`
+	CategoryName    string     `json:"category_name,omitempty"`
+	IsPublished     bool       `json:"is_published"`
+	IsRecommended   bool       `json:"is_recommended"`
+	ViewCount       int        `json:"view_count"`
+	PublishAt       *time.Time `json:"publish_at"`
+	UnpublishAt     *time.Time `json:"unpublish_at"`
+	PublishedAt     *time.Time `json:"published_at"`
+	Tags            []Tag      `json:"tags"`
+	AuthorId        *int64     `json:"author_id"`
+	AuthorName      string     `json:"author_name,omitempty"`
+	LastEditorId    *int64     `json:"last_editor_id"`
+	LastEditorName  string     `json:"last_editor_name,omitempty"`
+	MetaTitle       string     `json:"meta_title"`
+	MetaDescription string     `json:"meta_description"`
+	CanonicalUrl    string     `json:"canonical_url"`
+	OgImage         string     `json:"og_image"`
+	Noindex         bool       `json:"noindex"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+type ArticleRevision struct {
 	ID            int64     `json:"id"`
+	ArticleId     int64     `json:"article_id"`
+	Action        string    `json:"action"`
+	RestoredFrom  *int64    `json:"restored_from"`
 	Title         string    `json:"title"`
 	Slug          string    `json:"slug"`
 	Content       string    `json:"content"`
@@ -63,12 +179,11 @@ type Article struct {
 	Summary       string    `json:"summary"`
 	CoverImage    string    `json:"cover_image"`
 	CategoryId    *int64    `json:"category_id"`
-	CategoryName  string    `json:"category_name,omitempty"`
 	IsPublished   bool      `json:"is_published"`
 	IsRecommended bool      `json:"is_recommended"`
-	ViewCount     int       `json:"view_count"`
+	AdminId       *int64    `json:"admin_id"`
+	AdminName     string    `json:"admin_name"`
 	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
 }
 
 type Category struct {
@@ -82,6 +197,27 @@ type Category struct {
 	CreatedAt    time.Time `json:"created_at"`
 }
 
+type Author struct {
+	ID           int64     `json:"id"`
+	AdminId      *int64    `json:"admin_id"`
+	DisplayName  string    `json:"display_name"`
+	Slug         string    `json:"slug"`
+	Avatar       string    `json:"avatar"`
+	Bio          string    `json:"bio"`
+	ArticleCount int       `json:"article_count"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type Tag struct {
+	ID           int64      `json:"id"`
+	Name         string     `json:"name"`
+	Slug         string     `json:"slug"`
+	Type         string     `json:"type"`
+	Description  string     `json:"description,omitempty"`
+	ArticleCount int        `json:"article_count,omitempty"`
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+}
+
 type IpBlacklist struct {
 	ID        int64      `json:"id"`
 	IpAddress string     `json:"ip_address"`
@@ -113,6 +249,7 @@ type TrackingRequest struct {
 	ScreenHeight int    `json:"screen_height"`
 	Language     string `json:"language"`
 	Duration     int    `json:"duration"`
+	ScrollDepth  int    `json:"scroll_depth"`
 	Status       string `json:"status"`
 	Event        string `json:"event"`
 }
@@ -64,6 +64,7 @@ function setupEventListeners() {
   document.getElementById('new-article-btn')?.addEventListener('click', openArticleEditor);
   document.getElementById('saveArticleBtn')?.addEventListener('click', handleArticleSave);
   document.getElementById('article-category-filter')?.addEventListener('change', () => { articlePage = 1; loadArticles(); });
+  document.getElementById('article-author-filter')?.addEventListener('change', () => { articlePage = 1; loadArticles(); });
   document.getElementById('article-status-filter')?.addEventListener('change', () => { articlePage = 1; loadArticles(); });
   document.getElementById('article-search')?.addEventListener('input', debounce(() => { articlePage = 1; loadArticles(); }, 300));
   document.getElementById('select-all-articles')?.addEventListener('change', toggleSelectAllArticles);
@@ -96,7 +97,14 @@ function setupEventListeners() {
   document.getElementById('filter-visitors-btn')?.addEventListener('click', () => { visitorPage = 1; loadVisitorList(); });
 
   // System settings
-  document.getElementById('clearVisitorsBtn')?.addEventListener('click', handleClearVisitors);
+  document.getElementById('clearVisitorsBtn')?.addEventListener('click', () => handleClearVisitors({ all: true }));
+  document.getElementById('clearScopedBtn')?.addEventListener('click', () => handleClearVisitors({
+    from: document.getElementById('clear-from').value,
+    to: document.getElementById('clear-to').value,
+    ip_address: document.getElementById('clear-ip').value.trim(),
+    visitor_id: document.getElementById('clear-visitor-id').value.trim(),
+    path: document.getElementById('clear-path').value.trim()
+  }));
   document.getElementById('clearStatsBtn')?.addEventListener('click', handleClearStats);
   document.getElementById('refreshIpBtn')?.addEventListener('click', loadCurrentIP);
   document.getElementById('addCurrentIpBtn')?.addEventListener('click', addCurrentIPToWhitelist);
@@ -259,7 +267,7 @@ function navigateTo(page) {
     case 'dashboard': loadDashboard(); break;
     case 'visitors': loadVisitorStats(); break;
     case 'visitor-list': loadVisitorList(); break;
-    case 'articles': loadArticles(); loadCategoryOptions(); break;
+    case 'articles': loadArticles(); loadCategoryOptions(); loadAuthorOptions(); break;
     case 'article-editor': initEditor(); break;
     case 'images': loadImages(); loadImageStats(); loadFrontendDomain(); break;
     case 'categories': loadCategories(); break;
@@ -596,10 +604,11 @@ async function loadVisitorList() {
 async function loadArticles() {
   try {
     const category = document.getElementById('article-category-filter')?.value || '';
+    const author = document.getElementById('article-author-filter')?.value || '';
     const status = document.getElementById('article-status-filter')?.value || '';
     const keyword = document.getElementById('article-search')?.value || '';
     
-    const data = await api(`/articles?page=${articlePage}&page_size=20&category_id=${category}&status=${status}&keyword=${keyword}`);
+    const data = await api(`/articles?page=${articlePage}&page_size=20&category_id=${category}&author_id=${author}&status=${status}&keyword=${encodeURIComponent(keyword)}`);
     
     if (data.success) {
       const { articles, total, page, pageSize } = data.data;
@@ -607,10 +616,10 @@ async function loadArticles() {
       document.getElementById('articles-body').innerHTML = (articles || []).map(a => `
         <tr>
           <td><input type="checkbox" class="article-checkbox" value="${a.id}" /></td>
-          <td>${a.title}</td>
+          <td>${a.title}${a.author_name ? `<div style="font-size:11px;color:var(--muted);">by ${escapeHtml(a.author_name)}${a.last_editor_name && a.last_editor_name !== a.author_name ? ` · edited by ${escapeHtml(a.last_editor_name)}` : ''}</div>` : ''}</td>
           <td><code style="font-size:11px;color:var(--muted);">${a.slug || '-'}</code></td>
-          <td>${a.category_name || '-'}</td>
-          <td><span class="badge ${a.is_published ? 'published' : 'draft'}">${a.is_published ? 'Published' : 'Draft'}</span></td>
+          <td>${a.category_name || '-'}${(a.tags || []).length ? `<div style="font-size:11px;color:var(--muted);">${a.tags.map(t => '#' + escapeHtml(t.name)).join(' ')}</div>` : ''}</td>
+          <td><span class="badge ${a.is_published ? 'published' : 'draft'}">${a.is_published ? 'Published' : (a.publish_at ? 'Scheduled' : 'Draft')}</span>${a.publish_at ? `<div style="font-size:11px;color:var(--muted);">Publish ${formatDate(a.publish_at)}</div>` : ''}${a.unpublish_at ? `<div style="font-size:11px;color:var(--muted);">Unpublish ${formatDate(a.unpublish_at)}</div>` : ''}</td>
           <td>${a.view_count}</td>
           <td>${formatDate(a.created_at)}</td>
           <td class="actions">
@@ -650,6 +659,19 @@ async function loadCategoryOptions() {
   }
 }
 
+async function loadAuthorOptions() {
+  try {
+    const data = await api('/authors');
+    if (data.success) {
+      const options = (data.data || []).map(a => `<option value="${a.id}">${escapeHtml(a.display_name)}</option>`).join('');
+      document.getElementById('article-author').innerHTML = '<option value="">Me</option>' + options;
+      document.getElementById('article-author-filter').innerHTML = '<option value="">All Authors</option>' + options;
+    }
+  } catch (err) {
+    console.error('Authors error:', err);
+  }
+}
+
 function openArticleEditor() {
   editingArticleId = null;
   document.getElementById('article-title').value = '';
@@ -658,6 +680,15 @@ function openArticleEditor() {
   document.getElementById('article-cover').value = '';
   document.getElementById('article-published').checked = false;
   document.getElementById('article-recommended').checked = false;
+  document.getElementById('article-publish-at').value = '';
+  document.getElementById('article-unpublish-at').value = '';
+  document.getElementById('article-tags').value = '';
+  document.getElementById('article-author').value = '';
+  document.getElementById('article-meta-title').value = '';
+  document.getElementById('article-meta-description').value = '';
+  document.getElementById('article-canonical-url').value = '';
+  document.getElementById('article-og-image').value = '';
+  document.getElementById('article-noindex').checked = false;
   document.getElementById('coverPreview').style.display = 'none';
   document.getElementById('coverPlaceholder').style.display = 'block';
   loadCategoryOptions();
@@ -889,8 +920,17 @@ async function editArticle(id) {
         document.getElementById('article-slug').value = a.slug || '';
         document.getElementById('article-summary').value = a.summary || '';
         document.getElementById('article-category').value = a.category_id || '';
+        document.getElementById('article-author').value = a.author_id || '';
+        document.getElementById('article-meta-title').value = a.meta_title || '';
+        document.getElementById('article-meta-description').value = a.meta_description || '';
+        document.getElementById('article-canonical-url').value = a.canonical_url || '';
+        document.getElementById('article-og-image').value = a.og_image || '';
+        document.getElementById('article-noindex').checked = !!a.noindex;
         document.getElementById('article-published').checked = a.is_published;
         document.getElementById('article-recommended').checked = a.is_recommended;
+        document.getElementById('article-publish-at').value = toDateTimeLocal(a.publish_at);
+        document.getElementById('article-unpublish-at').value = toDateTimeLocal(a.unpublish_at);
+        document.getElementById('article-tags').value = (a.tags || []).map(t => t.name).join(', ');
         
         if (a.cover_image) {
           document.getElementById('article-cover').value = a.cover_image;
@@ -1003,12 +1043,21 @@ async function handleArticleSave() {
     title: title,
     slug: slug,
     category_id: document.getElementById('article-category').value ? parseInt(document.getElementById('article-category').value) : null,
+    author_id: document.getElementById('article-author').value ? parseInt(document.getElementById('article-author').value) : null,
     cover_image: document.getElementById('article-cover').value,
     summary: document.getElementById('article-summary').value,
     content: content,
     content_json: contentJson,
     is_published: document.getElementById('article-published').checked,
-    is_recommended: document.getElementById('article-recommended').checked
+    is_recommended: document.getElementById('article-recommended').checked,
+    publish_at: fromDateTimeLocal(document.getElementById('article-publish-at').value),
+    unpublish_at: fromDateTimeLocal(document.getElementById('article-unpublish-at').value),
+    tags: document.getElementById('article-tags').value.split(',').map(t => t.trim()).filter(Boolean),
+    meta_title: document.getElementById('article-meta-title').value,
+    meta_description: document.getElementById('article-meta-description').value,
+    canonical_url: document.getElementById('article-canonical-url').value,
+    og_image: document.getElementById('article-og-image').value,
+    noindex: document.getElementById('article-noindex').checked
   };
 
   try {
@@ -1240,14 +1289,33 @@ async function addCurrentIPToWhitelist() {
   }
 }
 
-async function handleClearVisitors() {
-  if (!confirm('Are you sure you want to delete ALL visitor records? This action cannot be undone!')) return;
-  if (!confirm('This will permanently delete all visitor logs and statistics. Continue?')) return;
-  
+async function handleClearVisitors(scope) {
   try {
-    const data = await api('/system/clear-visitors', { method: 'POST' });
+    // Dry run first: show what would be deleted and get a confirmation token
+    const preview = await api('/system/clear-visitors', {
+      method: 'POST',
+      body: JSON.stringify({ ...scope, dry_run: true })
+    });
+    if (!preview.success) {
+      alert(preview.error || 'Failed to preview clearing');
+      return;
+    }
+
+    const counts = Object.entries(preview.data.counts || {})
+      .map(([table, n]) => `  ${table}: ${n}`)
+      .join('\n');
+    const days = (preview.data.affected_dates || []).length;
+    const what = scope.all ? 'ALL visitor records and statistics' : 'the selected visitor records';
+    if (!confirm(`This will permanently delete ${what}:\n\n${counts}\n\n` +
+      (scope.all ? '' : `Statistics of ${days} day(s) will be rebuilt.\n\n`) +
+      'This action cannot be undone. Continue?')) return;
+
+    const data = await api('/system/clear-visitors', {
+      method: 'POST',
+      body: JSON.stringify({ ...scope, confirm_token: preview.data.confirm_token })
+    });
     if (data.success) {
-      alert('All visitor records have been cleared.');
+      alert(data.message || 'Visitor records have been cleared.');
       loadSystemSettings();
     } else {
       alert(data.error || 'Failed to clear visitors');
@@ -1431,6 +1499,18 @@ async function handlePasswordChange(e) {
 
 
 // Utilities
+// Schedule times are edited in the browser's timezone and sent as RFC 3339
+function toDateTimeLocal(dateStr) {
+  if (!dateStr) return '';
+  const d = new Date(dateStr);
+  const pad = n => String(n).padStart(2, '0');
+  return `${d.getFullYear()}-${pad(d.getMonth() + 1)}-${pad(d.getDate())}T${pad(d.getHours())}:${pad(d.getMinutes())}`;
+}
+
+function fromDateTimeLocal(value) {
+  return value ? new Date(value).toISOString() : '';
+}
+
 function formatDate(dateStr) {
   if (!dateStr) return '-';
   const d = new Date(dateStr);
@@ -119,9 +119,22 @@
 
   function trackPageView() {
     pageStartTime = Date.now();
+    maxScrollDepth = 0;
     track('pageview');
   }
 
+  // Track scroll depth (deepest point reached, in percent of the page)
+  let maxScrollDepth = 0;
+
+  function updateScrollDepth() {
+    const doc = document.documentElement;
+    const scrollable = doc.scrollHeight - window.innerHeight;
+    const depth = scrollable > 0
+      ? Math.round((window.scrollY / scrollable) * 100)
+      : 100;
+    maxScrollDepth = Math.max(maxScrollDepth, Math.min(100, depth));
+  }
+
   // Track heartbeat (keep alive)
   let heartbeatTimer = null;
 
@@ -147,7 +160,7 @@
   // Track page leave
   function trackLeave() {
     const duration = Math.round((Date.now() - pageStartTime) / 1000);
-    track('leave', { duration: duration });
+    track('leave', { duration: duration, scroll_depth: maxScrollDepth });
   }
 
   // Visibility change handler
@@ -155,7 +168,8 @@
     if (document.hidden) {
       track('heartbeat', { 
         event: 'visibility_hidden',
-        duration: Math.round((Date.now() - pageStartTime) / 1000)
+        duration: Math.round((Date.now() - pageStartTime) / 1000),
+        scroll_depth: maxScrollDepth
       });
     } else {
       track('heartbeat', { 
@@ -181,6 +195,9 @@
     // Start heartbeat
     startHeartbeat();
 
+    // Handle scroll depth
+    window.addEventListener('scroll', updateScrollDepth, { passive: true });
+
     // Handle visibility change
     document.addEventListener('visibilitychange', handleVisibilityChange);
 